@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/xhad/yag/internal/commands"
 )
@@ -12,7 +13,7 @@ func main() {
 	// Define command line subcommands
 	if len(os.Args) < 2 {
 		fmt.Println("Usage: yag <command> [<args>]")
-		fmt.Println("Available commands: init, add, commit, branch, checkout, status, restore")
+		fmt.Println("Available commands: init, add, commit, branch, checkout, status, restore, log, diff, merge, rev-parse, tag, reflog, reset, revert, cherry-pick, rebase, stash")
 		os.Exit(1)
 	}
 
@@ -52,17 +53,40 @@ func main() {
 
 	case "branch":
 		branchCmd := flag.NewFlagSet("branch", flag.ExitOnError)
+		del := branchCmd.Bool("d", false, "Delete a fully merged branch")
+		forceDel := branchCmd.Bool("D", false, "Delete a branch even if it is not merged")
+		rename := branchCmd.Bool("m", false, "Rename a branch")
+		forceRename := branchCmd.Bool("M", false, "Rename a branch even if the new name exists")
+		verbose := branchCmd.Bool("v", false, "Show the tip commit of each branch")
 		branchCmd.Parse(os.Args[1:])
-		err = commands.BranchCommand(branchCmd.Args())
+		err = commands.BranchCommandWithOptions(branchCmd.Args(), commands.BranchOptions{
+			Delete:  *del || *forceDel,
+			Rename:  *rename || *forceRename,
+			Force:   *forceDel || *forceRename,
+			Verbose: *verbose,
+		})
 
 	case "checkout":
 		checkoutCmd := flag.NewFlagSet("checkout", flag.ExitOnError)
+		force := checkoutCmd.Bool("force", false, "Discard local modifications that would be overwritten")
+		checkoutCmd.BoolVar(force, "f", false, "Shorthand for --force")
+		detach := checkoutCmd.Bool("detach", false, "Check out a commit without switching to a branch")
+		ours := checkoutCmd.Bool("ours", false, "Check out our version of conflicted paths")
+		theirs := checkoutCmd.Bool("theirs", false, "Check out their version of conflicted paths")
+		newBranch := checkoutCmd.String("b", "", "Create a branch and switch to it")
 		checkoutCmd.Parse(os.Args[1:])
-		if checkoutCmd.NArg() == 0 {
-			fmt.Println("Usage: yag checkout <branch>")
+		if checkoutCmd.NArg() == 0 && *newBranch == "" {
+			fmt.Println("Usage: yag checkout [-f|--force] [--detach] <branch|commit>")
+			fmt.Println("       yag checkout -b <new-branch> [<start-rev>]")
+			fmt.Println("       yag checkout --ours|--theirs <path1> [<path2> ...]")
 			os.Exit(1)
 		}
-		err = commands.CheckoutCommand(checkoutCmd.Arg(0))
+		opts := commands.CheckoutOptions{Force: *force, Detach: *detach, Ours: *ours, Theirs: *theirs, NewBranch: *newBranch}
+		if *ours || *theirs {
+			err = commands.CheckoutPathsCommand(checkoutCmd.Args(), opts)
+		} else {
+			err = commands.CheckoutCommandWithOptions(checkoutCmd.Arg(0), opts)
+		}
 
 	case "status":
 		statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
@@ -72,18 +96,177 @@ func main() {
 	case "restore":
 		restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
 		staged := restoreCmd.Bool("staged", false, "Restore staged changes (unstage files)")
+		source := restoreCmd.String("source", "", "Restore working tree files from the given commit or branch")
 		restoreCmd.Parse(os.Args[1:])
 
 		if restoreCmd.NArg() == 0 {
-			fmt.Println("Usage: yag restore [--staged] <file1> [<file2> ...]")
+			fmt.Println("Usage: yag restore [--staged] [--source <rev>] <pathspec1> [<pathspec2> ...]")
+			os.Exit(1)
+		}
+
+		err = commands.RestoreCommandWithOptions(restoreCmd.Args(), commands.RestoreOptions{
+			Staged: *staged,
+			Source: *source,
+		})
+
+	case "log":
+		logCmd := flag.NewFlagSet("log", flag.ExitOnError)
+		limit := logCmd.Int("n", 0, "Limit the number of commits shown")
+		oneline := logCmd.Bool("oneline", false, "Show each commit on a single line")
+		since := logCmd.String("since", "", "Show commits more recent than a date (YYYY-MM-DD or RFC 3339)")
+		until := logCmd.String("until", "", "Show commits older than a date (YYYY-MM-DD or RFC 3339)")
+		author := logCmd.String("author", "", "Show commits whose author matches")
+		logCmd.Parse(os.Args[1:])
+		err = commands.LogCommand(logCmd.Args(), commands.LogOptions{
+			Limit:   *limit,
+			Oneline: *oneline,
+			Since:   *since,
+			Until:   *until,
+			Author:  *author,
+		})
+
+	case "diff":
+		diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+		staged := diffCmd.Bool("staged", false, "Compare the index with HEAD")
+		cached := diffCmd.Bool("cached", false, "Synonym for --staged")
+		stat := diffCmd.Bool("stat", false, "Show a summary of changed lines per file")
+		nameStatus := diffCmd.Bool("name-status", false, "Show only the names and status of changed files")
+		diffCmd.Parse(os.Args[1:])
+		err = commands.DiffCommand(diffCmd.Args(), commands.DiffOptions{
+			Staged:     *staged || *cached,
+			Stat:       *stat,
+			NameStatus: *nameStatus,
+		})
+
+	case "merge":
+		mergeCmd := flag.NewFlagSet("merge", flag.ExitOnError)
+		abort := mergeCmd.Bool("abort", false, "Abandon a merge that stopped on conflicts")
+		cont := mergeCmd.Bool("continue", false, "Conclude a merge once its conflicts are resolved")
+		strategy := mergeCmd.String("strategy", "", "Resolve conflicting changes with a merge driver: ours, theirs or union")
+		mergeCmd.StringVar(strategy, "s", "", "Shorthand for --strategy")
+		mergeCmd.Parse(os.Args[1:])
+		if mergeCmd.NArg() == 0 && !*abort && !*cont {
+			fmt.Println("Usage: yag merge [-s|--strategy <ours|theirs|union>] <branch>")
+			fmt.Println("       yag merge --abort | --continue")
+			os.Exit(1)
+		}
+		err = commands.MergeCommandWithOptions(mergeCmd.Arg(0), commands.MergeOptions{
+			Abort:    *abort,
+			Continue: *cont,
+			Strategy: *strategy,
+		})
+
+	case "rev-parse":
+		revParseCmd := flag.NewFlagSet("rev-parse", flag.ExitOnError)
+		short := revParseCmd.Bool("short", false, "Print abbreviated hashes")
+		revParseCmd.Parse(os.Args[1:])
+		if revParseCmd.NArg() == 0 {
+			fmt.Println("Usage: yag rev-parse [--short] <rev1> [<rev2> ...]")
 			os.Exit(1)
 		}
+		err = commands.RevParseCommand(revParseCmd.Args(), commands.RevParseOptions{Short: *short})
+
+	case "tag":
+		tagCmd := flag.NewFlagSet("tag", flag.ExitOnError)
+		list := tagCmd.Bool("l", false, "List tags, optionally matching patterns")
+		del := tagCmd.Bool("d", false, "Delete tags")
+		annotate := tagCmd.Bool("a", false, "Create an annotated tag")
+		message := tagCmd.String("m", "", "Tag message (creates an annotated tag)")
+		force := tagCmd.Bool("f", false, "Replace an existing tag")
+		show := tagCmd.Bool("show", false, "Show a tag and the commit it points to")
+		tagCmd.Parse(os.Args[1:])
+		err = commands.TagCommand(tagCmd.Args(), commands.TagOptions{
+			List:     *list,
+			Delete:   *del,
+			Show:     *show,
+			Annotate: *annotate,
+			Force:    *force,
+			Message:  *message,
+		})
+
+	case "reflog":
+		reflogCmd := flag.NewFlagSet("reflog", flag.ExitOnError)
+		reflogCmd.Parse(os.Args[1:])
+		err = commands.ReflogCommand(reflogCmd.Args())
 
-		err = commands.RestoreCommand(restoreCmd.Args(), *staged)
+	case "reset":
+		resetCmd := flag.NewFlagSet("reset", flag.ExitOnError)
+		soft := resetCmd.Bool("soft", false, "Only move the current branch")
+		mixed := resetCmd.Bool("mixed", false, "Move the current branch and reset the index (default)")
+		hard := resetCmd.Bool("hard", false, "Move the current branch and reset the index and working tree")
+		resetCmd.Parse(os.Args[1:])
+		err = commands.ResetCommand(resetCmd.Args(), commands.ResetOptions{Soft: *soft, Mixed: *mixed, Hard: *hard})
+
+	case "revert":
+		revertCmd := flag.NewFlagSet("revert", flag.ExitOnError)
+		abort := revertCmd.Bool("abort", false, "Abandon a revert that stopped on conflicts")
+		cont := revertCmd.Bool("continue", false, "Conclude a revert once its conflicts are resolved")
+		revertCmd.Parse(os.Args[1:])
+		if revertCmd.NArg() == 0 && !*abort && !*cont {
+			fmt.Println("Usage: yag revert <commit>")
+			fmt.Println("       yag revert --abort | --continue")
+			os.Exit(1)
+		}
+		err = commands.RevertCommand(revertCmd.Arg(0), commands.RevertOptions{Abort: *abort, Continue: *cont})
+
+	case "cherry-pick":
+		cherryPickCmd := flag.NewFlagSet("cherry-pick", flag.ExitOnError)
+		abort := cherryPickCmd.Bool("abort", false, "Abandon the cherry-pick and return to where it started")
+		cont := cherryPickCmd.Bool("continue", false, "Conclude the stopped commit once its conflicts are resolved")
+		skip := cherryPickCmd.Bool("skip", false, "Drop the stopped commit and replay the rest")
+		cherryPickCmd.Parse(os.Args[1:])
+		if cherryPickCmd.NArg() == 0 && !*abort && !*cont && !*skip {
+			fmt.Println("Usage: yag cherry-pick <commit>...")
+			fmt.Println("       yag cherry-pick --continue | --skip | --abort")
+			os.Exit(1)
+		}
+		err = commands.CherryPickCommand(cherryPickCmd.Args(), commands.CherryPickOptions{
+			Abort:    *abort,
+			Continue: *cont,
+			Skip:     *skip,
+		})
+
+	case "rebase":
+		rebaseCmd := flag.NewFlagSet("rebase", flag.ExitOnError)
+		interactive := rebaseCmd.Bool("interactive", false, "Edit the list of commits to replay in $EDITOR")
+		rebaseCmd.BoolVar(interactive, "i", false, "Shorthand for --interactive")
+		abort := rebaseCmd.Bool("abort", false, "Abandon the rebase and check out the original branch")
+		cont := rebaseCmd.Bool("continue", false, "Resume the rebase once the stopped commit is resolved or edited")
+		skip := rebaseCmd.Bool("skip", false, "Drop the stopped commit and resume the rebase")
+		rebaseCmd.Parse(os.Args[1:])
+		if rebaseCmd.NArg() == 0 && !*abort && !*cont && !*skip {
+			fmt.Println("Usage: yag rebase [-i|--interactive] <upstream>")
+			fmt.Println("       yag rebase --continue | --skip | --abort")
+			os.Exit(1)
+		}
+		err = commands.RebaseCommand(rebaseCmd.Arg(0), commands.RebaseOptions{
+			Interactive: *interactive,
+			Abort:       *abort,
+			Continue:    *cont,
+			Skip:        *skip,
+		})
+
+	case "stash":
+		// The subcommand comes first, so its flags can follow it
+		var args []string
+		if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+			args = append(args, os.Args[1])
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+		stashCmd := flag.NewFlagSet("stash", flag.ExitOnError)
+		message := stashCmd.String("m", "", "Describe the stash entry")
+		index := stashCmd.Bool("index", false, "Also restore which changes were staged")
+		patch := stashCmd.Bool("p", false, "Show the stash entry as a patch")
+		stashCmd.Parse(os.Args[1:])
+		err = commands.StashCommand(append(args, stashCmd.Args()...), commands.StashOptions{
+			Message: *message,
+			Index:   *index,
+			Patch:   *patch,
+		})
 
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
-		fmt.Println("Available commands: init, add, commit, branch, checkout, status, restore")
+		fmt.Println("Available commands: init, add, commit, branch, checkout, status, restore, log, diff, merge, rev-parse, tag, reflog, reset, revert, cherry-pick, rebase, stash")
 		os.Exit(1)
 	}
 
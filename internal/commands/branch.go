@@ -4,11 +4,30 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/xhad/yag/internal/core"
 	"github.com/xhad/yag/internal/repository"
 )
 
+// BranchOptions selects what BranchCommand does
+type BranchOptions struct {
+	Delete  bool // Delete the named branches
+	Rename  bool // Rename a branch
+	Force   bool // Delete unmerged branches, or rename over an existing branch
+	Verbose bool // Show each branch's tip commit and subject when listing
+}
+
 // BranchCommand handles branch operations
 func BranchCommand(args []string) error {
+	return BranchCommandWithOptions(args, BranchOptions{})
+}
+
+// BranchCommandWithOptions lists, creates, deletes or renames branches
+// @notice "-d <name>..." deletes merged branches (with Force also unmerged ones), "-m [<old>] <new>" renames
+// a branch, defaulting to the current one
+// @param args The branch names
+// @param opts The operation and its options
+// @return error Returns nil on success or an error if the operation fails
+func BranchCommandWithOptions(args []string, opts BranchOptions) error {
 	// Open the repository
 	path, err := os.Getwd()
 	if err != nil {
@@ -20,15 +39,30 @@ func BranchCommand(args []string) error {
 		return err
 	}
 
+	if opts.Delete {
+		return deleteBranches(repo, args, opts.Force)
+	}
+
+	if opts.Rename {
+		return renameBranch(repo, args, opts.Force)
+	}
+
 	// If no branch name is provided, list all branches
 	if len(args) == 0 {
-		return listBranches(repo)
+		return listBranches(repo, opts.Verbose)
+	}
+
+	// Otherwise, create a new branch, at HEAD unless a start point is given
+	if len(args) > 2 {
+		return fmt.Errorf("usage: yag branch <name> [<start-rev>]")
 	}
 
-	// Otherwise, create a new branch
-	branchName := args[0]
+	branchName, startRev := args[0], ""
+	if len(args) == 2 {
+		startRev = args[1]
+	}
 
-	if err := repo.CreateBranch(branchName); err != nil {
+	if err := repo.CreateBranchAt(branchName, startRev); err != nil {
 		return err
 	}
 
@@ -36,8 +70,53 @@ func BranchCommand(args []string) error {
 	return nil
 }
 
+// deleteBranches deletes each named branch, printing the commit it pointed to
+func deleteBranches(repo *repository.Repository, names []string, force bool) error {
+	if len(names) == 0 {
+		return fmt.Errorf("branch name required")
+	}
+
+	for _, name := range names {
+		tip, err := repo.DeleteBranch(name, force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted branch %s (was %s).\n", name, tip[:8])
+	}
+
+	return nil
+}
+
+// renameBranch renames "<old> <new>", or the current branch when only "<new>" is given
+func renameBranch(repo *repository.Repository, args []string, force bool) error {
+	var oldName, newName string
+	switch len(args) {
+	case 1:
+		current, err := repo.GetCurrentBranch()
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			return fmt.Errorf("cannot rename the current branch: HEAD is detached")
+		}
+		oldName, newName = current, args[0]
+	case 2:
+		oldName, newName = args[0], args[1]
+	default:
+		return fmt.Errorf("usage: yag branch -m [<old-branch>] <new-branch>")
+	}
+
+	if err := repo.RenameBranch(oldName, newName, force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed branch '%s' to '%s'\n", oldName, newName)
+	return nil
+}
+
 // listBranches lists all branches in the repository
-func listBranches(repo *repository.Repository) error {
+// @dev In verbose mode each line also shows the branch's tip commit and its subject, with names padded to line up
+func listBranches(repo *repository.Repository, verbose bool) error {
 	branches, err := repo.ListBranches()
 	if err != nil {
 		return err
@@ -48,17 +127,68 @@ func listBranches(repo *repository.Repository) error {
 		return err
 	}
 
+	width := 0
+	for _, branch := range branches {
+		width = max(width, len(branch))
+	}
+
+	// A detached HEAD is listed first, like Git does
+	if currentBranch == "" {
+		headCommit, err := repo.GetStorage().GetHeadCommit()
+		if err != nil {
+			return err
+		}
+		if headCommit != nil {
+			label := fmt.Sprintf("(HEAD detached at %s)", headCommit.ID()[:8])
+			if verbose {
+				fmt.Printf("* %-*s %s %s\n", width, label, headCommit.ID()[:8], firstLine(headCommit.Message()))
+			} else {
+				fmt.Printf("* %s\n", label)
+			}
+		}
+	}
+
 	for _, branch := range branches {
 		prefix := "  "
 		if branch == currentBranch {
 			prefix = "* "
 		}
-		fmt.Printf("%s%s\n", prefix, branch)
+
+		if !verbose {
+			fmt.Printf("%s%s\n", prefix, branch)
+			continue
+		}
+
+		commit, err := branchTip(repo, branch)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%-*s %s %s\n", prefix, width, branch, commit.ID()[:8], firstLine(commit.Message()))
 	}
 
 	return nil
 }
 
+// branchTip reads the commit a branch points to
+func branchTip(repo *repository.Repository, branch string) (*core.Commit, error) {
+	hash, err := repo.GetStorage().GetRef(branch)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := repo.GetStorage().GetObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+
+	commit, ok := obj.(*core.Commit)
+	if !ok {
+		return nil, fmt.Errorf("branch '%s' does not point to a commit", branch)
+	}
+
+	return commit, nil
+}
+
 // getCurrentBranch gets the name of the current branch
 func getCurrentBranch(repo *repository.Repository) (string, error) {
 	// Use the repository's storage to get the HEAD reference
@@ -7,9 +7,25 @@ import (
 	"github.com/xhad/yag/internal/repository"
 )
 
+// CheckoutOptions controls how CheckoutCommandWithOptions switches branches
+type CheckoutOptions struct {
+	Force  bool // Discard local modifications that would be overwritten
+	Detach bool // Check out the commit a branch points to without switching to the branch
+	Ours   bool // Check out our version of conflicted paths
+	Theirs bool // Check out their version of conflicted paths
+
+	NewBranch string // Create a branch with this name at the target (or HEAD) and switch to it
+}
+
 // CheckoutCommand switches to the specified branch
 func CheckoutCommand(branchName string) error {
-	if branchName == "" {
+	return CheckoutCommandWithOptions(branchName, CheckoutOptions{})
+}
+
+// CheckoutCommandWithOptions switches to the specified branch using the given options
+// @dev With NewBranch set, branchName is the optional start point of the new branch
+func CheckoutCommandWithOptions(branchName string, opts CheckoutOptions) error {
+	if branchName == "" && opts.NewBranch == "" {
 		return fmt.Errorf("branch name is required")
 	}
 
@@ -24,11 +40,78 @@ func CheckoutCommand(branchName string) error {
 		return err
 	}
 
-	// Checkout the branch
-	if err := repo.Checkout(branchName); err != nil {
+	if opts.NewBranch != "" {
+		if err := repo.CheckoutNewBranch(opts.NewBranch, branchName, opts.Force); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to a new branch '%s'\n", opts.NewBranch)
+		return nil
+	}
+
+	// Checkout the branch, or detach HEAD at a commit
+	if opts.Detach {
+		err = repo.CheckoutDetached(branchName, opts.Force)
+	} else {
+		err = repo.Checkout(branchName, opts.Force)
+	}
+	if err != nil {
+		return err
+	}
+
+	detached, err := repo.IsDetached()
+	if err != nil {
+		return err
+	}
+
+	if !detached {
+		fmt.Printf("Switched to branch '%s'\n", branchName)
+		return nil
+	}
+
+	headCommit, err := repo.GetStorage().GetHeadCommit()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("HEAD is now at %s %s\n", headCommit.ID()[:8], firstLine(headCommit.Message()))
+	return nil
+}
+
+// CheckoutPathsCommand writes one side's version of conflicted files into the working tree
+// @notice Requires exactly one of Ours or Theirs; the files stay unmerged until they are added
+// @param paths The pathspecs selecting conflicted files
+// @param opts Which side of the conflict to check out
+// @return error Returns nil on success or an error if a path is not conflicted
+func CheckoutPathsCommand(paths []string, opts CheckoutOptions) error {
+	if opts.Ours == opts.Theirs {
+		return fmt.Errorf("exactly one of --ours and --theirs is required")
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("at least one path is required")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	side := repository.ConflictOurs
+	if opts.Theirs {
+		side = repository.ConflictTheirs
+	}
+
+	updated, err := repo.CheckoutConflictSide(paths, side)
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Switched to branch '%s'\n", branchName)
+	fmt.Printf("Updated %d path%s from the index\n", len(updated), plural(len(updated)))
 	return nil
 }
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// CherryPickOptions controls what CherryPickCommand does
+type CherryPickOptions struct {
+	Abort    bool // Abandon the cherry-pick and return to where it started
+	Continue bool // Conclude the stopped commit once its conflicts are resolved and replay the rest
+	Skip     bool // Drop the stopped commit and replay the rest
+}
+
+// CherryPickCommand replays commits onto the current branch, or continues, skips or aborts a stopped cherry-pick
+// @notice Prints one line per new commit; conflicting files are written with markers and reported
+// @param revs The commits to replay, in order; ignored with Abort, Continue or Skip
+// @param opts Whether to abort, continue or skip instead
+// @return error Returns nil on success or an error if the cherry-pick fails or has conflicts
+func CherryPickCommand(revs []string, opts CherryPickOptions) error {
+	actions := 0
+	for _, set := range []bool{opts.Abort, opts.Continue, opts.Skip} {
+		if set {
+			actions++
+		}
+	}
+	if actions > 1 {
+		return fmt.Errorf("--abort, --continue and --skip cannot be used together")
+	}
+
+	if len(revs) == 0 && actions == 0 {
+		return fmt.Errorf("commit is required")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	var outcome *repository.CherryPickOutcome
+	switch {
+	case opts.Abort:
+		if err := repo.CherryPickAbort(); err != nil {
+			return err
+		}
+		fmt.Println("Cherry-pick aborted.")
+		return nil
+
+	case opts.Continue:
+		outcome, err = repo.CherryPickContinue()
+
+	case opts.Skip:
+		outcome, err = repo.CherryPickSkip()
+
+	default:
+		outcome, err = repo.CherryPick(revs)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, pick := range outcome.Picks {
+		if pick.CommitHash == "" {
+			fmt.Printf("Dropped %s: its changes are already in HEAD\n", pick.Source[:8])
+			continue
+		}
+		fmt.Printf("[%s] %s\n", pick.CommitHash[:8], firstLine(pick.Message))
+	}
+
+	if outcome.Stopped != "" {
+		fmt.Printf("could not apply %s\n", outcome.Stopped[:8])
+		return reportConflicts(repo, outcome.Conflicts, "cherry-pick")
+	}
+
+	return nil
+}
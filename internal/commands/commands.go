@@ -1,7 +1,7 @@
 // Package commands implements the command-line interface functionality for YAG
 // @title YAG Command System
 // @author XHad
-// @notice Contains implementations of all YAG commands (init, add, commit, branch, checkout, status, restore)
+// @notice Contains implementations of all YAG commands (init, add, commit, branch, checkout, status, restore, log, diff, merge, rev-parse, tag, reflog, reset, revert, cherry-pick, rebase, stash)
 // @dev Each command is implemented in its own file for maintainability
 package commands
 
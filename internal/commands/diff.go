@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// DiffOptions controls what DiffCommand compares and how it prints the result
+type DiffOptions struct {
+	Staged     bool // Compare the index with HEAD instead of the working tree with the index
+	Stat       bool // Print a per-file summary of changed lines instead of the patch
+	NameStatus bool // Print only the change type and path of each file
+}
+
+// diffContextLines is the number of unchanged lines shown around each change
+const diffContextLines = 3
+
+// statBarWidth is the widest +/- bar printed by --stat
+const statBarWidth = 40
+
+// DiffCommand shows changes between the working tree, the index and commits
+// @notice Arguments are "[<rev> [<rev>]] [--] [<path>...]"
+// @dev With one revision the working tree (or index with --staged) is compared against it, with two they are compared with each other
+// @param args The optional revisions and pathspecs
+// @param opts The comparison and output options
+// @return error Returns nil on success or an error if a snapshot cannot be read
+func DiffCommand(args []string, opts DiffOptions) error {
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	revs, paths := splitRevisionsAndPaths(repo, args, 2)
+
+	diffOpts := repository.DiffOptions{
+		Staged: opts.Staged,
+		Paths:  paths,
+	}
+	if len(revs) > 0 {
+		diffOpts.From = revs[0]
+	}
+	if len(revs) > 1 {
+		diffOpts.To = revs[1]
+	}
+
+	diffs, err := repo.Diff(diffOpts)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.NameStatus:
+		printNameStatus(diffs)
+	case opts.Stat:
+		printDiffStat(diffs)
+	default:
+		printPatch(diffs)
+	}
+
+	return nil
+}
+
+// splitRevisionsAndPaths separates up to max leading revisions from pathspecs
+func splitRevisionsAndPaths(repo *repository.Repository, args []string, max int) ([]string, []string) {
+	var revs []string
+
+	for i, arg := range args {
+		if arg == "--" {
+			return revs, args[i+1:]
+		}
+		if len(revs) == max || !repo.IsRevision(arg) {
+			return revs, args[i:]
+		}
+		revs = append(revs, arg)
+	}
+
+	return revs, nil
+}
+
+// printPatch prints every file diff in unified format
+func printPatch(diffs []*repository.FileDiff) {
+	for _, diff := range diffs {
+		oldName, newName := "a/"+diff.Path, "b/"+diff.Path
+
+		fmt.Printf("diff --yag %s %s\n", oldName, newName)
+		switch diff.Change {
+		case repository.ChangeAdded:
+			fmt.Println("new file")
+			oldName = "/dev/null"
+		case repository.ChangeDeleted:
+			fmt.Println("deleted file")
+			newName = "/dev/null"
+		}
+
+		if diff.Binary {
+			fmt.Printf("Binary files %s and %s differ\n", oldName, newName)
+			continue
+		}
+
+		fmt.Print(core.FormatUnified(oldName, newName, diff.Lines, diffContextLines))
+	}
+}
+
+// printNameStatus prints one "<status>\t<path>" line per changed file
+func printNameStatus(diffs []*repository.FileDiff) {
+	for _, diff := range diffs {
+		fmt.Printf("%s\t%s\n", changeLetters[diff.Change], diff.Path)
+	}
+}
+
+// changeLetters maps each kind of change to the letter used in short listings
+var changeLetters = map[repository.FileChange]string{
+	repository.ChangeAdded:    "A",
+	repository.ChangeModified: "M",
+	repository.ChangeDeleted:  "D",
+}
+
+// printDiffStat prints a histogram of changed lines per file followed by a summary
+func printDiffStat(diffs []*repository.FileDiff) {
+	if len(diffs) == 0 {
+		return
+	}
+
+	nameWidth, maxChanges := 0, 0
+	for _, diff := range diffs {
+		if len(diff.Path) > nameWidth {
+			nameWidth = len(diff.Path)
+		}
+		insertions, deletions := diff.Stats()
+		if insertions+deletions > maxChanges {
+			maxChanges = insertions + deletions
+		}
+	}
+
+	totalInsertions, totalDeletions := 0, 0
+	for _, diff := range diffs {
+		if diff.Binary {
+			fmt.Printf(" %-*s | Bin\n", nameWidth, diff.Path)
+			continue
+		}
+
+		insertions, deletions := diff.Stats()
+		totalInsertions += insertions
+		totalDeletions += deletions
+
+		// Scale the bar down when the largest change does not fit
+		plus, minus := insertions, deletions
+		if maxChanges > statBarWidth {
+			plus = insertions * statBarWidth / maxChanges
+			minus = deletions * statBarWidth / maxChanges
+		}
+
+		fmt.Printf(" %-*s | %d %s%s\n", nameWidth, diff.Path, insertions+deletions,
+			strings.Repeat("+", plus), strings.Repeat("-", minus))
+	}
+
+	fmt.Printf(" %d file%s changed, %d insertion%s(+), %d deletion%s(-)\n",
+		len(diffs), plural(len(diffs)),
+		totalInsertions, plural(totalInsertions),
+		totalDeletions, plural(totalDeletions))
+}
+
+// plural returns "s" unless n is exactly one
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhad/yag/internal/repository"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// defaultEditor is started when $EDITOR is not set
+const defaultEditor = "vi"
+
+// textEditor returns an editor that opens text in $EDITOR
+// @dev The text is written to the named file in the repository's .yag directory, the editor is started on that
+// file, and the file's content is read back once the editor exits
+// @param root The repository root
+// @return repository.Editor The editor to pass to repository operations
+func textEditor(root string) repository.Editor {
+	return func(name, text string) (string, error) {
+		path := filepath.Join(root, storage.YAGDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			return "", err
+		}
+
+		editor := os.Getenv("EDITOR")
+		if strings.TrimSpace(editor) == "" {
+			editor = defaultEditor
+		}
+
+		// $EDITOR may carry arguments, e.g. "code --wait"
+		args := strings.Fields(editor)
+		cmd := exec.Command(args[0], append(args[1:], path)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("there was a problem with the editor '%s': %v", editor, err)
+		}
+
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		return string(edited), nil
+	}
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// LogOptions controls how LogCommand filters and prints history
+type LogOptions struct {
+	Limit   int    // Maximum number of commits to show; 0 means no limit
+	Oneline bool   // Print each commit on a single line
+	Since   string // Only show commits at or after this date
+	Until   string // Only show commits at or before this date
+	Author  string // Only show commits whose author contains this string
+}
+
+// dateLayouts lists the formats accepted by --since and --until
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// LogCommand shows the commit history
+// @notice Walks history from HEAD, or from a given revision, optionally limited to commits touching some paths
+// @dev Arguments are "[<rev>] [--] [<path>...]"; without "--" the first argument is a revision only if it resolves to one
+// @param args The optional revision and pathspecs
+// @param opts The filters and output format
+// @return error Returns nil on success or an error if the history cannot be read
+func LogCommand(args []string, opts LogOptions) error {
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	logOpts := repository.LogOptions{
+		Limit:  opts.Limit,
+		Author: opts.Author,
+	}
+
+	if logOpts.Since, err = parseDate(opts.Since); err != nil {
+		return fmt.Errorf("invalid --since date: %v", err)
+	}
+	if logOpts.Until, err = parseDate(opts.Until); err != nil {
+		return fmt.Errorf("invalid --until date: %v", err)
+	}
+
+	revs, paths := splitRevisionsAndPaths(repo, args, 1)
+	if len(revs) > 0 {
+		logOpts.Start = revs[0]
+	}
+	logOpts.Paths = paths
+
+	it, err := repo.Log(logOpts)
+	if err != nil {
+		return err
+	}
+
+	first := true
+	for {
+		commit, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if commit == nil {
+			break
+		}
+
+		if opts.Oneline {
+			fmt.Printf("%s %s\n", commit.ID()[:8], firstLine(commit.Message()))
+			continue
+		}
+
+		if !first {
+			fmt.Println()
+		}
+		first = false
+		printCommit(commit)
+	}
+
+	return nil
+}
+
+// printCommit prints a commit in the default multi-line log format
+func printCommit(commit *core.Commit) {
+	fmt.Printf("commit %s\n", commit.ID())
+	if commit.IsMerge() {
+		short := make([]string, 0, len(commit.ParentHashes()))
+		for _, parent := range commit.ParentHashes() {
+			short = append(short, parent[:8])
+		}
+		fmt.Printf("Merge: %s\n", strings.Join(short, " "))
+	}
+	fmt.Printf("Author: %s\n", commit.Author())
+	fmt.Printf("Date:   %s\n", commit.Timestamp().Format("Mon Jan 2 15:04:05 2006 -0700"))
+	fmt.Println()
+	for _, line := range strings.Split(commit.Message(), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// firstLine returns the subject line of a commit message
+func firstLine(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return message[:i]
+	}
+	return message
+}
+
+// parseDate parses a --since or --until value, returning the zero time for an empty string
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized date '%s' (use YYYY-MM-DD or RFC 3339)", value)
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// MergeOptions controls what MergeCommandWithOptions does
+type MergeOptions struct {
+	Abort    bool   // Abandon a merge that stopped on conflicts
+	Continue bool   // Conclude a merge once its conflicts are resolved
+	Strategy string // Merge driver for files without a merge attribute: text, ours, theirs or union
+}
+
+// MergeCommand merges another branch or commit into the current branch
+func MergeCommand(rev string) error {
+	return MergeCommandWithOptions(rev, MergeOptions{})
+}
+
+// MergeCommandWithOptions merges another branch or commit, or continues or aborts a conflicted merge
+// @notice Fast-forwards when possible, otherwise creates a merge commit; conflicting files are written with markers and reported
+// @param rev The branch or commit to merge; ignored with Abort or Continue
+// @param opts Whether to abort or continue an in-progress merge instead
+// @return error Returns nil on success or an error if the merge fails or has conflicts
+func MergeCommandWithOptions(rev string, opts MergeOptions) error {
+	if opts.Abort && opts.Continue {
+		return fmt.Errorf("--abort and --continue cannot be used together")
+	}
+
+	if rev == "" && !opts.Abort && !opts.Continue {
+		return fmt.Errorf("branch name is required")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Abort:
+		if err := repo.MergeAbort(); err != nil {
+			return err
+		}
+		fmt.Println("Merge aborted.")
+		return nil
+
+	case opts.Continue:
+		commitID, err := repo.MergeContinue()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[%s] Merge completed\n", commitID[:8])
+		return nil
+	}
+
+	outcome, err := repo.MergeWithOptions(rev, repository.MergeOptions{Strategy: opts.Strategy})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case outcome.UpToDate:
+		fmt.Println("Already up to date.")
+	case outcome.FastForward:
+		fmt.Printf("Fast-forward to %s\n", outcome.CommitHash[:8])
+	case len(outcome.Conflicts) > 0:
+		return reportConflicts(repo, outcome.Conflicts, "merge")
+	default:
+		strategy := opts.Strategy
+		if strategy == "" {
+			strategy = "three-way"
+		}
+		fmt.Printf("Merge made by the '%s' strategy.\n[%s] %s\n", strategy, outcome.CommitHash[:8], rev)
+	}
+
+	return nil
+}
+
+// reportConflicts prints one line per conflicted file and returns the error that stops the command
+// @param operation The command that stopped, e.g. "merge", named in the hint on how to conclude it
+func reportConflicts(repo *repository.Repository, conflicts []string, operation string) error {
+	if err := printConflicts(repo, conflicts); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("automatic %s failed; fix conflicts and then run 'yag %s --continue'", operation, operation)
+}
+
+// printConflicts prints one line per conflicted file, naming the kind of conflict
+func printConflicts(repo *repository.Repository, conflicts []string) error {
+	status, err := repo.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range conflicts {
+		switch status.Unmerged[file] {
+		case repository.UnmergedDeletedByUs, repository.UnmergedDeletedByThem:
+			fmt.Printf("CONFLICT (modify/delete): %s %s\n", file, status.Unmerged[file])
+		case repository.UnmergedBothAdded:
+			fmt.Printf("CONFLICT (add/add): Merge conflict in %s\n", file)
+		default:
+			fmt.Printf("CONFLICT (content): Merge conflict in %s\n", file)
+		}
+	}
+
+	return nil
+}
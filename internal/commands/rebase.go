@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// RebaseOptions controls what RebaseCommand does
+type RebaseOptions struct {
+	Interactive bool // Edit the todo list in $EDITOR before replaying
+	Abort       bool // Abandon the rebase and check out the original branch
+	Continue    bool // Resume the rebase once conflicts are resolved or the stopped commit is edited
+	Skip        bool // Drop the stopped commit and resume the rebase
+}
+
+// RebaseCommand replays the current branch onto another commit, or continues, skips or aborts a stopped rebase
+// @notice Reworded and squashed commits open their message in $EDITOR; conflicting files are written with markers and reported
+// @param upstream The commit to replay onto; ignored with Abort, Continue or Skip
+// @param opts Whether to edit the todo list, or to abort, continue or skip instead
+// @return error Returns nil on success or an error if the rebase fails or stops on conflicts
+func RebaseCommand(upstream string, opts RebaseOptions) error {
+	actions := 0
+	for _, set := range []bool{opts.Abort, opts.Continue, opts.Skip} {
+		if set {
+			actions++
+		}
+	}
+	if actions > 1 {
+		return fmt.Errorf("--abort, --continue and --skip cannot be used together")
+	}
+
+	if upstream == "" && actions == 0 {
+		return fmt.Errorf("upstream is required")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	edit := textEditor(path)
+
+	var outcome *repository.RebaseOutcome
+	switch {
+	case opts.Abort:
+		if err := repo.RebaseAbort(); err != nil {
+			return err
+		}
+		fmt.Println("Rebase aborted.")
+		return nil
+
+	case opts.Continue:
+		outcome, err = repo.RebaseContinue(edit)
+
+	case opts.Skip:
+		outcome, err = repo.RebaseSkip(edit)
+
+	default:
+		outcome, err = repo.Rebase(upstream, repository.RebaseOptions{Interactive: opts.Interactive, Edit: edit})
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case outcome.UpToDate:
+		fmt.Printf("Current branch %s is up to date.\n", outcome.Branch)
+
+	case outcome.Editing:
+		fmt.Printf("Stopped at %s\n", outcome.Stopped[:8])
+		fmt.Println("Stage changes to fold them into this commit, or make new commits, then run 'yag rebase --continue'")
+
+	case outcome.Stopped != "":
+		fmt.Printf("could not apply %s\n", outcome.Stopped[:8])
+		return reportConflicts(repo, outcome.Conflicts, "rebase")
+
+	case outcome.Branch != "":
+		fmt.Printf("Successfully rebased and updated refs/heads/%s.\n", outcome.Branch)
+
+	default:
+		fmt.Println("Successfully rebased.")
+	}
+
+	return nil
+}
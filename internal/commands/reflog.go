@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// ReflogCommand shows the recorded values of a ref, newest first
+// @notice Each line shows the value, the "<ref>@{n}" expression that resolves to it and what moved the ref there
+// @param args An optional ref name; without one HEAD's reflog is shown
+// @return error Returns nil on success or an error if the ref is unknown
+func ReflogCommand(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: yag reflog [<ref>]")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	ref := "HEAD"
+	if len(args) == 1 {
+		ref = args[0]
+	}
+
+	entries, err := repo.Reflog(ref)
+	if err != nil {
+		return err
+	}
+
+	for n, entry := range entries {
+		hash := entry.NewHash
+		if len(hash) > 8 {
+			hash = hash[:8]
+		}
+		fmt.Printf("%s %s@{%d}: %s\n", hash, ref, n, entry.Reason)
+	}
+
+	return nil
+}
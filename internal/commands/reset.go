@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// ResetOptions selects how much ResetCommand rewrites; at most one may be set, and none means mixed
+type ResetOptions struct {
+	Soft  bool // Only move the current branch
+	Mixed bool // Also reset the index
+	Hard  bool // Also reset the index and working tree
+}
+
+// mode returns the reset mode the options select
+func (o ResetOptions) mode() (repository.ResetMode, error) {
+	set := 0
+	for _, flag := range []bool{o.Soft, o.Mixed, o.Hard} {
+		if flag {
+			set++
+		}
+	}
+	if set > 1 {
+		return repository.ResetMixed, fmt.Errorf("only one of --soft, --mixed and --hard can be used")
+	}
+
+	switch {
+	case o.Soft:
+		return repository.ResetSoft, nil
+	case o.Hard:
+		return repository.ResetHard, nil
+	default:
+		return repository.ResetMixed, nil
+	}
+}
+
+// ResetCommand moves the current branch to a commit, or resets index entries for some paths
+// @notice Arguments are "[<rev>] [--] [<path>...]". Without paths HEAD moves and, depending on mode, the index
+// and working tree follow; with paths only their index entries are reset and HEAD stays put
+// @param args The optional revision and pathspecs
+// @param opts How much to rewrite when moving HEAD
+// @return error Returns nil on success or an error if the revision or a path is unknown
+func ResetCommand(args []string, opts ResetOptions) error {
+	mode, err := opts.mode()
+	if err != nil {
+		return err
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	revs, paths := splitRevisionsAndPaths(repo, args, 1)
+	rev := ""
+	if len(revs) > 0 {
+		rev = revs[0]
+	}
+
+	if len(paths) > 0 {
+		if mode != repository.ResetMixed {
+			return fmt.Errorf("cannot do a %s reset with paths", mode)
+		}
+		if _, err := repo.ResetPaths(rev, paths); err != nil {
+			return err
+		}
+		return printUnstaged(repo)
+	}
+
+	if err := repo.Reset(rev, mode); err != nil {
+		return err
+	}
+
+	switch mode {
+	case repository.ResetHard:
+		headCommit, err := repo.GetStorage().GetHeadCommit()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD is now at %s %s\n", headCommit.ID()[:8], firstLine(headCommit.Message()))
+	case repository.ResetMixed:
+		return printUnstaged(repo)
+	}
+
+	return nil
+}
+
+// printUnstaged lists the working tree changes that are not staged, as left behind by a reset
+func printUnstaged(repo *repository.Repository) error {
+	status, err := repo.Status()
+	if err != nil {
+		return err
+	}
+
+	if len(status.Unstaged) == 0 {
+		return nil
+	}
+
+	files := make([]string, 0, len(status.Unstaged))
+	for file := range status.Unstaged {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	fmt.Println("Unstaged changes after reset:")
+	for _, file := range files {
+		fmt.Printf("%s\t%s\n", changeLetters[status.Unstaged[file]], file)
+	}
+
+	return nil
+}
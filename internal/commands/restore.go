@@ -7,13 +7,28 @@ import (
 	"github.com/xhad/yag/internal/repository"
 )
 
+// RestoreOptions controls what RestoreCommandWithOptions restores and where from
+type RestoreOptions struct {
+	Staged bool   // Unstage files instead of restoring the working tree
+	Source string // Revision to restore working tree files from; empty means the index
+}
+
 // RestoreCommand handles restoring files from the staging area
-// @notice Removes files from the staging area when used with the --staged flag
-// @dev Currently only supports unstaging files; restoring working tree changes is not implemented
-// @param args The file paths to be unstaged
+// @notice Removes files from the staging area when used with the --staged flag, otherwise discards local modifications
+// @param args The file paths to be restored
 // @param staged Boolean flag indicating whether to unstage files (true) or restore working tree (false)
 // @return error Returns nil on success or an error if the operation fails
 func RestoreCommand(args []string, staged bool) error {
+	return RestoreCommandWithOptions(args, RestoreOptions{Staged: staged})
+}
+
+// RestoreCommandWithOptions restores files using the given options
+// @notice Unstages files with Staged, otherwise rewrites working tree files from the index or from Source
+// @dev Paths may be files, directories or glob patterns
+// @param args The paths to be restored
+// @param opts The restore options
+// @return error Returns nil on success or an error if the operation fails
+func RestoreCommandWithOptions(args []string, opts RestoreOptions) error {
 	if len(args) == 0 {
 		return fmt.Errorf("nothing specified, nothing restored")
 	}
@@ -30,7 +45,11 @@ func RestoreCommand(args []string, staged bool) error {
 	}
 
 	// Check if we're unstaging files
-	if staged {
+	if opts.Staged {
+		if opts.Source != "" {
+			return fmt.Errorf("--source cannot be combined with --staged")
+		}
+
 		for _, file := range args {
 			if err := repo.Unstage(file); err != nil {
 				return fmt.Errorf("failed to unstage '%s': %v", file, err)
@@ -40,7 +59,15 @@ func RestoreCommand(args []string, staged bool) error {
 		return nil
 	}
 
-	// TODO: Implement restoring working tree changes
-	// (Discarding local modifications)
-	return fmt.Errorf("restoring working tree changes is not yet implemented")
+	// Discard local modifications
+	restored, err := repo.Restore(args, opts.Source)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range restored {
+		fmt.Printf("Restored '%s'\n", file)
+	}
+
+	return nil
 }
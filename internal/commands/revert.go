@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// RevertOptions controls what RevertCommand does
+type RevertOptions struct {
+	Abort    bool // Abandon a revert that stopped on conflicts
+	Continue bool // Conclude a revert once its conflicts are resolved
+}
+
+// RevertCommand records a commit undoing another commit, or continues or aborts a conflicted revert
+// @notice Conflicting files are written with markers and reported
+// @param rev The commit to revert; ignored with Abort or Continue
+// @param opts Whether to abort or continue an in-progress revert instead
+// @return error Returns nil on success or an error if the revert fails or has conflicts
+func RevertCommand(rev string, opts RevertOptions) error {
+	if opts.Abort && opts.Continue {
+		return fmt.Errorf("--abort and --continue cannot be used together")
+	}
+
+	if rev == "" && !opts.Abort && !opts.Continue {
+		return fmt.Errorf("commit is required")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Abort:
+		if err := repo.RevertAbort(); err != nil {
+			return err
+		}
+		fmt.Println("Revert aborted.")
+		return nil
+
+	case opts.Continue:
+		commitID, err := repo.RevertContinue()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[%s] Revert completed\n", commitID[:8])
+		return nil
+	}
+
+	outcome, err := repo.Revert(rev)
+	if err != nil {
+		return err
+	}
+
+	if len(outcome.Conflicts) > 0 {
+		return reportConflicts(repo, outcome.Conflicts, "revert")
+	}
+
+	fmt.Printf("[%s] %s\n", outcome.CommitHash[:8], firstLine(outcome.Message))
+	return nil
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// RevParseOptions controls how RevParseCommand prints hashes
+type RevParseOptions struct {
+	Short bool // Print abbreviated hashes
+}
+
+// RevParseCommand resolves revision expressions and prints the object hash each one names
+// @notice Supports everything Repository.ResolveRevision does, e.g. "HEAD~2", "main^2" or "HEAD:src/main.go"
+// @param args The revision expressions to resolve
+// @param opts The output options
+// @return error Returns nil on success or an error for the first expression that does not resolve
+func RevParseCommand(args []string, opts RevParseOptions) error {
+	if len(args) == 0 {
+		return fmt.Errorf("at least one revision is required")
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	for _, rev := range args {
+		hash, err := repo.ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+
+		if opts.Short && len(hash) > 8 {
+			hash = hash[:8]
+		}
+		fmt.Println(hash)
+	}
+
+	return nil
+}
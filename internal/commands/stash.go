@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xhad/yag/internal/repository"
+)
+
+// StashOptions controls what StashCommand does
+type StashOptions struct {
+	Message string // Describes the entry created by push
+	Index   bool   // Restore which changes were staged when applying or popping
+	Patch   bool   // Show the entry as a patch instead of a summary
+}
+
+// StashCommand saves local changes away and brings them back
+// @notice Arguments are "[push|list|show|apply|pop|drop] [<stash>]"; without a subcommand the changes are pushed
+// @param args The subcommand and the optional stash entry, e.g. "stash@{1}"
+// @param opts The push message and the apply and show options
+// @return error Returns nil on success or an error if the subcommand fails or stops on conflicts
+func StashCommand(args []string, opts StashOptions) error {
+	action := "push"
+	if len(args) > 0 {
+		action = args[0]
+		args = args[1:]
+	}
+
+	ref := ""
+	switch {
+	case action == "push" || action == "list":
+		if len(args) > 0 {
+			return fmt.Errorf("stash %s takes no arguments", action)
+		}
+	case len(args) > 1:
+		return fmt.Errorf("too many arguments; expected at most one stash entry")
+	case len(args) == 1:
+		ref = args[0]
+	}
+
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "push":
+		entry, err := repo.StashPush(opts.Message)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			fmt.Println("No local changes to save")
+			return nil
+		}
+		fmt.Printf("Saved working directory and index state %s\n", entry.Message)
+
+	case "list":
+		stashes, err := repo.StashList()
+		if err != nil {
+			return err
+		}
+		for _, entry := range stashes {
+			fmt.Printf("%s: %s\n", entry.Name(), entry.Message)
+		}
+
+	case "show":
+		entry, err := repo.StashEntryFor(ref)
+		if err != nil {
+			return err
+		}
+
+		// The entry's changes are those of its working tree commit against the commit it was made on
+		diffs, err := repo.Diff(repository.DiffOptions{From: entry.Commit + "^1", To: entry.Commit})
+		if err != nil {
+			return err
+		}
+		if opts.Patch {
+			printPatch(diffs)
+		} else {
+			printDiffStat(diffs)
+		}
+
+	case "apply", "pop":
+		var outcome *repository.StashApplyOutcome
+		if action == "pop" {
+			outcome, err = repo.StashPop(ref, opts.Index)
+		} else {
+			outcome, err = repo.StashApply(ref, opts.Index)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(outcome.Conflicts) > 0 {
+			if err := printConflicts(repo, outcome.Conflicts); err != nil {
+				return err
+			}
+			if action == "pop" {
+				fmt.Println("The stash entry is kept in case you need it again.")
+			}
+			return fmt.Errorf("applying %s left conflicts; fix them and stage the results with 'yag add'", outcome.Entry.Name())
+		}
+
+		if outcome.Dropped {
+			fmt.Printf("Dropped %s (%s)\n", outcome.Entry.Name(), outcome.Entry.Commit[:8])
+		} else {
+			fmt.Printf("Applied %s\n", outcome.Entry.Name())
+		}
+
+	case "drop":
+		entry, err := repo.StashDrop(ref)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Dropped %s (%s)\n", entry.Name(), entry.Commit[:8])
+
+	default:
+		return fmt.Errorf("unknown stash subcommand '%s'; expected push, list, show, apply, pop or drop", action)
+	}
+
+	return nil
+}
@@ -32,7 +32,69 @@ func StatusCommand(args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("On branch %s\n", branch)
+	if branch != "" {
+		fmt.Printf("On branch %s\n", branch)
+	} else {
+		headCommit, err := repo.GetStorage().GetHeadCommit()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD detached at %s\n", headCommit.ID()[:8])
+	}
+
+	// Explain how to finish an interrupted merge
+	if status.Merging {
+		if len(status.Unmerged) > 0 {
+			fmt.Println("You have unmerged paths.")
+			fmt.Println("  (fix conflicts and run \"yag merge --continue\")")
+			fmt.Println("  (use \"yag merge --abort\" to abort the merge)")
+		} else {
+			fmt.Println("All conflicts fixed but you are still merging.")
+			fmt.Println("  (use \"yag merge --continue\" to conclude merge)")
+		}
+	}
+
+	// Explain how to finish an interrupted revert
+	if status.Reverting != "" {
+		fmt.Printf("You are currently reverting commit %s.\n", status.Reverting[:8])
+		if len(status.Unmerged) > 0 {
+			fmt.Println("  (fix conflicts and run \"yag revert --continue\")")
+		} else {
+			fmt.Println("  (all conflicts fixed: run \"yag revert --continue\")")
+		}
+		fmt.Println("  (use \"yag revert --abort\" to cancel the revert operation)")
+	}
+
+	// Explain how to finish an interrupted cherry-pick
+	if status.Picking != "" {
+		fmt.Printf("You are currently cherry-picking commit %s.\n", status.Picking[:8])
+		if len(status.Unmerged) > 0 {
+			fmt.Println("  (fix conflicts and run \"yag cherry-pick --continue\")")
+		} else {
+			fmt.Println("  (all conflicts fixed: run \"yag cherry-pick --continue\")")
+		}
+		fmt.Println("  (use \"yag cherry-pick --skip\" to skip this patch)")
+		fmt.Println("  (use \"yag cherry-pick --abort\" to cancel the cherry-pick operation)")
+		if status.PicksLeft > 0 {
+			fmt.Printf("%d more commit%s to replay after this one.\n", status.PicksLeft, plural(status.PicksLeft))
+		}
+	} else if status.PicksLeft > 0 {
+		fmt.Printf("You are in the middle of a cherry-pick with %d commit%s left to replay.\n", status.PicksLeft, plural(status.PicksLeft))
+		fmt.Println("  (use \"yag cherry-pick --continue\" to replay them)")
+		fmt.Println("  (use \"yag cherry-pick --abort\" to cancel the cherry-pick operation)")
+	}
+
+	// Explain how to finish an interrupted rebase
+	if status.Rebasing != "" {
+		fmt.Printf("rebase in progress; onto %s\n", status.Rebasing[:8])
+		if len(status.Unmerged) > 0 {
+			fmt.Println("  (fix conflicts and then run \"yag rebase --continue\")")
+		} else {
+			fmt.Println("  (use \"yag rebase --continue\" once you are satisfied with your changes)")
+		}
+		fmt.Println("  (use \"yag rebase --skip\" to skip this patch)")
+		fmt.Println("  (use \"yag rebase --abort\" to check out the original branch)")
+	}
 
 	// Print staged files
 	if len(status.Staged) > 0 {
@@ -48,7 +110,7 @@ func StatusCommand(args []string) error {
 		sort.Strings(stagedFiles)
 
 		for _, file := range stagedFiles {
-			fmt.Printf("\tmodified: %s\n", file)
+			fmt.Printf("\t%-9s %s\n", string(status.Staged[file])+":", file)
 		}
 	}
 
@@ -56,6 +118,7 @@ func StatusCommand(args []string) error {
 	if len(status.Unstaged) > 0 {
 		fmt.Println("\nChanges not staged for commit:")
 		fmt.Println("  (use \"yag add <file>...\" to update what will be committed)")
+		fmt.Println("  (use \"yag restore <file>...\" to discard changes in working directory)")
 		fmt.Println()
 
 		// Sort the files for consistent output
@@ -66,7 +129,26 @@ func StatusCommand(args []string) error {
 		sort.Strings(unstagedFiles)
 
 		for _, file := range unstagedFiles {
-			fmt.Printf("\tmodified: %s\n", file)
+			fmt.Printf("\t%-9s %s\n", string(status.Unstaged[file])+":", file)
+		}
+	}
+
+	// Print unmerged files
+	if len(status.Unmerged) > 0 {
+		fmt.Println("\nUnmerged paths:")
+		fmt.Println("  (use \"yag add <file>...\" to mark resolution)")
+		fmt.Println("  (use \"yag checkout --ours|--theirs <file>...\" to pick one side)")
+		fmt.Println()
+
+		// Sort the files for consistent output
+		unmergedFiles := make([]string, 0, len(status.Unmerged))
+		for file := range status.Unmerged {
+			unmergedFiles = append(unmergedFiles, file)
+		}
+		sort.Strings(unmergedFiles)
+
+		for _, file := range unmergedFiles {
+			fmt.Printf("\t%-16s %s\n", string(status.Unmerged[file])+":", file)
 		}
 	}
 
@@ -89,7 +171,7 @@ func StatusCommand(args []string) error {
 	}
 
 	// If nothing to show, print a clean message
-	if len(status.Staged) == 0 && len(status.Unstaged) == 0 && len(status.Untracked) == 0 {
+	if status.IsClean() && !status.Merging && status.Reverting == "" && status.Picking == "" && status.PicksLeft == 0 && status.Rebasing == "" {
 		fmt.Println("\nNothing to commit, working tree clean")
 	}
 
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// TagOptions selects what TagCommand does
+type TagOptions struct {
+	List     bool   // List tags, optionally only those matching a pattern
+	Delete   bool   // Delete the named tags
+	Show     bool   // Show a tag and the object it points to
+	Annotate bool   // Create an annotated tag; requires a message
+	Force    bool   // Replace an existing tag of the same name
+	Message  string // Annotation message; setting it implies an annotated tag
+}
+
+// TagCommand creates, lists, deletes or shows tags
+// @notice "yag tag" lists tags, "yag tag <name> [<rev>]" creates one, "-d <name>..." deletes and "--show <name>" shows one
+// @param args The tag names, and for creation an optional revision
+// @param opts The operation and its options
+// @return error Returns nil on success or an error if the operation fails
+func TagCommand(args []string, opts TagOptions) error {
+	// Open the repository
+	path, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %v", err)
+	}
+
+	repo, err := repository.Open(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Delete:
+		if len(args) == 0 {
+			return fmt.Errorf("tag name required")
+		}
+		for _, name := range args {
+			info, err := repo.GetTag(name)
+			if err != nil {
+				return err
+			}
+			if err := repo.DeleteTag(name); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted tag '%s' (was %s)\n", name, info.Hash[:8])
+		}
+		return nil
+
+	case opts.Show:
+		if len(args) != 1 {
+			return fmt.Errorf("exactly one tag name required")
+		}
+		return showTag(repo, args[0])
+
+	case opts.List || len(args) == 0:
+		return listTags(repo, args)
+	}
+
+	if opts.Annotate && opts.Message == "" {
+		return fmt.Errorf("an annotated tag needs a message; use -m <message>")
+	}
+	if len(args) > 2 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	rev := ""
+	if len(args) == 2 {
+		rev = args[1]
+	}
+
+	hash, err := repo.CreateTag(args[0], rev, opts.Message, opts.Force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created tag '%s' at %s\n", args[0], hash[:8])
+	return nil
+}
+
+// listTags prints tag names, keeping only those matching one of the glob patterns if any are given
+func listTags(repo *repository.Repository, patterns []string) error {
+	tags, err := repo.ListTags()
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if len(patterns) > 0 && !matchesAny(patterns, tag) {
+			continue
+		}
+		fmt.Println(tag)
+	}
+
+	return nil
+}
+
+// matchesAny reports whether a name matches any of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// showTag prints an annotated tag's details followed by the commit it points to
+func showTag(repo *repository.Repository, name string) error {
+	info, err := repo.GetTag(name)
+	if err != nil {
+		return err
+	}
+
+	if info.Annotated() {
+		fmt.Printf("tag %s\n", info.Tag.Name())
+		fmt.Printf("Tagger: %s\n", info.Tag.Tagger())
+		fmt.Printf("Date:   %s\n", info.Tag.Timestamp().Format("Mon Jan 2 15:04:05 2006 -0700"))
+		fmt.Println()
+		fmt.Println(strings.TrimRight(info.Tag.Message(), "\n"))
+		fmt.Println()
+	}
+
+	obj, err := repo.GetStorage().GetObject(info.Target)
+	if err != nil {
+		return fmt.Errorf("failed to read object %s: %v", info.Target, err)
+	}
+
+	commit, ok := obj.(*core.Commit)
+	if !ok {
+		fmt.Printf("%s %s\n", obj.Type(), info.Target)
+		return nil
+	}
+
+	printCommit(commit)
+	return nil
+}
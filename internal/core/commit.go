@@ -9,28 +9,51 @@ import (
 
 // CommitData contains the data for a commit
 type CommitData struct {
-	TreeHash   string    // Hash of the tree this commit points to
-	ParentHash string    // Hash of the parent commit (empty for root commit)
-	Message    string    // Commit message
-	Author     string    // Author of the commit
-	Timestamp  time.Time // When the commit was created
+	TreeHash     string    // Hash of the tree this commit points to
+	ParentHashes []string  // Hashes of the parent commits in order (empty for root commit, several for a merge)
+	Message      string    // Commit message
+	Author       string    // Author of the commit
+	Timestamp    time.Time // When the commit was created
+}
+
+// storedCommitData is the superset of every commit encoding ever written
+// @dev Commits written before merge support carry a single ParentHash instead of ParentHashes
+type storedCommitData struct {
+	TreeHash     string
+	ParentHash   string
+	ParentHashes []string
+	Message      string
+	Author       string
+	Timestamp    time.Time
 }
 
 // Commit represents a commit in the repository
 type Commit struct {
 	data CommitData
 	hash string
+	raw  []byte // Serialized form the commit was loaded from, if any
 }
 
 // NewCommit creates a new Commit
 func NewCommit(treeHash, parentHash, message, author string) *Commit {
+	var parents []string
+	if parentHash != "" {
+		parents = []string{parentHash}
+	}
+
+	return NewMergeCommit(treeHash, parents, message, author)
+}
+
+// NewMergeCommit creates a new Commit with any number of parents
+// @notice The order of parents is preserved; the first parent is the branch the commit was made on
+func NewMergeCommit(treeHash string, parentHashes []string, message, author string) *Commit {
 	commit := &Commit{
 		data: CommitData{
-			TreeHash:   treeHash,
-			ParentHash: parentHash,
-			Message:    message,
-			Author:     author,
-			Timestamp:  time.Now(),
+			TreeHash:     treeHash,
+			ParentHashes: append([]string(nil), parentHashes...),
+			Message:      message,
+			Author:       author,
+			Timestamp:    time.Now(),
 		},
 	}
 
@@ -56,9 +79,22 @@ func (c *Commit) TreeHash() string {
 	return c.data.TreeHash
 }
 
-// ParentHash returns the hash of the parent commit
+// ParentHash returns the hash of the first parent commit, or an empty string for a root commit
 func (c *Commit) ParentHash() string {
-	return c.data.ParentHash
+	if len(c.data.ParentHashes) == 0 {
+		return ""
+	}
+	return c.data.ParentHashes[0]
+}
+
+// ParentHashes returns the hashes of all parent commits in order
+func (c *Commit) ParentHashes() []string {
+	return append([]string(nil), c.data.ParentHashes...)
+}
+
+// IsMerge reports whether the commit has more than one parent
+func (c *Commit) IsMerge() bool {
+	return len(c.data.ParentHashes) > 1
 }
 
 // Message returns the commit message
@@ -78,6 +114,11 @@ func (c *Commit) Timestamp() time.Time {
 
 // Serialize converts the commit to a byte slice for storage (implements Object interface)
 func (c *Commit) Serialize() ([]byte, error) {
+	// A loaded commit keeps the exact bytes it was stored as, so its hash never changes
+	if c.raw != nil {
+		return SerializeObject(CommitType, c.raw), nil
+	}
+
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
 	if err := enc.Encode(c.data); err != nil {
@@ -88,21 +129,33 @@ func (c *Commit) Serialize() ([]byte, error) {
 }
 
 // DeserializeCommit creates a Commit from serialized data
+// @dev Accepts both the current encoding and the single-parent encoding used before merge support
 func DeserializeCommit(data []byte) (*Commit, error) {
-	var commitData CommitData
+	var stored storedCommitData
 
 	dec := gob.NewDecoder(bytes.NewReader(data))
-	if err := dec.Decode(&commitData); err != nil {
+	if err := dec.Decode(&stored); err != nil {
 		return nil, fmt.Errorf("failed to decode commit: %v", err)
 	}
 
+	parents := stored.ParentHashes
+	if len(parents) == 0 && stored.ParentHash != "" {
+		parents = []string{stored.ParentHash}
+	}
+
 	commit := &Commit{
-		data: commitData,
+		data: CommitData{
+			TreeHash:     stored.TreeHash,
+			ParentHashes: parents,
+			Message:      stored.Message,
+			Author:       stored.Author,
+			Timestamp:    stored.Timestamp,
+		},
+		raw: append([]byte(nil), data...),
 	}
 
-	// Calculate hash
-	serialized, _ := commit.Serialize()
-	commit.hash = CalculateHash(serialized)
+	// The hash identifies the stored bytes, whichever encoding they use
+	commit.hash = CalculateHash(SerializeObject(CommitType, data))
 
 	return commit, nil
 }
@@ -0,0 +1,332 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiffOp represents the kind of edit applied to a line
+type DiffOp int
+
+const (
+	// DiffEqual marks a line present in both versions
+	DiffEqual DiffOp = iota
+
+	// DiffInsert marks a line only present in the new version
+	DiffInsert
+
+	// DiffDelete marks a line only present in the old version
+	DiffDelete
+)
+
+// DiffLine is a single line of a line-based diff
+// @dev Text keeps its trailing newline so a missing newline at end of file counts as a change
+type DiffLine struct {
+	Op   DiffOp // Whether the line was kept, inserted or deleted
+	Text string // The line content including its newline, if any
+}
+
+// binarySniffLen is how many leading bytes are inspected when detecting binary content
+const binarySniffLen = 8000
+
+// IsBinary reports whether content looks like binary data
+// @notice Uses the same heuristic as Git: a NUL byte near the start of the content
+// @param content The raw file content
+// @return bool True if the content should not be diffed line by line
+func IsBinary(content []byte) bool {
+	if len(content) > binarySniffLen {
+		content = content[:binarySniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// SplitLines splits content into lines, keeping each line's newline terminator
+// @param content The raw file content
+// @return []string The lines; the last one has no newline if the content does not end with one
+func SplitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// DiffBlobs computes a line-based diff between two blobs
+// @notice A nil blob is treated as empty, which is how added and deleted files are diffed
+// @param oldBlob The old version of the content, or nil
+// @param newBlob The new version of the content, or nil
+// @return []DiffLine The edit script turning the old content into the new content
+func DiffBlobs(oldBlob, newBlob *Blob) []DiffLine {
+	var oldContent, newContent []byte
+	if oldBlob != nil {
+		oldContent = oldBlob.Content()
+	}
+	if newBlob != nil {
+		newContent = newBlob.Content()
+	}
+
+	return DiffLines(SplitLines(oldContent), SplitLines(newContent))
+}
+
+// DiffLines computes the shortest edit script between two sequences of lines
+// @notice Implements Eugene Myers' O(ND) difference algorithm in its linear space variant
+// @dev Common leading and trailing lines are matched directly; the rest is split at the middle of an optimal
+// path and each half is diffed recursively, so memory stays proportional to the input even for full rewrites
+// @param a The old lines
+// @param b The new lines
+// @return []DiffLine The edit script turning a into b
+func DiffLines(a, b []string) []DiffLine {
+	lines := appendDiff(make([]DiffLine, 0, len(a)+len(b)), a, b)
+
+	// The halves of a split can interleave insertions and deletions; list each change's deletions first
+	for start := 0; start < len(lines); start++ {
+		if lines[start].Op == DiffEqual {
+			continue
+		}
+
+		end := start
+		for end < len(lines) && lines[end].Op != DiffEqual {
+			end++
+		}
+		sort.SliceStable(lines[start:end], func(i, j int) bool {
+			return lines[start+i].Op == DiffDelete && lines[start+j].Op == DiffInsert
+		})
+		start = end
+	}
+
+	return lines
+}
+
+// appendDiff appends the edit script turning a into b to lines
+func appendDiff(lines []DiffLine, a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for _, line := range a[:prefix] {
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: line})
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := middleSnake(a, b); ok {
+		lines = appendDiff(lines, a[:x], b[:y])
+		lines = appendDiff(lines, a[x:], b[y:])
+	} else {
+		// Nothing in common: everything old goes, everything new comes
+		for _, line := range a {
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: line})
+		}
+	}
+
+	for _, line := range common {
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: line})
+	}
+	return lines
+}
+
+// middleSnake finds a point on a shortest edit path that splits it into two smaller problems
+// @dev Runs the search forwards from the start and backwards from the end until the paths overlap. a and b must not
+// share a first or last line; ok is false when they have no line in common at all
+// @return x, y, ok The split point as indexes into a and b, and whether one was found
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	delta := n - m
+	// With an odd delta the paths meet while extending forwards, otherwise while extending backwards
+	front := delta%2 != 0
+
+	// forward[offset+k] is the furthest x reached from the start on diagonal k, backward[offset+k] the furthest
+	// distance from the end on the reversed diagonal k; -1 marks diagonals not reached yet
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	// Diagonals that ran off the edges are skipped from then on
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x1 int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x1 = forward[offset+k+1]
+			} else {
+				x1 = forward[offset+k-1] + 1
+			}
+			y1 := x1 - k
+
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			forward[offset+k] = x1
+
+			switch {
+			case x1 > n:
+				forwardEnd += 2
+			case y1 > m:
+				forwardStart += 2
+			case front:
+				reverse := offset + delta - k
+				if reverse >= 0 && reverse < len(backward) && backward[reverse] != -1 && x1 >= n-backward[reverse] {
+					return x1, y1, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x2 int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x2 = backward[offset+k+1]
+			} else {
+				x2 = backward[offset+k-1] + 1
+			}
+			y2 := x2 - k
+
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			backward[offset+k] = x2
+
+			switch {
+			case x2 > n:
+				backwardEnd += 2
+			case y2 > m:
+				backwardStart += 2
+			case !front:
+				k1 := delta - k
+				if k1 >= -d && k1 <= d && forward[offset+k1] != -1 && forward[offset+k1] >= n-x2 {
+					x1 := forward[offset+k1]
+					return x1, x1 - k1, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// CountChanges returns the number of inserted and deleted lines in a diff
+func CountChanges(lines []DiffLine) (insertions, deletions int) {
+	for _, line := range lines {
+		switch line.Op {
+		case DiffInsert:
+			insertions++
+		case DiffDelete:
+			deletions++
+		}
+	}
+	return insertions, deletions
+}
+
+// FormatUnified renders a diff in unified format
+// @notice Produces "---"/"+++" headers followed by "@@" hunks with the given number of context lines
+// @param oldName The name shown for the old version, e.g. "a/file.txt" or "/dev/null"
+// @param newName The name shown for the new version, e.g. "b/file.txt" or "/dev/null"
+// @param lines The edit script to render
+// @param context The number of unchanged lines to show around each change
+// @return string The unified diff, or an empty string if there are no changes
+func FormatUnified(oldName, newName string, lines []DiffLine, context int) string {
+	// Record the 0-based old/new line numbers at each position of the script
+	oldPos := make([]int, len(lines)+1)
+	newPos := make([]int, len(lines)+1)
+	var changes []int
+	for i, line := range lines {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if line.Op != DiffInsert {
+			oldPos[i+1]++
+		}
+		if line.Op != DiffDelete {
+			newPos[i+1]++
+		}
+		if line.Op != DiffEqual {
+			changes = append(changes, i)
+		}
+	}
+
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Group changes into hunks, merging those whose context overlaps
+	for i := 0; i < len(changes); {
+		start := changes[i] - context
+		if start < 0 {
+			start = 0
+		}
+
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context+1 {
+			j++
+		}
+
+		end := changes[j] + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		writeHunk(&out, lines, start, end, oldPos, newPos)
+		i = j + 1
+	}
+
+	return out.String()
+}
+
+// writeHunk renders lines[start:end] as a single unified diff hunk
+func writeHunk(out *strings.Builder, lines []DiffLine, start, end int, oldPos, newPos []int) {
+	oldStart, oldCount := oldPos[start]+1, oldPos[end]-oldPos[start]
+	newStart, newCount := newPos[start]+1, newPos[end]-newPos[start]
+
+	// An empty range is reported as starting at the line before it
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, line := range lines[start:end] {
+		prefix := " "
+		switch line.Op {
+		case DiffInsert:
+			prefix = "+"
+		case DiffDelete:
+			prefix = "-"
+		}
+
+		out.WriteString(prefix)
+		out.WriteString(line.Text)
+		if !strings.HasSuffix(line.Text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"strings"
+)
+
+// MergeHunk is a region of a three-way merge
+// @dev Stable hunks hold lines all three versions agree on; other hunks hold each side's version of a changed region
+type MergeHunk struct {
+	Base     []string // Lines from the common ancestor
+	Ours     []string // Lines from our version
+	Theirs   []string // Lines from their version
+	Resolved []string // The merged lines, when the hunk is not a conflict
+	Conflict bool     // Whether both sides changed the region differently
+}
+
+// MergeResult is the outcome of a three-way merge of file content
+type MergeResult struct {
+	Hunks []MergeHunk
+}
+
+// HasConflicts reports whether any region could not be merged automatically
+func (m *MergeResult) HasConflicts() bool {
+	for _, hunk := range m.Hunks {
+		if hunk.Conflict {
+			return true
+		}
+	}
+	return false
+}
+
+// Content returns the merged content
+// @dev Conflicting regions are rendered with our version; check HasConflicts first
+func (m *MergeResult) Content() []byte {
+	return m.ContentFavoring(FavorOurs)
+}
+
+// MergeFavor selects how conflicting regions are resolved without asking the user
+type MergeFavor int
+
+const (
+	// FavorOurs resolves conflicts with our lines
+	FavorOurs MergeFavor = iota
+
+	// FavorTheirs resolves conflicts with their lines
+	FavorTheirs
+
+	// FavorUnion resolves conflicts with our lines followed by their lines
+	FavorUnion
+)
+
+// ContentFavoring returns the merged content with every conflict resolved mechanically
+// @param favor Which side, or both, wins in conflicting regions
+// @return []byte The merged content without conflict markers
+func (m *MergeResult) ContentFavoring(favor MergeFavor) []byte {
+	var out strings.Builder
+	for _, hunk := range m.Hunks {
+		var lines []string
+		switch {
+		case !hunk.Conflict:
+			lines = hunk.Resolved
+		case favor == FavorTheirs:
+			lines = hunk.Theirs
+		case favor == FavorUnion:
+			lines = append(append([]string{}, hunk.Ours...), hunk.Theirs...)
+		default:
+			lines = hunk.Ours
+		}
+
+		// Keep lines from being glued together when a side lacks its final newline
+		if hunk.Conflict && favor == FavorUnion {
+			writeMarkedLines(&out, lines)
+			continue
+		}
+
+		for _, line := range lines {
+			out.WriteString(line)
+		}
+	}
+	return []byte(out.String())
+}
+
+// ContentWithMarkers returns the merged content with conflicting regions spelled out
+// @notice Each conflict is rendered as "<<<<<<< ours", our lines, "=======", their lines and ">>>>>>> theirs"
+// @param oursLabel The name shown after the opening marker, e.g. "HEAD"
+// @param theirsLabel The name shown after the closing marker, e.g. the branch being merged
+// @return []byte The merged content including conflict markers
+func (m *MergeResult) ContentWithMarkers(oursLabel, theirsLabel string) []byte {
+	var out strings.Builder
+	for _, hunk := range m.Hunks {
+		if !hunk.Conflict {
+			for _, line := range hunk.Resolved {
+				out.WriteString(line)
+			}
+			continue
+		}
+
+		out.WriteString("<<<<<<< " + oursLabel + "\n")
+		writeMarkedLines(&out, hunk.Ours)
+		out.WriteString("=======\n")
+		writeMarkedLines(&out, hunk.Theirs)
+		out.WriteString(">>>>>>> " + theirsLabel + "\n")
+	}
+	return []byte(out.String())
+}
+
+// writeMarkedLines writes one side of a conflict, terminating the last line so the next marker starts a line
+func writeMarkedLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+}
+
+// MergeContent performs a line-based three-way merge of file content
+// @notice Changes made on only one side are taken automatically; overlapping different changes become conflicts
+// @param base The content of the common ancestor (empty when both sides added the file)
+// @param ours Our version of the content
+// @param theirs Their version of the content
+// @return *MergeResult The merged regions
+func MergeContent(base, ours, theirs []byte) *MergeResult {
+	return MergeLines(SplitLines(base), SplitLines(ours), SplitLines(theirs))
+}
+
+// MergeLines performs a three-way merge of line sequences
+// @notice Implements the diff3 algorithm: lines unchanged on both sides anchor the merge, the regions in between are resolved one by one
+// @param base The lines of the common ancestor
+// @param ours Our version of the lines
+// @param theirs Their version of the lines
+// @return *MergeResult The merged regions
+func MergeLines(base, ours, theirs []string) *MergeResult {
+	oursMatch := matchBaseLines(base, ours)
+	theirsMatch := matchBaseLines(base, theirs)
+
+	result := &MergeResult{}
+	i, j, k := 0, 0, 0
+
+	for i < len(base) || j < len(ours) || k < len(theirs) {
+		// Lines unchanged on both sides are copied as they are
+		if i < len(base) && oursMatch[i] == j && theirsMatch[i] == k {
+			result.addStable(base[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+
+		// Find the next base line that both sides kept
+		nextI, nextJ, nextK := len(base), len(ours), len(theirs)
+		for candidate := i; candidate < len(base); candidate++ {
+			if oursMatch[candidate] >= j && theirsMatch[candidate] >= k {
+				nextI, nextJ, nextK = candidate, oursMatch[candidate], theirsMatch[candidate]
+				break
+			}
+		}
+
+		result.addChanged(base[i:nextI], ours[j:nextJ], theirs[k:nextK])
+		i, j, k = nextI, nextJ, nextK
+	}
+
+	return result
+}
+
+// addStable appends a line all three versions agree on, extending the previous stable hunk if possible
+func (m *MergeResult) addStable(line string) {
+	if n := len(m.Hunks); n > 0 && !m.Hunks[n-1].Conflict && m.Hunks[n-1].Base == nil && m.Hunks[n-1].Ours == nil {
+		m.Hunks[n-1].Resolved = append(m.Hunks[n-1].Resolved, line)
+		return
+	}
+	m.Hunks = append(m.Hunks, MergeHunk{Resolved: []string{line}})
+}
+
+// addChanged resolves a region that at least one side changed
+func (m *MergeResult) addChanged(base, ours, theirs []string) {
+	hunk := MergeHunk{
+		Base:   append([]string{}, base...),
+		Ours:   append([]string{}, ours...),
+		Theirs: append([]string{}, theirs...),
+	}
+
+	switch {
+	case linesEqual(ours, base):
+		hunk.Resolved = hunk.Theirs
+	case linesEqual(theirs, base), linesEqual(ours, theirs):
+		hunk.Resolved = hunk.Ours
+	default:
+		hunk.Conflict = true
+	}
+
+	m.Hunks = append(m.Hunks, hunk)
+}
+
+// matchBaseLines maps each base line to its index in the other version, or -1 if it was removed or changed
+func matchBaseLines(base, other []string) []int {
+	matches := make([]int, len(base))
+	i, j := 0, 0
+
+	for _, line := range DiffLines(base, other) {
+		switch line.Op {
+		case DiffEqual:
+			matches[i] = j
+			i++
+			j++
+		case DiffDelete:
+			matches[i] = -1
+			i++
+		case DiffInsert:
+			j++
+		}
+	}
+
+	return matches
+}
+
+// linesEqual reports whether two line sequences are identical
+func linesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -2,7 +2,7 @@
 // @title YAG Core Objects
 // @author XHad
 // @notice Provides the core object models and interfaces for YAG
-// @dev Contains object types like blobs, trees, and commits, along with serialization utilities
+// @dev Contains object types like blobs, trees, commits and tags, along with serialization utilities
 package core
 
 import (
@@ -27,14 +27,18 @@ const (
 	// CommitType represents a snapshot of the repository
 	// @notice Represents a point-in-time snapshot with author, message, and tree references
 	CommitType ObjectType = "commit"
+
+	// TagType represents an annotated tag
+	// @notice Names another object (usually a commit) and records who tagged it, when and why
+	TagType ObjectType = "tag"
 )
 
 // Object represents a YAG object in the object database
 // @notice Base interface implemented by all storable objects in YAG
-// @dev All core objects (Blob, Tree, Commit) must implement this interface
+// @dev All core objects (Blob, Tree, Commit, Tag) must implement this interface
 type Object interface {
 	// Type returns the type of the object
-	// @return ObjectType The type of this object (blob, tree, commit)
+	// @return ObjectType The type of this object (blob, tree, commit, tag)
 	Type() ObjectType
 
 	// ID returns the SHA-256 hash that identifies this object
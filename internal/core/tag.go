@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+)
+
+// TagData contains the data for an annotated tag
+type TagData struct {
+	Name       string     // Name of the tag, without the refs/tags/ prefix
+	TargetHash string     // Hash of the tagged object
+	TargetType ObjectType // Type of the tagged object
+	Tagger     string     // Who created the tag
+	Timestamp  time.Time  // When the tag was created
+	Message    string     // Tag message
+}
+
+// Tag represents an annotated tag
+// @notice Lightweight tags are plain refs; annotated tags are objects that a ref points to
+type Tag struct {
+	data TagData
+	hash string
+}
+
+// NewTag creates a new annotated Tag
+// @param name The tag name
+// @param targetHash The hash of the tagged object
+// @param targetType The type of the tagged object
+// @param message The tag message
+// @param tagger Who created the tag
+// @return *Tag The new tag object
+func NewTag(name, targetHash string, targetType ObjectType, message, tagger string) *Tag {
+	tag := &Tag{
+		data: TagData{
+			Name:       name,
+			TargetHash: targetHash,
+			TargetType: targetType,
+			Tagger:     tagger,
+			Timestamp:  time.Now(),
+			Message:    message,
+		},
+	}
+
+	// Calculate hash
+	data, _ := tag.Serialize()
+	tag.hash = CalculateHash(data)
+
+	return tag
+}
+
+// Type returns the type of this object (implements Object interface)
+func (t *Tag) Type() ObjectType {
+	return TagType
+}
+
+// ID returns the hash of this tag (implements Object interface)
+func (t *Tag) ID() string {
+	return t.hash
+}
+
+// Name returns the tag name
+func (t *Tag) Name() string {
+	return t.data.Name
+}
+
+// TargetHash returns the hash of the tagged object
+func (t *Tag) TargetHash() string {
+	return t.data.TargetHash
+}
+
+// TargetType returns the type of the tagged object
+func (t *Tag) TargetType() ObjectType {
+	return t.data.TargetType
+}
+
+// Tagger returns who created the tag
+func (t *Tag) Tagger() string {
+	return t.data.Tagger
+}
+
+// Timestamp returns when the tag was created
+func (t *Tag) Timestamp() time.Time {
+	return t.data.Timestamp
+}
+
+// Message returns the tag message
+func (t *Tag) Message() string {
+	return t.data.Message
+}
+
+// Serialize converts the tag to a byte slice for storage (implements Object interface)
+func (t *Tag) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(t.data); err != nil {
+		return nil, fmt.Errorf("failed to encode tag: %v", err)
+	}
+
+	return SerializeObject(TagType, buf.Bytes()), nil
+}
+
+// DeserializeTag creates a Tag from serialized data
+func DeserializeTag(data []byte) (*Tag, error) {
+	var tagData TagData
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&tagData); err != nil {
+		return nil, fmt.Errorf("failed to decode tag: %v", err)
+	}
+
+	return &Tag{
+		data: tagData,
+		hash: CalculateHash(SerializeObject(TagType, data)),
+	}, nil
+}
@@ -101,71 +101,93 @@ func (t *Tree) Serialize() ([]byte, error) {
 	return SerializeObject(TreeType, buf.Bytes()), nil
 }
 
+// DeserializeTree creates a Tree from serialized data
+func DeserializeTree(data []byte) (*Tree, error) {
+	var entries []*TreeEntry
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode tree entries: %v", err)
+	}
+
+	tree := NewTree()
+	for _, entry := range entries {
+		tree.AddEntry(entry.Name, entry.Hash, entry.Mode)
+	}
+
+	// Calculate hash
+	serialized, err := tree.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	tree.hash = CalculateHash(serialized)
+
+	return tree, nil
+}
+
 // BuildTreeFromPaths constructs a tree structure from a set of paths and their blob hashes
 func BuildTreeFromPaths(paths map[string]string) *Tree {
-	// Group files by directory
-	dirMap := make(map[string]map[string]string)
+	root, _ := BuildTreeHierarchy(paths)
+	return root
+}
+
+// BuildTreeHierarchy constructs every tree needed to represent a set of paths and their blob hashes
+// It returns the root tree along with all trees it created, ordered so that each
+// subtree appears before the tree that references it. Intermediate directories
+// that only contain other directories get a tree of their own.
+func BuildTreeHierarchy(paths map[string]string) (*Tree, []*Tree) {
+	// Group files and subdirectories by their parent directory
+	files := make(map[string]map[string]string)
+	subdirs := make(map[string]map[string]bool)
 
 	for path, hash := range paths {
 		dir, file := filepath.Split(path)
-		dir = filepath.Clean(dir)
+		dir = cleanDir(dir)
 
-		if dir == "." {
-			dir = ""
+		if _, exists := files[dir]; !exists {
+			files[dir] = make(map[string]string)
 		}
+		files[dir][file] = hash
 
-		if _, exists := dirMap[dir]; !exists {
-			dirMap[dir] = make(map[string]string)
+		// Register every ancestor so empty intermediate directories are kept
+		for d := dir; d != ""; d = cleanDir(filepath.Dir(d)) {
+			parent := cleanDir(filepath.Dir(d))
+			if _, exists := subdirs[parent]; !exists {
+				subdirs[parent] = make(map[string]bool)
+			}
+			subdirs[parent][filepath.Base(d)] = true
 		}
-
-		dirMap[dir][file] = hash
 	}
 
 	// Build trees from the bottom up
-	treeMap := make(map[string]string)
-
-	// Process directory by directory
-	var processDirs func(string) string
-	processDirs = func(dir string) string {
-		// Check if this directory was already processed
-		if hash, exists := treeMap[dir]; exists {
-			return hash
-		}
+	var trees []*Tree
 
+	var build func(string) *Tree
+	build = func(dir string) *Tree {
 		tree := NewTree()
 
-		// Add all files in this directory
-		for file, hash := range dirMap[dir] {
+		for file, hash := range files[dir] {
 			tree.AddFile(file, hash)
 		}
 
-		// Add all subdirectories
-		for otherDir := range dirMap {
-			if otherDir != dir && filepath.Dir(otherDir) == dir {
-				subTreeHash := processDirs(otherDir)
-				tree.AddDirectory(filepath.Base(otherDir), subTreeHash)
-			}
+		for name := range subdirs[dir] {
+			subTree := build(filepath.Join(dir, name))
+			tree.AddDirectory(name, subTree.ID())
 		}
 
-		// Store tree hash for reuse
-		treeMap[dir] = tree.ID()
-
-		return tree.ID()
+		trees = append(trees, tree)
+		return tree
 	}
 
-	// Start with root directory
-	rootTree := NewTree()
-	for dir := range dirMap {
-		if filepath.Dir(dir) == "." {
-			subTreeHash := processDirs(dir)
-			rootTree.AddDirectory(dir, subTreeHash)
-		}
-	}
+	root := build("")
+	return root, trees
+}
 
-	// Add root-level files
-	for file, hash := range dirMap[""] {
-		rootTree.AddFile(file, hash)
+// cleanDir normalizes a directory path so the repository root is the empty string
+func cleanDir(dir string) string {
+	dir = filepath.Clean(dir)
+	if dir == "." || dir == string(filepath.Separator) {
+		return ""
 	}
-
-	return rootTree
+	return dir
 }
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// TreeLoader loads a tree object by its hash
+// @notice Lets the core package walk stored trees without depending on a storage implementation
+type TreeLoader func(hash string) (*Tree, error)
+
+// TreeChangeType represents how an entry differs between two trees
+type TreeChangeType string
+
+const (
+	// TreeEntryAdded means the file only exists in the new tree
+	TreeEntryAdded TreeChangeType = "added"
+
+	// TreeEntryDeleted means the file only exists in the old tree
+	TreeEntryDeleted TreeChangeType = "deleted"
+
+	// TreeEntryModified means the file's content changed
+	TreeEntryModified TreeChangeType = "modified"
+
+	// TreeEntryModeChanged means only the file's mode changed
+	TreeEntryModeChanged TreeChangeType = "mode-changed"
+)
+
+// TreeChange describes a single file that differs between two trees
+type TreeChange struct {
+	Path    string         // File path relative to the root of the compared trees
+	Type    TreeChangeType // How the file changed
+	OldHash string         // Blob hash in the old tree, empty when added
+	NewHash string         // Blob hash in the new tree, empty when deleted
+	OldMode EntryMode      // Mode in the old tree, zero when added
+	NewMode EntryMode      // Mode in the new tree, zero when deleted
+}
+
+// DiffTrees compares two trees recursively and lists every file that differs
+// @notice Subtrees with identical hashes are skipped without being loaded, which is what makes Merkle trees cheap to compare
+// @dev An empty hash stands for an empty tree. A file replaced by a directory (or vice versa) is reported as a deletion plus additions
+// @param load Loads tree objects by hash
+// @param oldHash The hash of the old tree, or an empty string
+// @param newHash The hash of the new tree, or an empty string
+// @return []TreeChange, error The changed files sorted by path and nil on success, or nil and an error if a tree cannot be loaded
+func DiffTrees(load TreeLoader, oldHash, newHash string) ([]TreeChange, error) {
+	var changes []TreeChange
+	if err := diffTreesAt(load, "", oldHash, newHash, &changes); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// diffTreesAt compares two trees found at the same path and appends the differences to changes
+func diffTreesAt(load TreeLoader, prefix, oldHash, newHash string, changes *[]TreeChange) error {
+	if oldHash == newHash {
+		return nil
+	}
+
+	oldEntries, err := loadEntries(load, oldHash)
+	if err != nil {
+		return err
+	}
+
+	newEntries, err := loadEntries(load, newHash)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+
+	for name := range names {
+		path := filepath.Join(prefix, name)
+		oldEntry, newEntry := oldEntries[name], newEntries[name]
+
+		oldIsDir := oldEntry != nil && oldEntry.Mode == ModeDir
+		newIsDir := newEntry != nil && newEntry.Mode == ModeDir
+
+		// Both sides are directories: only descend when they differ
+		if oldIsDir && newIsDir {
+			if err := diffTreesAt(load, path, oldEntry.Hash, newEntry.Hash, changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Both sides are files
+		if oldEntry != nil && newEntry != nil && !oldIsDir && !newIsDir {
+			change := TreeChange{
+				Path:    path,
+				OldHash: oldEntry.Hash,
+				NewHash: newEntry.Hash,
+				OldMode: oldEntry.Mode,
+				NewMode: newEntry.Mode,
+			}
+
+			switch {
+			case oldEntry.Hash != newEntry.Hash:
+				change.Type = TreeEntryModified
+			case oldEntry.Mode != newEntry.Mode:
+				change.Type = TreeEntryModeChanged
+			default:
+				continue
+			}
+
+			*changes = append(*changes, change)
+			continue
+		}
+
+		// Anything else is a removal of the old side and an addition of the new side
+		if oldEntry != nil {
+			if err := reportEntry(load, path, oldEntry, TreeEntryDeleted, changes); err != nil {
+				return err
+			}
+		}
+		if newEntry != nil {
+			if err := reportEntry(load, path, newEntry, TreeEntryAdded, changes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// reportEntry records an added or deleted entry, expanding directories into the files they contain
+func reportEntry(load TreeLoader, path string, entry *TreeEntry, changeType TreeChangeType, changes *[]TreeChange) error {
+	if entry.Mode == ModeDir {
+		if changeType == TreeEntryAdded {
+			return diffTreesAt(load, path, "", entry.Hash, changes)
+		}
+		return diffTreesAt(load, path, entry.Hash, "", changes)
+	}
+
+	change := TreeChange{Path: path, Type: changeType}
+	if changeType == TreeEntryAdded {
+		change.NewHash, change.NewMode = entry.Hash, entry.Mode
+	} else {
+		change.OldHash, change.OldMode = entry.Hash, entry.Mode
+	}
+
+	*changes = append(*changes, change)
+	return nil
+}
+
+// loadEntries loads a tree and indexes its entries by name; an empty hash yields no entries
+func loadEntries(load TreeLoader, hash string) (map[string]*TreeEntry, error) {
+	entries := make(map[string]*TreeEntry)
+	if hash == "" {
+		return entries, nil
+	}
+
+	tree, err := load(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range tree.GetEntries() {
+		entries[entry.Name] = entry
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// DeleteBranch removes a branch
+// @notice Without force the branch must be merged into HEAD, so no commits become unreachable
+// @param name The branch name
+// @param force Whether to delete the branch even if it has commits HEAD does not contain
+// @return string, error The commit the branch pointed to and nil on success, or "" and an error if the branch is
+// missing, checked out or not fully merged
+func (r *Repository) DeleteBranch(name string, force bool) (string, error) {
+	tip, err := r.storage.GetRef(branchRef(name))
+	if err != nil {
+		return "", fmt.Errorf("branch '%s' not found", name)
+	}
+
+	current, err := r.storage.GetHead()
+	if err != nil {
+		return "", err
+	}
+	if name == current {
+		return "", fmt.Errorf("cannot delete branch '%s': it is checked out", name)
+	}
+
+	if !force {
+		merged, err := r.isMergedIntoHead(tip)
+		if err != nil {
+			return "", err
+		}
+		if !merged {
+			return "", fmt.Errorf("the branch '%s' is not fully merged; use -D to delete it anyway", name)
+		}
+	}
+
+	if err := r.storage.DeleteRef(branchRef(name)); err != nil {
+		return "", err
+	}
+
+	return tip, nil
+}
+
+// isMergedIntoHead reports whether a commit is reachable from HEAD
+func (r *Repository) isMergedIntoHead(commitHash string) (bool, error) {
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return false, err
+	}
+	if headCommit == nil {
+		return false, nil
+	}
+
+	return r.IsAncestor(commitHash, headCommit.ID())
+}
+
+// RenameBranch renames a branch, moving HEAD along if the branch is checked out
+// @param oldName The current branch name
+// @param newName The new branch name
+// @param force Whether to overwrite an existing branch called newName
+// @return error Returns nil on success or an error if the old branch is missing or the new name is invalid or taken
+func (r *Repository) RenameBranch(oldName, newName string, force bool) error {
+	tip, err := r.storage.GetRef(branchRef(oldName))
+	if err != nil {
+		return fmt.Errorf("branch '%s' not found", oldName)
+	}
+
+	if err := validateRefName("branch", newName); err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	if _, err := r.storage.GetRef(branchRef(newName)); err == nil {
+		if !force {
+			return fmt.Errorf("a branch named '%s' already exists", newName)
+		}
+		// The replaced branch's history would otherwise be mixed into the renamed one's
+		if err := r.storage.DeleteRef(branchRef(newName)); err != nil {
+			return err
+		}
+	}
+
+	current, err := r.storage.GetHead()
+	if err != nil {
+		return err
+	}
+
+	// The history moves with the branch
+	history, err := r.storage.ReadReflog(branchRef(oldName))
+	if err != nil {
+		return err
+	}
+
+	// Delete first so renaming "feature" to "feature/v2" frees the path
+	if err := r.storage.DeleteRef(branchRef(oldName)); err != nil {
+		return err
+	}
+	if err := r.storage.UpdateRef(branchRef(newName), tip); err != nil {
+		// Put the old branch back so a failed rename loses nothing
+		if restoreErr := r.restoreBranch(oldName, tip, history); restoreErr != nil {
+			return fmt.Errorf("%v; restoring '%s' also failed: %v", err, oldName, restoreErr)
+		}
+		return err
+	}
+	if err := r.appendReflog(branchRef(newName), history); err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("Branch: renamed %s to %s", branchRef(oldName), branchRef(newName))
+	if err := r.logRefUpdate(branchRef(newName), tip, tip, reason); err != nil {
+		return err
+	}
+
+	if current == oldName {
+		return r.storage.SetHead(newName)
+	}
+
+	return nil
+}
+
+// restoreBranch recreates a branch together with its reflog
+func (r *Repository) restoreBranch(name, tip string, history []storage.ReflogEntry) error {
+	if err := r.storage.UpdateRef(branchRef(name), tip); err != nil {
+		return err
+	}
+	return r.appendReflog(branchRef(name), history)
+}
+
+// appendReflog copies reflog entries to a ref
+func (r *Repository) appendReflog(name string, entries []storage.ReflogEntry) error {
+	for _, entry := range entries {
+		if err := r.storage.AppendReflog(name, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
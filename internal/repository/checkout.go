@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// Checkout switches to the specified branch
+// @notice Updates HEAD, the working directory and the index to match the branch's latest commit
+// @dev Anything that is not a branch but resolves to a commit is checked out with a detached HEAD.
+// Refuses to overwrite uncommitted local modifications unless force is set
+// @param branchName The name of the branch (or a revision) to switch to
+// @param force Whether to discard local modifications that would be overwritten
+// @return error Returns nil on success or an error if the branch is missing or local changes would be lost
+func (r *Repository) Checkout(branchName string, force bool) error {
+	// Check if branch exists; other refs such as tags are checked out detached
+	commitHash, err := r.storage.GetRef(branchRef(branchName))
+	if err != nil {
+		if !r.IsRevision(branchName) {
+			return fmt.Errorf("branch '%s' does not exist", branchName)
+		}
+		return r.CheckoutDetached(branchName, force)
+	}
+
+	// Materialize the branch's snapshot before moving HEAD
+	if err := r.switchCommit(commitHash, force); err != nil {
+		return err
+	}
+
+	// Update HEAD to point to the branch
+	return r.setHead(branchName, r.checkoutReason(branchName))
+}
+
+// CheckoutNewBranch creates a branch and switches to it
+// @notice Right after init, with no start point, HEAD is switched to the new branch before it has any commits
+// (an unborn branch), which the first commit then creates
+// @param name The branch name
+// @param startRev The commit the branch starts at; empty means HEAD
+// @param force Whether to discard local modifications that would be overwritten
+// @return error Returns nil on success or an error if the name is invalid or taken, or local changes would be lost
+func (r *Repository) CheckoutNewBranch(name, startRev string, force bool) error {
+	if err := validateRefName("branch", name); err != nil {
+		return err
+	}
+
+	if _, err := r.storage.GetRef(branchRef(name)); err == nil {
+		return fmt.Errorf("a branch named '%s' already exists", name)
+	}
+
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+	if headCommit == nil && startRev == "" {
+		return r.setHead(name, r.checkoutReason(name))
+	}
+
+	startHash, err := r.branchStart(name, startRev)
+	if err != nil {
+		return err
+	}
+
+	// Materialize the start point first so a refused checkout leaves no branch behind
+	if err := r.switchCommit(startHash, force); err != nil {
+		return err
+	}
+
+	if startRev == "" {
+		startRev = storage.HeadFile
+	}
+	if err := r.updateRef(branchRef(name), startHash, "branch: Created from "+startRev); err != nil {
+		return err
+	}
+
+	return r.setHead(name, r.checkoutReason(name))
+}
+
+// CheckoutDetached checks out a commit without being on any branch
+// @notice New commits move HEAD itself until a branch is checked out or created
+// @param rev The revision to check out; a branch name detaches at the branch's commit
+// @param force Whether to discard local modifications that would be overwritten
+// @return error Returns nil on success or an error if the revision is unknown or local changes would be lost
+func (r *Repository) CheckoutDetached(rev string, force bool) error {
+	commitHash, err := r.resolveCommit(rev)
+	if err != nil {
+		return err
+	}
+
+	if err := r.switchCommit(commitHash, force); err != nil {
+		return err
+	}
+
+	return r.detachHead(commitHash, r.checkoutReason(rev))
+}
+
+// switchCommit checks out a commit on behalf of a branch switch
+// @dev A stopped merge, revert, cherry-pick or rebase must be concluded first; a forced switch abandons a stopped
+// merge, revert or cherry-pick instead, so the next commit does not pick up its parents or message
+func (r *Repository) switchCommit(commitHash string, force bool) error {
+	if !force {
+		if err := r.requireNoOperation(); err != nil {
+			return fmt.Errorf("cannot switch branches: %v", err)
+		}
+	}
+
+	if err := r.checkoutCommit(commitHash, force); err != nil {
+		return err
+	}
+
+	if force {
+		return r.clearMergeState()
+	}
+
+	return nil
+}
+
+// checkoutCommit moves the index and working tree to a commit's snapshot without touching HEAD
+func (r *Repository) checkoutCommit(commitHash string, force bool) error {
+	if !force {
+		conflicts, err := r.storage.GetIndexConflicts()
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("you need to resolve your current index first (use 'yag merge --abort' or --force)")
+		}
+	}
+
+	commit, err := r.readCommit(commitHash)
+	if err != nil {
+		return err
+	}
+
+	targetFiles, err := r.flattenTree(commit.TreeHash())
+	if err != nil {
+		return err
+	}
+
+	return r.switchSnapshot(targetFiles, force)
+}
+
+// switchSnapshot moves the working directory and index from the HEAD snapshot to a target snapshot
+// @notice Writes changed files, deletes files the target does not track and resets the index
+// @dev Staged changes to files the target leaves untouched are carried over, like Git does
+// @param target File paths mapped to blob hashes for the snapshot being checked out
+// @param force Whether to overwrite local modifications instead of refusing
+// @return error Returns nil on success or an error listing the files whose local changes would be lost
+func (r *Repository) switchSnapshot(target map[string]string, force bool) error {
+	current, err := r.headFiles()
+	if err != nil {
+		return err
+	}
+
+	index, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	if !force {
+		conflicts, err := r.overwrittenByCheckout(current, index, target)
+		if err != nil {
+			return err
+		}
+
+		if len(conflicts) > 0 {
+			return fmt.Errorf("your local changes to the following files would be overwritten by checkout:\n\t%s\nplease commit your changes or use --force",
+				strings.Join(conflicts, "\n\t"))
+		}
+	}
+
+	// Remove files tracked by the current snapshot that the target does not have
+	for path := range current {
+		if _, inTarget := target[path]; !inTarget {
+			if err := r.removeWorkingFile(path); err != nil {
+				return fmt.Errorf("failed to remove '%s': %v", path, err)
+			}
+		}
+	}
+
+	// Write every target file whose working copy differs
+	for path, hash := range target {
+		workingHash, err := r.hashWorkingFile(path)
+		if err != nil {
+			return err
+		}
+
+		if workingHash == hash {
+			continue
+		}
+
+		// Leave carried-over local modifications alone
+		if !force && current[path] == hash {
+			continue
+		}
+
+		if err := r.writeWorkingFile(path, hash); err != nil {
+			return fmt.Errorf("failed to write '%s': %v", path, err)
+		}
+	}
+
+	// Reset the index to the target snapshot
+	newIndex := make(map[string]string, len(target))
+	for path, hash := range target {
+		newIndex[path] = hash
+	}
+
+	if !force {
+		for _, files := range []map[string]string{current, index} {
+			for path := range files {
+				if current[path] != target[path] {
+					continue
+				}
+
+				if hash, staged := index[path]; staged {
+					newIndex[path] = hash
+				} else {
+					delete(newIndex, path)
+				}
+			}
+		}
+	}
+
+	if err := r.storage.UpdateIndexEntries(newIndex); err != nil {
+		return err
+	}
+
+	// A forced switch throws away any unresolved conflicts along with the old index
+	if force {
+		return r.storage.UpdateIndexConflicts(nil)
+	}
+
+	return nil
+}
+
+// overwrittenByCheckout lists files whose uncommitted changes a checkout would overwrite
+// @dev A file is at risk when the checkout changes it and it has staged or unstaged modifications
+// @param current File paths mapped to blob hashes in the HEAD snapshot
+// @param index The current index entries
+// @param target File paths mapped to blob hashes in the snapshot being checked out
+// @return []string, error The sorted list of at-risk paths and nil on success, or nil and an error
+func (r *Repository) overwrittenByCheckout(current, index, target map[string]string) ([]string, error) {
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{current, index, target} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+
+	var conflicts []string
+	for path := range paths {
+		// Files the checkout does not change can keep their local changes
+		if current[path] == target[path] {
+			continue
+		}
+
+		workingHash, err := r.hashWorkingFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// The working copy already matches the target
+		if workingHash == target[path] {
+			continue
+		}
+
+		stagedChange := index[path] != current[path]
+		unstagedChange := workingHash != current[path]
+
+		if stagedChange || unstagedChange {
+			conflicts = append(conflicts, path)
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// PickResult describes one replayed commit
+type PickResult struct {
+	Source     string // The commit that was replayed
+	CommitHash string // The new commit, empty when the change was already in HEAD and the commit was dropped
+	Message    string // The message of the new commit
+}
+
+// CherryPickOutcome describes what a cherry-pick did
+type CherryPickOutcome struct {
+	Picks     []PickResult // The commits replayed, in order
+	Stopped   string       // The commit whose replay stopped on conflicts, or empty when every commit was replayed
+	Conflicts []string     // Paths left conflicted by the stopped commit, sorted
+}
+
+// CherryPick replays other commits onto HEAD, one new commit each
+// @notice Each commit's changes against its parent are applied with a three-way merge; the new commits keep the
+// original author and message. Commits whose changes are already in HEAD are dropped
+// @dev The working tree and index must be clean. The commits still to replay are kept in the sequencer state, so a
+// replay that stops on conflicts can be continued, skipped or aborted by later commands
+// @param revs The commits to replay, in order
+// @return *CherryPickOutcome, error What the cherry-pick did and nil on success, or nil and an error if it could not be attempted
+func (r *Repository) CherryPick(revs []string) (*CherryPickOutcome, error) {
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("no commits to cherry-pick")
+	}
+
+	todo := make([]string, 0, len(revs))
+	for _, rev := range revs {
+		hash, err := r.resolveCommit(rev)
+		if err != nil {
+			return nil, err
+		}
+
+		commit, err := r.readCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		if commit.IsMerge() {
+			return nil, fmt.Errorf("commit %s is a merge; cherry-picking merges is not supported", shortHash(hash))
+		}
+
+		todo = append(todo, hash)
+	}
+
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	if headCommit == nil {
+		return nil, fmt.Errorf("cannot cherry-pick: your current branch does not have any commits yet")
+	}
+
+	if err := r.requireNoOperation(); err != nil {
+		return nil, err
+	}
+
+	if err := r.requireCleanWorktree("cherry-pick"); err != nil {
+		return nil, err
+	}
+
+	if err := r.storage.WriteState(storage.SequencerHeadFile, headCommit.ID()); err != nil {
+		return nil, err
+	}
+	if err := r.writePickTodo(todo); err != nil {
+		return nil, err
+	}
+
+	return r.runCherryPicks(&CherryPickOutcome{})
+}
+
+// CherryPickContinue concludes the commit whose replay stopped on conflicts and replays the remaining commits
+// @notice The stopped commit is recorded with its original author and message once every conflicted path has been resolved with Add
+// @return *CherryPickOutcome, error What the cherry-pick did and nil on success, or nil and an error if no cherry-pick is in progress or conflicts remain
+func (r *Repository) CherryPickContinue() (*CherryPickOutcome, error) {
+	picking, err := r.cherryPickInProgress()
+	if err != nil {
+		return nil, err
+	}
+	if !picking {
+		return nil, fmt.Errorf("there is no cherry-pick in progress")
+	}
+
+	stopped, err := r.stoppedPick()
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &CherryPickOutcome{}
+
+	// The stopped commit may already have been concluded with "yag commit"
+	if stopped != "" {
+		message, err := r.storage.ReadState(storage.MergeMsgFile)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := r.Commit(message)
+		if err != nil {
+			return nil, err
+		}
+		outcome.Picks = append(outcome.Picks, PickResult{Source: stopped, CommitHash: hash, Message: message})
+	}
+
+	return r.runCherryPicks(outcome)
+}
+
+// CherryPickSkip drops the commit whose replay stopped on conflicts and replays the remaining commits
+// @return *CherryPickOutcome, error What the cherry-pick did and nil on success, or nil and an error if no cherry-pick is in progress
+func (r *Repository) CherryPickSkip() (*CherryPickOutcome, error) {
+	picking, err := r.cherryPickInProgress()
+	if err != nil {
+		return nil, err
+	}
+	if !picking {
+		return nil, fmt.Errorf("there is no cherry-pick in progress")
+	}
+
+	if err := r.resetToHead(); err != nil {
+		return nil, err
+	}
+	if err := r.clearMergeState(); err != nil {
+		return nil, err
+	}
+
+	return r.runCherryPicks(&CherryPickOutcome{})
+}
+
+// CherryPickAbort abandons a cherry-pick
+// @notice Moves HEAD back to where it was before the cherry-pick started and restores the index and working tree,
+// dropping the commits already replayed
+// @return error Returns nil on success or an error if no cherry-pick is in progress
+func (r *Repository) CherryPickAbort() error {
+	picking, err := r.cherryPickInProgress()
+	if err != nil {
+		return err
+	}
+	if !picking {
+		return fmt.Errorf("there is no cherry-pick to abort")
+	}
+
+	start, err := r.storage.ReadState(storage.SequencerHeadFile)
+	if err != nil {
+		return err
+	}
+
+	if start = strings.TrimSpace(start); start == "" {
+		if err := r.resetToHead(); err != nil {
+			return err
+		}
+	} else {
+		files, err := r.commitFiles(start)
+		if err != nil {
+			return err
+		}
+		if err := r.resetWorktree(files); err != nil {
+			return err
+		}
+		if err := r.updateRef(storage.HeadFile, start, "cherry-pick: abort"); err != nil {
+			return err
+		}
+	}
+
+	if err := r.clearMergeState(); err != nil {
+		return err
+	}
+	return r.clearSequencer()
+}
+
+// runCherryPicks replays the commits left in the sequencer until one stops on conflicts or none are left
+func (r *Repository) runCherryPicks(outcome *CherryPickOutcome) (*CherryPickOutcome, error) {
+	for {
+		todo, err := r.readPickTodo()
+		if err != nil {
+			return nil, err
+		}
+		if len(todo) == 0 {
+			if err := r.clearSequencer(); err != nil {
+				return nil, err
+			}
+			return outcome, nil
+		}
+
+		source := todo[0]
+		commit, err := r.readCommit(source)
+		if err != nil {
+			return nil, err
+		}
+
+		before, err := r.parentFiles(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		after, err := r.flattenTree(commit.TreeHash())
+		if err != nil {
+			return nil, err
+		}
+
+		replay, err := r.replayChange(before, after, pickLabel(source, commit), "cherry-pick")
+		if err != nil {
+			return nil, err
+		}
+
+		if len(replay.merged.conflicts) > 0 {
+			err := r.stopReplay(replay, map[string]string{
+				storage.CherryPickHeadFile: source,
+				storage.MergeMsgFile:       commit.Message(),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if err := r.writePickTodo(todo[1:]); err != nil {
+				return nil, err
+			}
+
+			outcome.Stopped = source
+			outcome.Conflicts = replay.merged.paths()
+			return outcome, nil
+		}
+
+		hash, err := r.commitReplay(replay, commit.Message(), commit.Author(), "cherry-pick: "+subject(commit.Message()), "cherry-pick")
+		if err != nil {
+			return nil, err
+		}
+		outcome.Picks = append(outcome.Picks, PickResult{Source: source, CommitHash: hash, Message: commit.Message()})
+
+		if err := r.writePickTodo(todo[1:]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cherryPickInProgress reports whether a cherry-pick has commits left to replay or stopped on conflicts
+// @dev The sequencer's start commit alone does not count: it is left behind once the last pick is concluded by other means
+func (r *Repository) cherryPickInProgress() (bool, error) {
+	stopped, err := r.stoppedPick()
+	if err != nil {
+		return false, err
+	}
+	if stopped != "" {
+		return true, nil
+	}
+
+	todo, err := r.readPickTodo()
+	if err != nil {
+		return false, err
+	}
+	return len(todo) > 0, nil
+}
+
+// stoppedPick returns the commit a cherry-pick stopped on, or an empty string when no replayed commit waits to be concluded
+func (r *Repository) stoppedPick() (string, error) {
+	picked, err := r.storage.ReadState(storage.CherryPickHeadFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(picked), nil
+}
+
+// readPickTodo lists the commits a cherry-pick still has to replay
+func (r *Repository) readPickTodo() ([]string, error) {
+	content, err := r.storage.ReadState(storage.SequencerTodoFile)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(content), nil
+}
+
+// writePickTodo stores the commits a cherry-pick still has to replay
+func (r *Repository) writePickTodo(todo []string) error {
+	if len(todo) == 0 {
+		return r.storage.RemoveState(storage.SequencerTodoFile)
+	}
+	return r.storage.WriteState(storage.SequencerTodoFile, strings.Join(todo, "\n")+"\n")
+}
+
+// clearFinishedSequencer forgets a cherry-pick that has no commits left to replay
+func (r *Repository) clearFinishedSequencer() error {
+	todo, err := r.readPickTodo()
+	if err != nil {
+		return err
+	}
+	if len(todo) > 0 {
+		return nil
+	}
+	return r.clearSequencer()
+}
+
+// clearSequencer forgets the commits of a cherry-pick
+func (r *Repository) clearSequencer() error {
+	for _, name := range []string{storage.SequencerTodoFile, storage.SequencerHeadFile} {
+		if err := r.storage.RemoveState(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/xhad/yag/internal/core"
+)
+
+// DiffOptions selects the two snapshots that Diff compares
+// @notice With no revisions, the working tree is compared with the index, or the index with HEAD when Staged is set
+type DiffOptions struct {
+	Staged bool     // Compare the index instead of the working tree
+	From   string   // Revision for the old side; replaces the index (or HEAD when Staged)
+	To     string   // Revision for the new side; replaces the working tree (or index when Staged)
+	Paths  []string // Only compare files matching these pathspecs
+}
+
+// FileDiff describes the change to a single file between two snapshots
+type FileDiff struct {
+	Path       string          // File path relative to the repository root
+	Change     FileChange      // Whether the file was added, modified or deleted
+	OldHash    string          // Blob hash of the old version, empty when added
+	NewHash    string          // Blob hash of the new version, empty when deleted
+	OldContent []byte          // Content of the old version
+	NewContent []byte          // Content of the new version
+	Binary     bool            // Whether either version is binary; Lines is empty when set
+	Lines      []core.DiffLine // The line-based edit script
+}
+
+// Stats returns the number of inserted and deleted lines in the file
+func (d *FileDiff) Stats() (insertions, deletions int) {
+	return core.CountChanges(d.Lines)
+}
+
+// snapshot is a set of files with a way to read their content
+// @dev Stored snapshots read blobs from the object store, the working tree reads files from disk
+type snapshot struct {
+	files map[string]string
+	load  func(path, hash string) ([]byte, error)
+}
+
+// Diff compares two snapshots of the repository file by file
+// @notice Supports working tree vs index, index vs HEAD (Staged) and revision vs revision comparisons
+// @param opts The snapshots to compare and an optional path filter
+// @return []*FileDiff, error The changed files sorted by path and nil on success, or nil and an error on failure
+func (r *Repository) Diff(opts DiffOptions) ([]*FileDiff, error) {
+	indexEntries, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	// Pick the old side
+	var oldSide *snapshot
+	switch {
+	case opts.From != "":
+		oldSide, err = r.revisionSnapshot(opts.From)
+	case opts.Staged:
+		oldSide, err = r.revisionSnapshot("")
+	default:
+		oldSide = r.storedSnapshot(indexEntries)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Pick the new side
+	var newSide *snapshot
+	switch {
+	case opts.To != "":
+		newSide, err = r.revisionSnapshot(opts.To)
+	case opts.Staged:
+		newSide = r.storedSnapshot(indexEntries)
+	default:
+		newSide, err = r.worktreeSnapshot(oldSide.files, indexEntries)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := r.parsePathspecs(opts.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffSnapshots(oldSide, newSide, specs)
+}
+
+// DiffTrees compares two stored trees recursively
+// @notice Lists added, deleted, modified and mode-changed files without descending into identical subtrees
+// @param oldTreeHash The hash of the old tree, or an empty string for an empty tree
+// @param newTreeHash The hash of the new tree, or an empty string for an empty tree
+// @return []core.TreeChange, error The changed files sorted by path and nil on success, or nil and an error on failure
+func (r *Repository) DiffTrees(oldTreeHash, newTreeHash string) ([]core.TreeChange, error) {
+	return core.DiffTrees(r.readTree, oldTreeHash, newTreeHash)
+}
+
+// diffSnapshots builds a FileDiff for every file that differs between two snapshots
+func diffSnapshots(oldSide, newSide *snapshot, specs []pathspec) ([]*FileDiff, error) {
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{oldSide.files, newSide.files} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+
+	var diffs []*FileDiff
+	for path := range paths {
+		oldHash, newHash := oldSide.files[path], newSide.files[path]
+		if oldHash == newHash || !selectedBy(specs, path) {
+			continue
+		}
+
+		diff := &FileDiff{Path: path, OldHash: oldHash, NewHash: newHash}
+		switch {
+		case oldHash == "":
+			diff.Change = ChangeAdded
+		case newHash == "":
+			diff.Change = ChangeDeleted
+		default:
+			diff.Change = ChangeModified
+		}
+
+		var err error
+		if oldHash != "" {
+			if diff.OldContent, err = oldSide.load(path, oldHash); err != nil {
+				return nil, err
+			}
+		}
+		if newHash != "" {
+			if diff.NewContent, err = newSide.load(path, newHash); err != nil {
+				return nil, err
+			}
+		}
+
+		diff.Binary = core.IsBinary(diff.OldContent) || core.IsBinary(diff.NewContent)
+		if !diff.Binary {
+			diff.Lines = core.DiffLines(core.SplitLines(diff.OldContent), core.SplitLines(diff.NewContent))
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Path < diffs[j].Path
+	})
+
+	return diffs, nil
+}
+
+// selectedBy reports whether a path matches any of the pathspecs, or whether there are no pathspecs at all
+func selectedBy(specs []pathspec, path string) bool {
+	if len(specs) == 0 {
+		return true
+	}
+
+	for _, spec := range specs {
+		if spec.matches(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storedSnapshot wraps a set of blob hashes whose content lives in the object store
+func (r *Repository) storedSnapshot(files map[string]string) *snapshot {
+	return &snapshot{
+		files: files,
+		load: func(path, hash string) ([]byte, error) {
+			blob, err := r.readBlob(hash)
+			if err != nil {
+				return nil, err
+			}
+			return blob.Content(), nil
+		},
+	}
+}
+
+// revisionSnapshot returns the files of a commit; an empty revision means HEAD, which may not exist yet
+func (r *Repository) revisionSnapshot(rev string) (*snapshot, error) {
+	if rev == "" {
+		files, err := r.headFiles()
+		if err != nil {
+			return nil, err
+		}
+		return r.storedSnapshot(files), nil
+	}
+
+	commitHash, err := r.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.readCommit(commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := r.flattenTree(commit.TreeHash())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.storedSnapshot(files), nil
+}
+
+// worktreeSnapshot hashes the tracked files in the working tree
+// @dev Only files known to the old side or the index are considered, so untracked files are not diffed
+func (r *Repository) worktreeSnapshot(oldFiles, indexEntries map[string]string) (*snapshot, error) {
+	files := make(map[string]string)
+
+	for _, tracked := range []map[string]string{oldFiles, indexEntries} {
+		for path := range tracked {
+			if _, done := files[path]; done {
+				continue
+			}
+
+			hash, err := r.hashWorkingFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if hash != "" {
+				files[path] = hash
+			}
+		}
+	}
+
+	return &snapshot{
+		files: files,
+		load: func(path, hash string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(r.path, path))
+		},
+	}, nil
+}
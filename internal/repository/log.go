@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/xhad/yag/internal/core"
+)
+
+// LogOptions controls which commits Log returns
+// @notice Zero values disable the corresponding filter
+type LogOptions struct {
+	Start  string    // Revision to start walking from; empty means HEAD
+	Limit  int       // Maximum number of commits to return; 0 means no limit
+	Since  time.Time // Only commits created at or after this time
+	Until  time.Time // Only commits created at or before this time
+	Author string    // Only commits whose author contains this string
+	Paths  []string  // Only commits that changed files matching these pathspecs
+}
+
+// LogIterator walks the commit history from a starting commit towards the root
+// @notice Returned by Repository.Log; call Next until it returns a nil commit
+// @dev Commits are visited newest first, and each commit is visited at most once
+type LogIterator struct {
+	repo    *Repository
+	opts    LogOptions
+	specs   []pathspec
+	pending []*core.Commit  // Commits discovered but not yet visited
+	seen    map[string]bool // Hashes of commits already queued
+	count   int             // Number of commits returned so far
+}
+
+// Log returns an iterator over the commit history
+// @notice Walks parents from HEAD, or from opts.Start, applying the filters in opts
+// @param opts The starting point and filters for the walk
+// @return *LogIterator, error The iterator and nil on success, or nil and an error if the start cannot be resolved
+func (r *Repository) Log(opts LogOptions) (*LogIterator, error) {
+	specs, err := r.parsePathspecs(opts.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	it := &LogIterator{
+		repo:  r,
+		opts:  opts,
+		specs: specs,
+		seen:  make(map[string]bool),
+	}
+
+	start := opts.Start
+	if start == "" {
+		// An empty history is not an error, there is simply nothing to show
+		headCommit, err := r.storage.GetHeadCommit()
+		if err != nil || headCommit == nil {
+			return it, err
+		}
+		start = headCommit.ID()
+	}
+
+	startHash, err := r.resolveCommit(start)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := it.push(startHash); err != nil {
+		return nil, err
+	}
+
+	return it, nil
+}
+
+// Next returns the next commit matching the filters
+// @return *core.Commit, error The next commit, or nil when the history is exhausted, and an error if an object cannot be read
+func (it *LogIterator) Next() (*core.Commit, error) {
+	for len(it.pending) > 0 {
+		if it.opts.Limit > 0 && it.count >= it.opts.Limit {
+			return nil, nil
+		}
+
+		commit := it.pop()
+		for _, parent := range commit.ParentHashes() {
+			if err := it.push(parent); err != nil {
+				return nil, err
+			}
+		}
+
+		ok, err := it.matches(commit)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		it.count++
+		return commit, nil
+	}
+
+	return nil, nil
+}
+
+// push queues a commit for visiting unless it has been seen before
+func (it *LogIterator) push(hash string) error {
+	if hash == "" || it.seen[hash] {
+		return nil
+	}
+
+	commit, err := it.repo.readCommit(hash)
+	if err != nil {
+		return err
+	}
+
+	it.seen[hash] = true
+	it.pending = append(it.pending, commit)
+	return nil
+}
+
+// pop removes and returns the newest pending commit
+func (it *LogIterator) pop() *core.Commit {
+	newest := 0
+	for i, commit := range it.pending {
+		if commit.Timestamp().After(it.pending[newest].Timestamp()) {
+			newest = i
+		}
+	}
+
+	commit := it.pending[newest]
+	it.pending = append(it.pending[:newest], it.pending[newest+1:]...)
+	return commit
+}
+
+// matches reports whether a commit passes every filter in the options
+func (it *LogIterator) matches(commit *core.Commit) (bool, error) {
+	if !it.opts.Since.IsZero() && commit.Timestamp().Before(it.opts.Since) {
+		return false, nil
+	}
+
+	if !it.opts.Until.IsZero() && commit.Timestamp().After(it.opts.Until) {
+		return false, nil
+	}
+
+	if it.opts.Author != "" && !strings.Contains(commit.Author(), it.opts.Author) {
+		return false, nil
+	}
+
+	if len(it.specs) > 0 {
+		return it.touchesPaths(commit)
+	}
+
+	return true, nil
+}
+
+// touchesPaths reports whether a commit changed any file selected by the pathspecs
+// @dev A root commit touches every file it contains; other commits are compared with their first parent
+func (it *LogIterator) touchesPaths(commit *core.Commit) (bool, error) {
+	parentTree := ""
+	if parents := commit.ParentHashes(); len(parents) > 0 {
+		parent, err := it.repo.readCommit(parents[0])
+		if err != nil {
+			return false, err
+		}
+		parentTree = parent.TreeHash()
+	}
+
+	changes, err := it.repo.DiffTrees(parentTree, commit.TreeHash())
+	if err != nil {
+		return false, err
+	}
+
+	for _, change := range changes {
+		if selectedBy(it.specs, change.Path) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
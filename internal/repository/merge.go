@@ -0,0 +1,558 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// MergeOutcome describes what Merge did
+// @notice At most one of UpToDate and FastForward is set; otherwise a merge commit was attempted
+type MergeOutcome struct {
+	UpToDate    bool     // The other commit was already part of HEAD's history
+	FastForward bool     // HEAD was moved forward without creating a merge commit
+	CommitHash  string   // The commit HEAD points to after the merge, empty if the merge stopped on conflicts
+	Conflicts   []string // Paths that could not be merged automatically, sorted
+}
+
+// MergeOptions controls how MergeWithOptions combines files
+type MergeOptions struct {
+	Strategy string // Merge driver for files without a merge attribute, e.g. "ours", "theirs" or "union"; empty means "text"
+}
+
+// ConflictSide selects one side of a conflicted merge
+type ConflictSide int
+
+const (
+	// ConflictOurs is the version from the current branch
+	ConflictOurs ConflictSide = iota
+
+	// ConflictTheirs is the version from the branch being merged
+	ConflictTheirs
+)
+
+// mergeSettings names the two sides of a merge and how their files are combined
+type mergeSettings struct {
+	oursLabel   string // Name of our side in conflict markers
+	theirsLabel string // Name of their side in conflict markers
+	strategy    string // Driver for files without a merge attribute
+}
+
+// treeMerge is the result of merging three snapshots file by file
+type treeMerge struct {
+	files     map[string]string // Merged file paths mapped to blob hashes; conflicted paths keep our version
+	conflicts []mergeConflict   // Paths that could not be merged automatically, sorted
+}
+
+// mergeConflict is a path that could not be merged automatically
+type mergeConflict struct {
+	path    string                // File path relative to the repository root
+	stages  storage.IndexConflict // The base, ours and theirs versions
+	content []byte                // Working tree content to write, or nil to keep the file the merged snapshot has
+}
+
+// paths lists the conflicted paths in order
+func (m *treeMerge) paths() []string {
+	paths := make([]string, len(m.conflicts))
+	for i, conflict := range m.conflicts {
+		paths[i] = conflict.path
+	}
+	return paths
+}
+
+// Merge joins the history of another commit into the current branch
+// @notice Fast-forwards when HEAD is an ancestor of the other commit, otherwise performs a three-way merge and records a merge commit
+// @dev The working tree and index must be clean. A conflicted merge writes conflict markers, records the conflicting
+// versions in the index and waits for MergeContinue or MergeAbort
+// @param rev The branch or commit to merge
+// @return *MergeOutcome, error What the merge did and nil on success, or nil and an error if the merge could not be attempted
+func (r *Repository) Merge(rev string) (*MergeOutcome, error) {
+	return r.MergeWithOptions(rev, MergeOptions{})
+}
+
+// MergeWithOptions joins the history of another commit into the current branch using the given options
+// @notice Files are combined by the merge driver the attributes file assigns to them, or by the strategy's driver
+// @param rev The branch or commit to merge
+// @param opts The merge strategy
+// @return *MergeOutcome, error What the merge did and nil on success, or nil and an error if the merge could not be attempted
+func (r *Repository) MergeWithOptions(rev string, opts MergeOptions) (*MergeOutcome, error) {
+	if opts.Strategy != "" {
+		if _, err := r.mergeDriver(opts.Strategy); err != nil {
+			return nil, fmt.Errorf("unknown merge strategy '%s'", opts.Strategy)
+		}
+	}
+
+	theirs, err := r.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	theirsFiles, err := r.commitFiles(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.requireNoOperation(); err != nil {
+		return nil, err
+	}
+
+	if err := r.requireCleanWorktree("merge"); err != nil {
+		return nil, err
+	}
+
+	// Without any commits, the branch simply takes over the other history
+	if headCommit == nil {
+		return r.fastForward(theirs, theirsFiles, "merge "+rev+": Fast-forward")
+	}
+	ours := headCommit.ID()
+
+	base, err := r.MergeBase(ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base {
+	case "":
+		return nil, fmt.Errorf("refusing to merge unrelated histories")
+	case theirs:
+		return &MergeOutcome{UpToDate: true, CommitHash: ours}, nil
+	case ours:
+		return r.fastForward(theirs, theirsFiles, "merge "+rev+": Fast-forward")
+	}
+
+	baseFiles, err := r.commitFiles(base)
+	if err != nil {
+		return nil, err
+	}
+
+	oursFiles, err := r.flattenTree(headCommit.TreeHash())
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := r.mergeSnapshots(baseFiles, oursFiles, theirsFiles, mergeSettings{
+		oursLabel:   "HEAD",
+		theirsLabel: rev,
+		strategy:    opts.Strategy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message := r.mergeMessage(rev, theirs)
+
+	if len(merged.conflicts) > 0 {
+		if err := r.applyConflictedMerge(oursFiles, merged); err != nil {
+			return nil, err
+		}
+
+		// Remember the merge so it can be continued or aborted
+		for name, content := range map[string]string{
+			storage.MergeHeadFile: theirs,
+			storage.MergeMsgFile:  message,
+			storage.OrigHeadFile:  ours,
+		} {
+			if err := r.storage.WriteState(name, content); err != nil {
+				return nil, err
+			}
+		}
+
+		return &MergeOutcome{Conflicts: merged.paths()}, nil
+	}
+
+	if err := r.checkUntrackedOverwritten(oursFiles, merged.files, "merge"); err != nil {
+		return nil, err
+	}
+
+	treeHash, err := r.writeTree(merged.files)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.commitTree(treeHash, []string{ours, theirs}, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.switchSnapshot(merged.files, false); err != nil {
+		return nil, err
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = "three-way"
+	}
+	if err := r.advanceHead(commit.ID(), fmt.Sprintf("merge %s: Merge made by the '%s' strategy.", rev, strategy)); err != nil {
+		return nil, err
+	}
+
+	return &MergeOutcome{CommitHash: commit.ID()}, nil
+}
+
+// MergeContinue concludes a merge that stopped on conflicts
+// @notice Records a merge commit once every conflicted path has been resolved with Add
+// @return string, error The hash of the merge commit and nil on success, or an empty string and an error if no merge is in progress or conflicts remain
+func (r *Repository) MergeContinue() (string, error) {
+	merging, err := r.mergeInProgress()
+	if err != nil {
+		return "", err
+	}
+	if !merging {
+		return "", fmt.Errorf("there is no merge in progress")
+	}
+
+	message, err := r.storage.ReadState(storage.MergeMsgFile)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Commit(message)
+}
+
+// MergeAbort abandons a merge that stopped on conflicts
+// @notice Restores the index and working tree to the HEAD commit and forgets the merge
+// @return error Returns nil on success or an error if no merge is in progress
+func (r *Repository) MergeAbort() error {
+	merging, err := r.mergeInProgress()
+	if err != nil {
+		return err
+	}
+	if !merging {
+		return fmt.Errorf("there is no merge to abort")
+	}
+
+	if err := r.resetToHead(); err != nil {
+		return err
+	}
+
+	return r.clearMergeState()
+}
+
+// CheckoutConflictSide writes one side's version of conflicted files into the working tree
+// @notice The files stay unmerged until they are added, so the choice can still be revised
+// @param pathspecs File paths, directories or glob patterns selecting conflicted files
+// @param side Whether to take our version or theirs
+// @return []string, error The sorted list of checked out paths and nil on success, or nil and an error if a path is not conflicted or lacks that version
+func (r *Repository) CheckoutConflictSide(pathspecs []string, side ConflictSide) ([]string, error) {
+	specs, err := r.parsePathspecs(pathspecs)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index conflicts: %v", err)
+	}
+
+	candidates := make(map[string]string, len(conflicts))
+	for path := range conflicts {
+		candidates[path] = path
+	}
+
+	selected, err := matchPathspecs(specs, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(selected))
+	for path := range selected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		hash, name := conflicts[path].Ours, "our"
+		if side == ConflictTheirs {
+			hash, name = conflicts[path].Theirs, "their"
+		}
+
+		if hash == "" {
+			return nil, fmt.Errorf("path '%s' does not have %s version", path, name)
+		}
+
+		if err := r.writeWorkingFile(path, hash); err != nil {
+			return nil, fmt.Errorf("failed to write '%s': %v", path, err)
+		}
+	}
+
+	return paths, nil
+}
+
+// applyConflictedMerge writes a merge with conflicts into the working tree and index
+// @dev Cleanly merged files are checked out as usual, conflicted files get markers and their versions are recorded in the index
+func (r *Repository) applyConflictedMerge(oursFiles map[string]string, merged *treeMerge) error {
+	// Files written for the conflicts must not clobber untracked work either
+	written := make(map[string]string, len(merged.files))
+	for path, hash := range merged.files {
+		written[path] = hash
+	}
+	for _, conflict := range merged.conflicts {
+		if _, ok := written[conflict.path]; !ok {
+			written[conflict.path] = conflict.stages.Theirs
+		}
+	}
+
+	if err := r.checkUntrackedOverwritten(oursFiles, written, "merge"); err != nil {
+		return err
+	}
+
+	if err := r.switchSnapshot(merged.files, false); err != nil {
+		return err
+	}
+
+	conflicts := make(map[string]storage.IndexConflict, len(merged.conflicts))
+	for _, conflict := range merged.conflicts {
+		if conflict.content != nil {
+			if err := r.writeWorkingContent(conflict.path, conflict.content); err != nil {
+				return fmt.Errorf("failed to write '%s': %v", conflict.path, err)
+			}
+		}
+		conflicts[conflict.path] = conflict.stages
+	}
+
+	return r.storage.UpdateIndexConflicts(conflicts)
+}
+
+// mergeInProgress reports whether a merge stopped on conflicts and has not been concluded
+func (r *Repository) mergeInProgress() (bool, error) {
+	mergeHead, err := r.storage.ReadState(storage.MergeHeadFile)
+	if err != nil {
+		return false, err
+	}
+	return mergeHead != "", nil
+}
+
+// clearMergeState forgets an in-progress merge, revert or cherry-picked commit
+// @dev The commits a cherry-pick still has to replay are kept, see clearSequencer
+func (r *Repository) clearMergeState() error {
+	for _, name := range []string{storage.MergeHeadFile, storage.RevertHeadFile, storage.CherryPickHeadFile, storage.MergeMsgFile} {
+		if err := r.storage.RemoveState(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetToHead discards every change in the index and working tree, including unresolved conflicts
+// @dev Files that are tracked only by the index are deleted from the working tree
+func (r *Repository) resetToHead() error {
+	current, err := r.headFiles()
+	if err != nil {
+		return err
+	}
+
+	return r.resetWorktree(current)
+}
+
+// fastForward moves the current branch, index and working tree to a descendant commit
+func (r *Repository) fastForward(commitHash string, files map[string]string, reason string) (*MergeOutcome, error) {
+	current, err := r.headFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.checkUntrackedOverwritten(current, files, "merge"); err != nil {
+		return nil, err
+	}
+
+	if err := r.switchSnapshot(files, false); err != nil {
+		return nil, err
+	}
+
+	if err := r.advanceHead(commitHash, reason); err != nil {
+		return nil, err
+	}
+
+	return &MergeOutcome{FastForward: true, CommitHash: commitHash}, nil
+}
+
+// mergeMessage builds the default message of a merge commit
+func (r *Repository) mergeMessage(rev, commitHash string) string {
+	if _, err := r.storage.GetRef(branchRef(rev)); err == nil {
+		return fmt.Sprintf("Merge branch '%s'", rev)
+	}
+	return fmt.Sprintf("Merge commit '%s'", commitHash)
+}
+
+// mergeSnapshots performs a three-way merge of three snapshots
+// @notice Files changed on one side only take that side's version; files changed on both sides are merged line by line
+// @dev Merged blobs are stored in the object database. Files changed on both sides go through their merge driver;
+// modify/delete pairs always become conflicts
+// @param base File paths mapped to blob hashes in the common ancestor
+// @param ours File paths mapped to blob hashes in our snapshot
+// @param theirs File paths mapped to blob hashes in their snapshot
+// @param settings The conflict marker labels and the merge strategy
+// @return *treeMerge, error The merged snapshot and its conflicts, and nil on success
+func (r *Repository) mergeSnapshots(base, ours, theirs map[string]string, settings mergeSettings) (*treeMerge, error) {
+	rules, err := r.readMergeAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{base, ours, theirs} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+
+	result := &treeMerge{files: make(map[string]string)}
+	for path := range paths {
+		b, o, t := base[path], ours[path], theirs[path]
+
+		merged := o
+		var conflict *mergeConflict
+
+		switch {
+		case o == t, t == b:
+			merged = o
+		case o == b:
+			merged = t
+		case o == "" || t == "":
+			// One side deleted the file, the other modified it: keep whichever version still exists
+			conflict = &mergeConflict{path: path}
+			if o == "" {
+				blob, err := r.readBlob(t)
+				if err != nil {
+					return nil, err
+				}
+				conflict.content = blob.Content()
+			}
+		default:
+			driver, err := r.driverFor(rules, path, settings.strategy)
+			if err != nil {
+				return nil, err
+			}
+
+			var content []byte
+			merged, content, err = r.mergeBlobs(driver, path, b, o, t, settings)
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge '%s': %v", path, err)
+			}
+			if merged == "" {
+				merged = o
+				conflict = &mergeConflict{path: path, content: content}
+			}
+		}
+
+		if merged != "" {
+			result.files[path] = merged
+		}
+		if conflict != nil {
+			conflict.stages = storage.IndexConflict{Base: b, Ours: o, Theirs: t}
+			result.conflicts = append(result.conflicts, *conflict)
+		}
+	}
+
+	sort.Slice(result.conflicts, func(i, j int) bool {
+		return result.conflicts[i].path < result.conflicts[j].path
+	})
+	return result, nil
+}
+
+// mergeBlobs merges the content of two versions of a file against their common version using a merge driver
+// @dev An empty base hash stands for a file added on both sides
+// @return string, []byte, error The blob hash of the merged content, or an empty hash and the driver's conflicted
+// content (nil to keep ours) when the versions conflict, and nil on success
+func (r *Repository) mergeBlobs(driver MergeDriver, path, baseHash, oursHash, theirsHash string, settings mergeSettings) (string, []byte, error) {
+	input := &MergeDriverInput{
+		Path:        path,
+		OursLabel:   settings.oursLabel,
+		TheirsLabel: settings.theirsLabel,
+	}
+
+	for _, version := range []struct {
+		hash    string
+		content *[]byte
+	}{
+		{baseHash, &input.Base},
+		{oursHash, &input.Ours},
+		{theirsHash, &input.Theirs},
+	} {
+		if version.hash == "" {
+			continue
+		}
+		blob, err := r.readBlob(version.hash)
+		if err != nil {
+			return "", nil, err
+		}
+		*version.content = blob.Content()
+	}
+
+	content, conflict, err := driver.Merge(input)
+	if err != nil {
+		return "", nil, err
+	}
+	if conflict {
+		return "", content, nil
+	}
+
+	blob := core.NewBlob(content)
+	if err := r.storage.StoreObject(blob); err != nil {
+		return "", nil, err
+	}
+
+	return blob.ID(), nil, nil
+}
+
+// commitFiles lists the files recorded in a commit
+func (r *Repository) commitFiles(commitHash string) (map[string]string, error) {
+	commit, err := r.readCommit(commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.flattenTree(commit.TreeHash())
+}
+
+// requireCleanWorktree refuses to continue when the index or working tree has uncommitted changes
+// @param operation The name of the operation, used in the error message
+func (r *Repository) requireCleanWorktree(operation string) error {
+	status, err := r.Status()
+	if err != nil {
+		return err
+	}
+
+	if len(status.Staged) > 0 || len(status.Unstaged) > 0 {
+		return fmt.Errorf("cannot %s: you have uncommitted changes; please commit them first", operation)
+	}
+
+	return nil
+}
+
+// checkUntrackedOverwritten refuses to continue when untracked files are in the way of files a snapshot switch would create
+// @param current File paths mapped to blob hashes in the snapshot being replaced
+// @param target File paths mapped to blob hashes in the snapshot being written
+// @param operation The name of the operation, used in the error message
+func (r *Repository) checkUntrackedOverwritten(current, target map[string]string, operation string) error {
+	var blocked []string
+	for path, hash := range target {
+		if _, tracked := current[path]; tracked {
+			continue
+		}
+
+		workingHash, err := r.hashWorkingFile(path)
+		if err != nil {
+			return err
+		}
+		if workingHash != "" && workingHash != hash {
+			blocked = append(blocked, path)
+		}
+	}
+
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	sort.Strings(blocked)
+	return fmt.Errorf("the following untracked working tree files would be overwritten by %s:\n\t%s\nplease move or remove them first",
+		operation, strings.Join(blocked, "\n\t"))
+}
@@ -0,0 +1,119 @@
+package repository
+
+// MergeBase finds the best common ancestor of two commits
+// @notice The best common ancestor is one that is not itself an ancestor of another common ancestor
+// @dev When several remain (criss-cross history) the most recent one is chosen
+// @param a The hash of the first commit
+// @param b The hash of the second commit
+// @return string, error The merge base hash, or an empty string if the histories are unrelated, and nil on success
+func (r *Repository) MergeBase(a, b string) (string, error) {
+	ancestorsOfA, err := r.ancestors(a)
+	if err != nil {
+		return "", err
+	}
+
+	// Walk back from b, stopping at the first commits shared with a
+	var candidates []string
+	seen := map[string]bool{b: true}
+	queue := []string{b}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		if ancestorsOfA[hash] {
+			candidates = append(candidates, hash)
+			continue
+		}
+
+		commit, err := r.readCommit(hash)
+		if err != nil {
+			return "", err
+		}
+		for _, parent := range commit.ParentHashes() {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	// Drop candidates that are reachable from another candidate
+	var best []string
+	for _, candidate := range candidates {
+		redundant := false
+		for _, other := range candidates {
+			if other == candidate {
+				continue
+			}
+			isAncestor, err := r.IsAncestor(candidate, other)
+			if err != nil {
+				return "", err
+			}
+			if isAncestor {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			best = append(best, candidate)
+		}
+	}
+
+	if len(best) == 0 {
+		return "", nil
+	}
+
+	// Prefer the most recent of the remaining candidates
+	newest := best[0]
+	newestCommit, err := r.readCommit(newest)
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range best[1:] {
+		commit, err := r.readCommit(candidate)
+		if err != nil {
+			return "", err
+		}
+		if commit.Timestamp().After(newestCommit.Timestamp()) {
+			newest, newestCommit = candidate, commit
+		}
+	}
+
+	return newest, nil
+}
+
+// IsAncestor reports whether one commit is reachable from another by following parents
+// @dev A commit counts as its own ancestor
+// @param ancestor The hash of the possible ancestor
+// @param descendant The hash of the commit to walk back from
+// @return bool, error True if ancestor is reachable from descendant, and nil on success
+func (r *Repository) IsAncestor(ancestor, descendant string) (bool, error) {
+	ancestors, err := r.ancestors(descendant)
+	if err != nil {
+		return false, err
+	}
+	return ancestors[ancestor], nil
+}
+
+// ancestors collects every commit reachable from a commit, including the commit itself
+func (r *Repository) ancestors(hash string) (map[string]bool, error) {
+	found := map[string]bool{hash: true}
+	queue := []string{hash}
+
+	for len(queue) > 0 {
+		commit, err := r.readCommit(queue[0])
+		if err != nil {
+			return nil, err
+		}
+		queue = queue[1:]
+
+		for _, parent := range commit.ParentHashes() {
+			if !found[parent] {
+				found[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhad/yag/internal/core"
+)
+
+// AttributesFile is the file at the repository root that assigns merge drivers to paths
+// @notice Each line holds a glob pattern followed by attributes, e.g. "package-lock.json merge=theirs"
+const AttributesFile = ".yagattributes"
+
+// DefaultMergeDriver is the driver used for files without a merge attribute
+const DefaultMergeDriver = "text"
+
+// MergeDriverInput holds the three versions of a file a merge driver combines
+type MergeDriverInput struct {
+	Path        string // File path relative to the repository root
+	Base        []byte // Content in the common ancestor, empty when both sides added the file
+	Ours        []byte // Content in the current branch
+	Theirs      []byte // Content in the branch being merged
+	OursLabel   string // Name of our side, for conflict markers
+	TheirsLabel string // Name of their side, for conflict markers
+}
+
+// MergeDriver combines two changed versions of a file against their common ancestor
+// @notice Implement this interface and register it with RegisterMergeDriver to merge file types line-based merging handles badly
+// @dev Drivers are selected per path by "merge=<name>" attributes in the attributes file. Registration only lasts as
+// long as the Repository value, so the command line knows the built-in drivers only
+type MergeDriver interface {
+	// Merge combines the versions in input
+	// @param input The base, ours and theirs versions of the file
+	// @return []byte, bool, error The merged content, whether it still has conflicts, and nil on success.
+	// Conflicted content is written to the working tree as is; nil keeps our version
+	Merge(input *MergeDriverInput) ([]byte, bool, error)
+}
+
+// textMergeDriver is the built-in line-based merge driver
+// @dev With resolve unset conflicts are written with markers, otherwise they are resolved in favor of one side or both
+type textMergeDriver struct {
+	resolve bool
+	favor   core.MergeFavor
+}
+
+// Merge performs a line-based three-way merge
+func (d *textMergeDriver) Merge(input *MergeDriverInput) ([]byte, bool, error) {
+	// Binary content cannot be merged line by line, only taken whole
+	if core.IsBinary(input.Base) || core.IsBinary(input.Ours) || core.IsBinary(input.Theirs) {
+		switch {
+		case d.resolve && d.favor == core.FavorOurs:
+			return input.Ours, false, nil
+		case d.resolve && d.favor == core.FavorTheirs:
+			return input.Theirs, false, nil
+		default:
+			return nil, true, nil
+		}
+	}
+
+	result := core.MergeContent(input.Base, input.Ours, input.Theirs)
+	if !result.HasConflicts() {
+		return result.Content(), false, nil
+	}
+
+	if d.resolve {
+		return result.ContentFavoring(d.favor), false, nil
+	}
+
+	return result.ContentWithMarkers(input.OursLabel, input.TheirsLabel), true, nil
+}
+
+// binaryMergeDriver never merges content and always reports a conflict that keeps our version
+type binaryMergeDriver struct{}
+
+// Merge reports a conflict without touching the working tree file
+func (binaryMergeDriver) Merge(input *MergeDriverInput) ([]byte, bool, error) {
+	return nil, true, nil
+}
+
+// builtinMergeDrivers returns the drivers every repository knows
+// @dev "ours" and "theirs" resolve conflicting regions in favor of one side, "union" keeps the lines of both
+func builtinMergeDrivers() map[string]MergeDriver {
+	return map[string]MergeDriver{
+		DefaultMergeDriver: &textMergeDriver{},
+		"ours":             &textMergeDriver{resolve: true, favor: core.FavorOurs},
+		"theirs":           &textMergeDriver{resolve: true, favor: core.FavorTheirs},
+		"union":            &textMergeDriver{resolve: true, favor: core.FavorUnion},
+		"binary":           binaryMergeDriver{},
+	}
+}
+
+// RegisterMergeDriver makes a merge driver available to "merge=<name>" attributes and merge strategies
+// @notice Registering a driver under a built-in name (text, ours, theirs, union, binary) replaces it
+// @param name The name attributes refer to
+// @param driver The driver implementation
+// @return error Returns nil on success or an error if the name is invalid
+func (r *Repository) RegisterMergeDriver(name string, driver MergeDriver) error {
+	if name == "" || strings.ContainsAny(name, " \t=") {
+		return fmt.Errorf("invalid merge driver name '%s'", name)
+	}
+
+	if r.mergeDrivers == nil {
+		r.mergeDrivers = builtinMergeDrivers()
+	}
+	r.mergeDrivers[name] = driver
+	return nil
+}
+
+// MergeDrivers lists the names of the available merge drivers
+func (r *Repository) MergeDrivers() []string {
+	drivers := r.mergeDrivers
+	if drivers == nil {
+		drivers = builtinMergeDrivers()
+	}
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeDriver looks up a driver by name
+func (r *Repository) mergeDriver(name string) (MergeDriver, error) {
+	if r.mergeDrivers == nil {
+		r.mergeDrivers = builtinMergeDrivers()
+	}
+
+	driver, ok := r.mergeDrivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown merge driver '%s'", name)
+	}
+	return driver, nil
+}
+
+// attributeRule assigns a merge driver to paths matching a pattern
+type attributeRule struct {
+	pattern string // Glob pattern; without a slash it matches the file name at any depth
+	driver  string // Name of the merge driver
+}
+
+// matches reports whether a repository-relative path matches the rule's pattern
+func (a attributeRule) matches(path string) bool {
+	path = filepath.ToSlash(path)
+
+	if !strings.Contains(a.pattern, "/") {
+		ok, _ := filepath.Match(a.pattern, filepath.Base(path))
+		return ok
+	}
+
+	ok, _ := filepath.Match(strings.TrimPrefix(a.pattern, "/"), path)
+	return ok
+}
+
+// readMergeAttributes parses the merge rules in the attributes file at the repository root
+// @dev Blank lines and lines starting with '#' are ignored, as are attributes other than merge=<driver>. A driver
+// that is not registered fails the whole file, before any path is merged
+// @return []attributeRule, error The rules in file order, empty if the file does not exist
+func (r *Repository) readMergeAttributes() ([]attributeRule, error) {
+	file, err := os.Open(filepath.Join(r.path, AttributesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []attributeRule
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for _, attr := range fields[1:] {
+			driver, ok := strings.CutPrefix(attr, "merge=")
+			if !ok {
+				continue
+			}
+			if _, err := filepath.Match(fields[0], ""); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid pattern '%s'", AttributesFile, lineNo, fields[0])
+			}
+			if _, err := r.mergeDriver(driver); err != nil {
+				return nil, fmt.Errorf("%s:%d: unknown merge driver '%s'; available drivers are %s",
+					AttributesFile, lineNo, driver, strings.Join(r.MergeDrivers(), ", "))
+			}
+			rules = append(rules, attributeRule{pattern: fields[0], driver: driver})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", AttributesFile, err)
+	}
+
+	return rules, nil
+}
+
+// driverFor picks the merge driver for a path
+// @dev The last matching attribute wins, like in Git; paths without one use the strategy, or the text driver
+func (r *Repository) driverFor(rules []attributeRule, path, strategy string) (MergeDriver, error) {
+	name := strategy
+	if name == "" {
+		name = DefaultMergeDriver
+	}
+
+	for _, rule := range rules {
+		if rule.matches(path) {
+			name = rule.driver
+		}
+	}
+
+	return r.mergeDriver(name)
+}
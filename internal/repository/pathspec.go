@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// pathspec is a user supplied path pattern normalized relative to the repository root
+type pathspec struct {
+	original string // The pathspec as the user typed it, used in error messages
+	pattern  string // The pathspec relative to the repository root
+}
+
+// parsePathspecs converts user supplied paths into repository-relative pathspecs
+// @dev Paths are interpreted relative to the current working directory, like file arguments
+// @param specs The paths, directories or glob patterns given on the command line
+// @return []pathspec, error The normalized pathspecs and nil on success, or nil and an error if a path is outside the repository
+func (r *Repository) parsePathspecs(specs []string) ([]pathspec, error) {
+	parsed := make([]pathspec, 0, len(specs))
+
+	for _, spec := range specs {
+		absPath, err := filepath.Abs(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+
+		relPath, err := filepath.Rel(r.path, absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get relative path: %v", err)
+		}
+
+		if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("pathspec '%s' is outside the repository", spec)
+		}
+
+		parsed = append(parsed, pathspec{original: spec, pattern: relPath})
+	}
+
+	return parsed, nil
+}
+
+// matches reports whether a repository-relative file path is selected by the pathspec
+// @dev A pathspec selects the file itself, every file below it when it names a directory, or any file matching it as a glob
+func (p pathspec) matches(path string) bool {
+	if p.pattern == "." || p.pattern == path {
+		return true
+	}
+
+	if strings.HasPrefix(path, p.pattern+string(filepath.Separator)) {
+		return true
+	}
+
+	if strings.ContainsAny(p.pattern, "*?[") {
+		if ok, _ := filepath.Match(p.pattern, path); ok {
+			return true
+		}
+
+		// A glob may also name a directory, e.g. "src/*" selects files nested below src
+		for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+			if ok, _ := filepath.Match(p.pattern, dir); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchPathspecs selects the files matching any of the given pathspecs
+// @param specs The parsed pathspecs to match
+// @param files Candidate file paths mapped to blob hashes
+// @return map[string]string, error The matching subset of files and nil on success, or nil and an error naming the first pathspec that matched nothing
+func matchPathspecs(specs []pathspec, files map[string]string) (map[string]string, error) {
+	matched := make(map[string]string)
+
+	for _, spec := range specs {
+		found := false
+		for path, hash := range files {
+			if spec.matches(path) {
+				matched[path] = hash
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("pathspec '%s' did not match any file(s) known to yag", spec.original)
+		}
+	}
+
+	return matched, nil
+}
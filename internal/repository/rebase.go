@@ -0,0 +1,815 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// Editor lets the user edit text, such as a rebase todo list or a commit message
+// @param name The state entry the text is edited in, e.g. storage.RebaseTodoFile
+// @param text The text to start from
+// @return string, error The edited text and nil on success, or an empty string and an error if editing failed
+type Editor func(name, text string) (string, error)
+
+// RebaseOptions controls how Rebase replays commits
+type RebaseOptions struct {
+	Interactive bool   // Let the user edit the todo list before anything is replayed
+	Edit        Editor // Edits the todo list and the messages of reworded and squashed commits; messages are kept as they are when nil
+}
+
+// RebaseOutcome describes what a rebase did
+type RebaseOutcome struct {
+	UpToDate  bool         // Whether the branch already contained the upstream, so nothing was replayed
+	Branch    string       // The branch being rebased, empty when HEAD is detached
+	Picks     []PickResult // The commits replayed, in order
+	Stopped   string       // The commit the rebase stopped at, for conflicts or an edit step
+	Editing   bool         // Whether the rebase stopped for an edit step rather than on conflicts
+	Conflicts []string     // Paths left conflicted by the stopped commit, sorted
+	Head      string       // The new tip once the rebase finished
+}
+
+// Rebase actions as they appear in a todo list
+const (
+	rebasePick   = "pick"   // Replay the commit
+	rebaseReword = "reword" // Replay the commit and edit its message
+	rebaseEdit   = "edit"   // Replay the commit and stop so it can be amended
+	rebaseSquash = "squash" // Meld the commit into the previous one, combining the messages
+	rebaseFixup  = "fixup"  // Meld the commit into the previous one, keeping only the previous message
+	rebaseDrop   = "drop"   // Leave the commit out
+)
+
+// rebaseActions maps every action name and abbreviation to its action
+var rebaseActions = map[string]string{
+	"p": rebasePick, rebasePick: rebasePick,
+	"r": rebaseReword, rebaseReword: rebaseReword,
+	"e": rebaseEdit, rebaseEdit: rebaseEdit,
+	"s": rebaseSquash, rebaseSquash: rebaseSquash,
+	"f": rebaseFixup, rebaseFixup: rebaseFixup,
+	"d": rebaseDrop, rebaseDrop: rebaseDrop,
+}
+
+// rebaseTodoHelp is appended to the todo list shown in the editor
+const rebaseTodoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's log message
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+# If you remove a line here THAT COMMIT WILL BE LOST.
+# However, if you remove everything, the rebase will be aborted.
+`
+
+// rebaseStep is one line of a rebase todo list
+type rebaseStep struct {
+	action string // One of the rebase actions
+	commit string // The full hash of the commit the action applies to
+}
+
+// String formats the step as it is stored between commands
+func (s rebaseStep) String() string {
+	return s.action + " " + s.commit
+}
+
+// rebaseState is what a rebase in progress remembers between commands
+type rebaseState struct {
+	headName string // The fully qualified branch being rebased, empty when HEAD was detached
+	origHead string // The tip before the rebase
+	onto     string // The commit the branch is replayed onto
+}
+
+// branch returns the short name of the branch being rebased, or an empty string when HEAD was detached
+func (s *rebaseState) branch() string {
+	return strings.TrimPrefix(s.headName, storage.RefsDir+"/"+storage.HeadsDir+"/")
+}
+
+// Rebase replays the current branch's commits onto another commit
+// @notice Every commit reachable from HEAD but not from the upstream is replayed in order with a three-way merge,
+// keeping its author and message; merge commits are left out and commits whose changes are already upstream are dropped.
+// The branch is moved to the new tip at the end, so its reflog records the tip from before the rebase
+// @dev The working tree and index must be clean. HEAD is detached while the commits are replayed; the steps left
+// are kept under rebase-merge/ so a rebase that stops can be continued, skipped or aborted by later commands
+// @param upstream The commit to replay onto
+// @param opts Whether to let the user edit the todo list first, and the editor to use
+// @return *RebaseOutcome, error What the rebase did and nil on success, or nil and an error if it could not be attempted
+func (r *Repository) Rebase(upstream string, opts RebaseOptions) (*RebaseOutcome, error) {
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	if headCommit == nil {
+		return nil, fmt.Errorf("cannot rebase: your current branch does not have any commits yet")
+	}
+
+	if err := r.requireNoOperation(); err != nil {
+		return nil, err
+	}
+
+	if err := r.requireCleanWorktree("rebase"); err != nil {
+		return nil, err
+	}
+
+	onto, err := r.resolveCommit(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := r.storage.GetHead()
+	if err != nil {
+		return nil, err
+	}
+	outcome := &RebaseOutcome{Branch: branch}
+
+	if !opts.Interactive {
+		upToDate, err := r.IsAncestor(onto, headCommit.ID())
+		if err != nil {
+			return nil, err
+		}
+		if upToDate {
+			outcome.UpToDate, outcome.Head = true, headCommit.ID()
+			return outcome, nil
+		}
+	}
+
+	commits, err := r.rebaseCommits(onto, headCommit.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]rebaseStep, len(commits))
+	for i, commit := range commits {
+		steps[i] = rebaseStep{action: rebasePick, commit: commit}
+	}
+
+	if opts.Interactive {
+		if steps, err = r.editRebaseTodo(steps, onto, headCommit.ID(), opts.Edit); err != nil {
+			return nil, err
+		}
+	}
+
+	state := &rebaseState{origHead: headCommit.ID(), onto: onto}
+	if branch != "" {
+		state.headName = branchRef(branch)
+	}
+	if err := r.writeRebaseState(state, steps); err != nil {
+		return nil, err
+	}
+
+	if err := r.checkoutCommit(onto, false); err != nil {
+		return nil, err
+	}
+	if err := r.detachHead(onto, "rebase (start): checkout "+upstream); err != nil {
+		return nil, err
+	}
+
+	return r.runRebase(state, opts.Edit, outcome)
+}
+
+// RebaseContinue resumes a rebase that stopped
+// @notice After conflicts, the resolution staged with Add is committed for the stopped commit. After an edit step,
+// staged changes are folded into the commit the rebase stopped at. The remaining steps are then replayed
+// @param edit Edits the messages of reworded and squashed commits; messages are kept as they are when nil
+// @return *RebaseOutcome, error What the rebase did and nil on success, or nil and an error if no rebase is in progress or conflicts remain
+func (r *Repository) RebaseContinue(edit Editor) (*RebaseOutcome, error) {
+	state, err := r.readRebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, fmt.Errorf("there is no rebase in progress")
+	}
+
+	status, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Unmerged) > 0 {
+		return nil, fmt.Errorf("you must edit all merge conflicts and then mark them as resolved using 'yag add'")
+	}
+	if len(status.Unstaged) > 0 {
+		return nil, fmt.Errorf("cannot continue the rebase: you have unstaged changes; add or restore them first")
+	}
+
+	outcome := &RebaseOutcome{Branch: state.branch()}
+
+	stopped, err := r.storage.ReadState(storage.RebaseStoppedFile)
+	if err != nil {
+		return nil, err
+	}
+	if stopped = strings.TrimSpace(stopped); stopped != "" {
+		step, err := r.parseRebaseStep(stopped)
+		if err != nil {
+			return nil, err
+		}
+
+		pick, err := r.concludeRebaseStep(step, edit)
+		if err != nil {
+			return nil, err
+		}
+		outcome.Picks = append(outcome.Picks, *pick)
+
+		if err := r.storage.RemoveState(storage.RebaseStoppedFile); err != nil {
+			return nil, err
+		}
+
+		// An edit step still stops once its conflicts are resolved
+		if step.action == rebaseEdit {
+			return r.stopForEdit(step, outcome)
+		}
+	}
+
+	if err := r.amendEditStep(); err != nil {
+		return nil, err
+	}
+
+	return r.runRebase(state, edit, outcome)
+}
+
+// RebaseSkip drops the commit a rebase stopped at and replays the remaining steps
+// @param edit Edits the messages of reworded and squashed commits; messages are kept as they are when nil
+// @return *RebaseOutcome, error What the rebase did and nil on success, or nil and an error if no rebase is in progress
+func (r *Repository) RebaseSkip(edit Editor) (*RebaseOutcome, error) {
+	state, err := r.readRebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, fmt.Errorf("there is no rebase in progress")
+	}
+
+	if err := r.resetToHead(); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{storage.RebaseStoppedFile, storage.RebaseAmendFile} {
+		if err := r.storage.RemoveState(name); err != nil {
+			return nil, err
+		}
+	}
+
+	outcome := &RebaseOutcome{Branch: state.branch()}
+
+	return r.runRebase(state, edit, outcome)
+}
+
+// RebaseAbort abandons a rebase
+// @notice Checks out the branch (or detached commit) the rebase started from, as it was before the rebase
+// @return error Returns nil on success or an error if no rebase is in progress
+func (r *Repository) RebaseAbort() error {
+	state, err := r.readRebaseState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("there is no rebase to abort")
+	}
+
+	files, err := r.commitFiles(state.origHead)
+	if err != nil {
+		return err
+	}
+	if err := r.resetWorktree(files); err != nil {
+		return err
+	}
+
+	// The branch itself only moves when the rebase finishes
+	if state.headName != "" {
+		if err := r.setHead(state.branch(), "rebase (abort): returning to "+state.headName); err != nil {
+			return err
+		}
+	} else if err := r.detachHead(state.origHead, "rebase (abort): returning to "+state.origHead); err != nil {
+		return err
+	}
+
+	if err := r.clearMergeState(); err != nil {
+		return err
+	}
+	return r.clearRebaseState()
+}
+
+// runRebase runs the steps left in the todo list until one stops or none are left
+func (r *Repository) runRebase(state *rebaseState, edit Editor, outcome *RebaseOutcome) (*RebaseOutcome, error) {
+	for {
+		steps, err := r.readRebaseTodo()
+		if err != nil {
+			return nil, err
+		}
+		if len(steps) == 0 {
+			return r.finishRebase(state, outcome)
+		}
+
+		step := steps[0]
+		pick, conflicts, err := r.applyRebaseStep(step, edit)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.writeRebaseTodo(steps[1:]); err != nil {
+			return nil, err
+		}
+
+		if len(conflicts) > 0 {
+			if err := r.storage.WriteState(storage.RebaseStoppedFile, step.String()); err != nil {
+				return nil, err
+			}
+
+			outcome.Stopped = step.commit
+			outcome.Conflicts = conflicts
+			return outcome, nil
+		}
+
+		if pick != nil {
+			outcome.Picks = append(outcome.Picks, *pick)
+		}
+
+		if step.action == rebaseEdit {
+			return r.stopForEdit(step, outcome)
+		}
+	}
+}
+
+// applyRebaseStep runs one step of a rebase on top of HEAD
+// @return *PickResult, []string, error The replayed commit (nil for a drop) or the conflicted paths when the step stopped, and nil on success
+func (r *Repository) applyRebaseStep(step rebaseStep, edit Editor) (*PickResult, []string, error) {
+	if step.action == rebaseDrop {
+		return nil, nil, nil
+	}
+
+	commit, err := r.readCommit(step.commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reason := fmt.Sprintf("rebase (%s): %s", step.action, subject(commit.Message()))
+
+	// A commit that already sits on HEAD is kept as it is
+	if (step.action == rebasePick || step.action == rebaseEdit) && commit.ParentHash() == headCommit.ID() {
+		if err := r.checkoutCommit(step.commit, false); err != nil {
+			return nil, nil, err
+		}
+		if err := r.updateRef(storage.HeadFile, step.commit, reason); err != nil {
+			return nil, nil, err
+		}
+		return &PickResult{Source: step.commit, CommitHash: step.commit, Message: commit.Message()}, nil, nil
+	}
+
+	before, err := r.parentFiles(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := r.flattenTree(commit.TreeHash())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replay, err := r.replayChange(before, after, pickLabel(step.commit, commit), "rebase")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(replay.merged.conflicts) > 0 {
+		if err := r.stopReplay(replay, nil); err != nil {
+			return nil, nil, err
+		}
+		return nil, replay.merged.paths(), nil
+	}
+
+	message, err := r.rebaseMessage(step, commit, headCommit, edit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hash string
+	if step.action == rebaseSquash || step.action == rebaseFixup {
+		hash, err = r.squashReplay(replay, message, reason)
+	} else {
+		hash, err = r.commitReplay(replay, message, commit.Author(), reason, "rebase")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &PickResult{Source: step.commit, CommitHash: hash, Message: message}, nil, nil
+}
+
+// concludeRebaseStep commits the resolution of a step that stopped on conflicts
+func (r *Repository) concludeRebaseStep(step rebaseStep, edit Editor) (*PickResult, error) {
+	commit, err := r.readCommit(step.commit)
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	treeHash, err := r.writeTree(index)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolving to HEAD's version leaves nothing to commit, so the commit is dropped
+	if treeHash == headCommit.TreeHash() {
+		return &PickResult{Source: step.commit}, nil
+	}
+
+	message, err := r.rebaseMessage(step, commit, headCommit, edit)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, author := []string{headCommit.ID()}, commit.Author()
+	if step.action == rebaseSquash || step.action == rebaseFixup {
+		parents, author = headCommit.ParentHashes(), headCommit.Author()
+	}
+
+	concluded, err := r.commitTreeAs(treeHash, parents, message, author)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("rebase (%s): %s", step.action, subject(commit.Message()))
+	if err := r.updateRef(storage.HeadFile, concluded.ID(), reason); err != nil {
+		return nil, err
+	}
+
+	return &PickResult{Source: step.commit, CommitHash: concluded.ID(), Message: message}, nil
+}
+
+// rebaseMessage builds the message of the commit a step records
+// @dev Squash and fixup meld into HEAD, so they start from HEAD's message
+func (r *Repository) rebaseMessage(step rebaseStep, commit, headCommit *core.Commit, edit Editor) (string, error) {
+	switch step.action {
+	case rebaseFixup:
+		return headCommit.Message(), nil
+	case rebaseSquash:
+		return editMessage(edit, headCommit.Message()+"\n\n"+commit.Message())
+	case rebaseReword:
+		return editMessage(edit, commit.Message())
+	default:
+		return commit.Message(), nil
+	}
+}
+
+// squashReplay melds a replay into HEAD: the new commit replaces HEAD, keeping its parents and author
+func (r *Repository) squashReplay(replay *changeReplay, message, reason string) (string, error) {
+	treeHash, err := r.writeTree(replay.merged.files)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.checkUntrackedOverwritten(replay.ours, replay.merged.files, "rebase"); err != nil {
+		return "", err
+	}
+
+	squashed, err := r.commitTreeAs(treeHash, replay.head.ParentHashes(), message, replay.head.Author())
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.switchSnapshot(replay.merged.files, false); err != nil {
+		return "", err
+	}
+
+	if err := r.updateRef(storage.HeadFile, squashed.ID(), reason); err != nil {
+		return "", err
+	}
+
+	return squashed.ID(), nil
+}
+
+// stopForEdit stops the rebase after an edit step, remembering the commit so staged changes can be folded into it
+func (r *Repository) stopForEdit(step rebaseStep, outcome *RebaseOutcome) (*RebaseOutcome, error) {
+	head, err := r.storage.GetRef(storage.HeadFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.storage.WriteState(storage.RebaseAmendFile, head); err != nil {
+		return nil, err
+	}
+
+	outcome.Stopped = step.commit
+	outcome.Editing = true
+	return outcome, nil
+}
+
+// amendEditStep folds changes staged while a rebase stopped for an edit step into the commit it stopped at
+func (r *Repository) amendEditStep() error {
+	amend, err := r.storage.ReadState(storage.RebaseAmendFile)
+	if err != nil {
+		return err
+	}
+	if amend = strings.TrimSpace(amend); amend == "" {
+		return nil
+	}
+
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+
+	index, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return err
+	}
+
+	treeHash, err := r.writeTree(index)
+	if err != nil {
+		return err
+	}
+
+	if treeHash != headCommit.TreeHash() {
+		// Commits made while stopped are kept; only staged changes on the stopped commit itself are folded in
+		if headCommit.ID() != amend {
+			return fmt.Errorf("you have staged changes; commit them first and then run 'yag rebase --continue' again")
+		}
+
+		amended, err := r.commitTreeAs(treeHash, headCommit.ParentHashes(), headCommit.Message(), headCommit.Author())
+		if err != nil {
+			return err
+		}
+		if err := r.updateRef(storage.HeadFile, amended.ID(), "commit (amend): "+subject(headCommit.Message())); err != nil {
+			return err
+		}
+	}
+
+	return r.storage.RemoveState(storage.RebaseAmendFile)
+}
+
+// finishRebase moves the rebased branch to the new tip and checks it out again
+func (r *Repository) finishRebase(state *rebaseState, outcome *RebaseOutcome) (*RebaseOutcome, error) {
+	head, err := r.storage.GetRef(storage.HeadFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.headName != "" {
+		if err := r.updateRef(state.headName, head, fmt.Sprintf("rebase (finish): %s onto %s", state.headName, state.onto)); err != nil {
+			return nil, err
+		}
+
+		if err := r.setHead(state.branch(), "rebase (finish): returning to "+state.headName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.clearRebaseState(); err != nil {
+		return nil, err
+	}
+
+	outcome.Head = head
+	return outcome, nil
+}
+
+// rebaseCommits lists the non-merge commits reachable from head but not from upstream, parents before children
+func (r *Repository) rebaseCommits(upstream, head string) ([]string, error) {
+	excluded, err := r.ancestors(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	type frame struct {
+		hash string
+		done bool // Whether the commit's parents have been visited
+	}
+
+	var commits []string
+	visited := make(map[string]bool)
+	merges := make(map[string]bool)
+	stack := []frame{{hash: head}}
+
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if top.done {
+			if !merges[top.hash] {
+				commits = append(commits, top.hash)
+			}
+			continue
+		}
+		if visited[top.hash] || excluded[top.hash] {
+			continue
+		}
+		visited[top.hash] = true
+
+		commit, err := r.readCommit(top.hash)
+		if err != nil {
+			return nil, err
+		}
+		merges[top.hash] = commit.IsMerge()
+
+		// Visit the first parent first, so its history comes first
+		stack = append(stack, frame{hash: top.hash, done: true})
+		parents := commit.ParentHashes()
+		for i := len(parents) - 1; i >= 0; i-- {
+			stack = append(stack, frame{hash: parents[i]})
+		}
+	}
+
+	return commits, nil
+}
+
+// editRebaseTodo lets the user edit the todo list of an interactive rebase
+func (r *Repository) editRebaseTodo(steps []rebaseStep, onto, head string, edit Editor) ([]rebaseStep, error) {
+	if edit == nil {
+		return nil, fmt.Errorf("an interactive rebase needs an editor")
+	}
+
+	var text strings.Builder
+	for _, step := range steps {
+		commit, err := r.readCommit(step.commit)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&text, "%s %s %s\n", step.action, shortHash(step.commit), subject(commit.Message()))
+	}
+	fmt.Fprintf(&text, "\n# Rebase %s..%s onto %s (%d commands)\n#", shortHash(onto), shortHash(head), shortHash(onto), len(steps))
+	text.WriteString(rebaseTodoHelp)
+
+	edited, err := edit(storage.RebaseTodoFile, text.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// The edited file is replaced by the rebase state, or must not be mistaken for it
+	if err := r.storage.RemoveState(storage.RebaseTodoFile); err != nil {
+		return nil, err
+	}
+
+	steps, err = r.parseRebaseTodo(edited)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("nothing to do")
+	}
+	if first := steps[0].action; first == rebaseSquash || first == rebaseFixup {
+		return nil, fmt.Errorf("cannot '%s' without a previous commit", first)
+	}
+
+	return steps, nil
+}
+
+// parseRebaseTodo reads a todo list, one "<action> <commit> [<subject>]" step per line
+// @dev Blank lines and lines starting with "#" are ignored; commits may be abbreviated
+func (r *Repository) parseRebaseTodo(text string) ([]rebaseStep, error) {
+	var steps []rebaseStep
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		step, err := r.parseRebaseStep(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid todo line %d: %v", i+1, err)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// parseRebaseStep reads one todo line
+func (r *Repository) parseRebaseStep(line string) (rebaseStep, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return rebaseStep{}, fmt.Errorf("missing commit in '%s'", line)
+	}
+
+	action, ok := rebaseActions[fields[0]]
+	if !ok {
+		return rebaseStep{}, fmt.Errorf("unknown action '%s'", fields[0])
+	}
+
+	commit, err := r.resolveCommit(fields[1])
+	if err != nil {
+		return rebaseStep{}, err
+	}
+
+	return rebaseStep{action: action, commit: commit}, nil
+}
+
+// rebaseInProgress reports whether a rebase has been started and not finished or aborted
+func (r *Repository) rebaseInProgress() (bool, error) {
+	state, err := r.readRebaseState()
+	return state != nil, err
+}
+
+// readRebaseState loads the state of a rebase in progress, or nil when there is none
+func (r *Repository) readRebaseState() (*rebaseState, error) {
+	values := make(map[string]string, 3)
+	for _, name := range []string{storage.RebaseHeadNameFile, storage.RebaseOrigHeadFile, storage.RebaseOntoFile} {
+		content, err := r.storage.ReadState(name)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = strings.TrimSpace(content)
+	}
+
+	if values[storage.RebaseOntoFile] == "" {
+		return nil, nil
+	}
+
+	return &rebaseState{
+		headName: values[storage.RebaseHeadNameFile],
+		origHead: values[storage.RebaseOrigHeadFile],
+		onto:     values[storage.RebaseOntoFile],
+	}, nil
+}
+
+// writeRebaseState stores the state of a new rebase and remembers the tip it started from as ORIG_HEAD
+func (r *Repository) writeRebaseState(state *rebaseState, steps []rebaseStep) error {
+	for name, content := range map[string]string{
+		storage.RebaseHeadNameFile: state.headName,
+		storage.RebaseOrigHeadFile: state.origHead,
+		storage.RebaseOntoFile:     state.onto,
+		storage.OrigHeadFile:       state.origHead,
+	} {
+		if err := r.storage.WriteState(name, content); err != nil {
+			return err
+		}
+	}
+
+	return r.writeRebaseTodo(steps)
+}
+
+// readRebaseTodo lists the steps a rebase still has to run
+func (r *Repository) readRebaseTodo() ([]rebaseStep, error) {
+	content, err := r.storage.ReadState(storage.RebaseTodoFile)
+	if err != nil {
+		return nil, err
+	}
+	return r.parseRebaseTodo(content)
+}
+
+// writeRebaseTodo stores the steps a rebase still has to run
+func (r *Repository) writeRebaseTodo(steps []rebaseStep) error {
+	var text strings.Builder
+	for _, step := range steps {
+		text.WriteString(step.String() + "\n")
+	}
+	return r.storage.WriteState(storage.RebaseTodoFile, text.String())
+}
+
+// clearRebaseState forgets a rebase
+func (r *Repository) clearRebaseState() error {
+	for _, name := range []string{
+		storage.RebaseHeadNameFile, storage.RebaseOrigHeadFile, storage.RebaseOntoFile,
+		storage.RebaseTodoFile, storage.RebaseStoppedFile, storage.RebaseAmendFile,
+	} {
+		if err := r.storage.RemoveState(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editMessage lets the user edit a commit message; comment lines are dropped and an empty message is refused
+func editMessage(edit Editor, message string) (string, error) {
+	if edit == nil {
+		return message, nil
+	}
+
+	text := message + "\n\n# Please enter the commit message for your changes. Lines starting\n" +
+		"# with '#' will be ignored, and an empty message aborts the commit.\n"
+
+	edited, err := edit(storage.CommitEditMsgFile, text)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(edited, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+
+	message = strings.TrimSpace(strings.Join(lines, "\n"))
+	if message == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+
+	return message, nil
+}
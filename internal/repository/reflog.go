@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// Reflog returns the recorded values of a ref, newest first
+// @notice Entry n is what "<ref>@{n}" resolves to
+// @param ref A branch name, a fully qualified ref, "HEAD", or empty for the current branch (HEAD when detached)
+// @return []storage.ReflogEntry, error The entries and nil on success, or nil and an error if the ref is unknown
+func (r *Repository) Reflog(ref string) ([]storage.ReflogEntry, error) {
+	name, err := r.reflogRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.storage.ReadReflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// reflogRef turns the ref part of "<ref>@{n}" into the name its reflog is stored under
+func (r *Repository) reflogRef(ref string) (string, error) {
+	switch {
+	case ref == "":
+		current, err := r.storage.GetHead()
+		if err != nil {
+			return "", err
+		}
+		if current == "" {
+			return storage.HeadFile, nil
+		}
+		return branchRef(current), nil
+	case ref == storage.HeadFile || ref == "@":
+		return storage.HeadFile, nil
+	case strings.HasPrefix(ref, storage.RefsDir+"/"):
+		return ref, nil
+	}
+
+	if _, err := r.storage.GetRef(branchRef(ref)); err == nil {
+		return branchRef(ref), nil
+	}
+	if _, err := r.storage.GetRef(storage.RefsDir + "/" + ref); err == nil {
+		return storage.RefsDir + "/" + ref, nil
+	}
+
+	return "", fmt.Errorf("unknown ref '%s'", ref)
+}
+
+// resolveReflog resolves "<ref>@{<n>}", the value a ref had n changes ago
+func (r *Repository) resolveReflog(ref string, n int) (string, error) {
+	entries, err := r.Reflog(ref)
+	if err != nil {
+		return "", err
+	}
+
+	// A ref without history still has its current value
+	if len(entries) == 0 && n == 0 {
+		if ref == "" {
+			ref = storage.HeadFile
+		}
+		return r.resolveBase(ref)
+	}
+
+	if n >= len(entries) {
+		if ref == "" {
+			ref = storage.HeadFile
+		}
+		return "", fmt.Errorf("log for '%s' only has %d entries", ref, len(entries))
+	}
+
+	if entries[n].NewHash == "" {
+		return "", fmt.Errorf("'%s@{%d}' does not point to a commit", ref, n)
+	}
+
+	return entries[n].NewHash, nil
+}
+
+// logsRef reports whether changes of a ref are recorded; tags are not expected to move
+func logsRef(name string) bool {
+	return !strings.HasPrefix(name, storage.RefsDir+"/"+storage.TagsDir+"/")
+}
+
+// logRefUpdate appends an entry to a ref's reflog
+func (r *Repository) logRefUpdate(name, oldHash, newHash, reason string) error {
+	if !logsRef(name) {
+		return nil
+	}
+
+	return r.storage.AppendReflog(name, storage.ReflogEntry{
+		OldHash:   oldHash,
+		NewHash:   newHash,
+		Identity:  currentAuthor(),
+		Timestamp: time.Now(),
+		Reason:    reason,
+	})
+}
+
+// updateRef points a ref at a hash and records the change
+// @dev Moving the checked out branch also moves HEAD, and moving HEAD on a branch moves the branch, so both reflogs get the entry
+// @param name The fully qualified ref name, or "HEAD"
+// @param hash The new value
+// @param reason Why the ref moved, shown by "yag reflog"
+// @return error Returns nil on success or an error if the update fails
+func (r *Repository) updateRef(name, hash, reason string) error {
+	// A missing ref has no old value
+	oldHash, _ := r.storage.GetRef(name)
+
+	if err := r.storage.UpdateRef(name, hash); err != nil {
+		return err
+	}
+
+	headTarget, err := r.storage.GetSymbolicRef(storage.HeadFile)
+	if err != nil {
+		return err
+	}
+
+	logged := []string{name}
+	if name == storage.HeadFile && headTarget != "" {
+		logged = append(logged, headTarget)
+	} else if name == headTarget {
+		logged = append(logged, storage.HeadFile)
+	}
+
+	for _, ref := range logged {
+		if err := r.logRefUpdate(ref, oldHash, hash, reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setHead switches HEAD to a branch and records the move in HEAD's reflog
+func (r *Repository) setHead(branch, reason string) error {
+	oldHash, _ := r.storage.GetRef(storage.HeadFile)
+
+	if err := r.storage.SetHead(branch); err != nil {
+		return err
+	}
+
+	// An unborn branch gives HEAD no value to record
+	newHash, err := r.storage.GetRef(storage.HeadFile)
+	if err != nil {
+		return nil
+	}
+
+	return r.logRefUpdate(storage.HeadFile, oldHash, newHash, reason)
+}
+
+// detachHead points HEAD at a commit and records the move in HEAD's reflog
+func (r *Repository) detachHead(commitHash, reason string) error {
+	oldHash, _ := r.storage.GetRef(storage.HeadFile)
+
+	if err := r.storage.DetachHead(commitHash); err != nil {
+		return err
+	}
+
+	return r.logRefUpdate(storage.HeadFile, oldHash, commitHash, reason)
+}
+
+// headName describes where HEAD is for reflog messages: the branch name, or the short commit hash when detached
+func (r *Repository) headName() string {
+	if current, err := r.storage.GetHead(); err == nil && current != "" {
+		return current
+	}
+	if hash, err := r.storage.GetRef(storage.HeadFile); err == nil {
+		return shortHash(hash)
+	}
+	return storage.HeadFile
+}
+
+// checkoutReason builds the reflog message of a checkout
+func (r *Repository) checkoutReason(target string) string {
+	return fmt.Sprintf("checkout: moving from %s to %s", r.headName(), target)
+}
+
+// shortHash abbreviates a hash for messages
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+// subject returns the first line of a commit message
+func subject(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return line
+}
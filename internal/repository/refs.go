@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// branchRef returns the fully qualified ref name of a branch
+func branchRef(name string) string {
+	return storage.RefsDir + "/" + storage.HeadsDir + "/" + name
+}
+
+// tagRef returns the fully qualified ref name of a tag
+func tagRef(name string) string {
+	return storage.RefsDir + "/" + storage.TagsDir + "/" + name
+}
+
+// validateRefName checks that a branch or tag name can be stored and used in revision expressions
+// @dev Follows the spirit of git check-ref-format: no whitespace, no revision syntax characters and no ".." components
+// @param kind What the name is for, used in the error message (e.g. "branch" or "tag")
+// @param name The name to check
+// @return error Returns nil if the name is valid or an error explaining why not
+func validateRefName(kind, name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("'%s' is not a valid %s name: %s", name, kind, reason)
+	}
+
+	switch {
+	case name == "":
+		return fmt.Errorf("%s name is required", kind)
+	case name == "HEAD" || name == "@":
+		return invalid("it is reserved")
+	case strings.HasPrefix(name, "-"):
+		return invalid("it cannot start with '-'")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return invalid("it cannot start or end with '/'")
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock"):
+		return invalid("it cannot end with '.' or '.lock'")
+	case strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{"):
+		return invalid("it cannot contain '..', '//' or '@{'")
+	case strings.ContainsAny(name, " \t\n~^:?*[\\"):
+		return invalid("it cannot contain whitespace or any of ~^:?*[\\")
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return invalid("no component can start with '.'")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/xhad/yag/internal/core"
+)
+
+// changeReplay is a change applied to HEAD with a three-way merge, ready to be committed or left conflicted
+type changeReplay struct {
+	head   *core.Commit      // The commit the change was applied to
+	ours   map[string]string // The files of the HEAD commit
+	merged *treeMerge        // The files of HEAD with the change applied
+}
+
+// replayChange applies the difference between two snapshots to HEAD
+// @dev The snapshot the change starts from is the merge base, so HEAD keeps its own changes to the same files
+// @param before File paths mapped to blob hashes the change starts from
+// @param after File paths mapped to blob hashes the change leads to
+// @param label The name of the change's side in conflict markers
+// @param operation The name of the operation, used in error messages
+// @return *changeReplay, error The merged snapshot and nil on success, or nil and an error if HEAD has no commits
+func (r *Repository) replayChange(before, after map[string]string, label, operation string) (*changeReplay, error) {
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	if headCommit == nil {
+		return nil, fmt.Errorf("cannot %s: your current branch does not have any commits yet", operation)
+	}
+
+	ours, err := r.flattenTree(headCommit.TreeHash())
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := r.mergeSnapshots(before, ours, after, mergeSettings{
+		oursLabel:   "HEAD",
+		theirsLabel: label,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &changeReplay{head: headCommit, ours: ours, merged: merged}, nil
+}
+
+// stopReplay leaves a conflicted replay in the working tree and index and remembers the operation
+// @param replay The replay with conflicts
+// @param state State entries mapped to their content, e.g. the commit being replayed and the message to commit
+func (r *Repository) stopReplay(replay *changeReplay, state map[string]string) error {
+	if err := r.applyConflictedMerge(replay.ours, replay.merged); err != nil {
+		return err
+	}
+
+	for name, content := range state {
+		if err := r.storage.WriteState(name, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commitReplay records a replay without conflicts as a new commit on HEAD and checks it out
+// @param replay The replay to commit
+// @param message The commit message
+// @param author The author of the new commit
+// @param reason Why HEAD moved, shown by "yag reflog"
+// @param operation The name of the operation, used in error messages
+// @return string, error The new commit hash, or an empty string when the change is already in HEAD, and nil on success
+func (r *Repository) commitReplay(replay *changeReplay, message, author, reason, operation string) (string, error) {
+	treeHash, err := r.writeTree(replay.merged.files)
+	if err != nil {
+		return "", err
+	}
+	if treeHash == replay.head.TreeHash() {
+		return "", nil
+	}
+
+	if err := r.checkUntrackedOverwritten(replay.ours, replay.merged.files, operation); err != nil {
+		return "", err
+	}
+
+	commit, err := r.commitTreeAs(treeHash, []string{replay.head.ID()}, message, author)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.switchSnapshot(replay.merged.files, false); err != nil {
+		return "", err
+	}
+
+	if err := r.advanceHead(commit.ID(), reason); err != nil {
+		return "", err
+	}
+
+	return commit.ID(), nil
+}
+
+// requireNoOperation refuses to start an operation while a merge, revert, cherry-pick or rebase waits to be concluded
+func (r *Repository) requireNoOperation() error {
+	merging, err := r.mergeInProgress()
+	if err != nil {
+		return err
+	}
+	if merging {
+		return fmt.Errorf("you have not concluded your merge; use 'yag merge --continue' or 'yag merge --abort'")
+	}
+
+	reverting, err := r.revertInProgress()
+	if err != nil {
+		return err
+	}
+	if reverting != "" {
+		return fmt.Errorf("a revert is in progress; use 'yag revert --continue' or 'yag revert --abort'")
+	}
+
+	picking, err := r.cherryPickInProgress()
+	if err != nil {
+		return err
+	}
+	if picking {
+		return fmt.Errorf("a cherry-pick is in progress; use 'yag cherry-pick --continue', '--skip' or '--abort'")
+	}
+
+	rebasing, err := r.rebaseInProgress()
+	if err != nil {
+		return err
+	}
+	if rebasing {
+		return fmt.Errorf("a rebase is in progress; use 'yag rebase --continue', '--skip' or '--abort'")
+	}
+
+	return nil
+}
+
+// parentFiles lists the files of a commit's first parent, or none for a root commit
+func (r *Repository) parentFiles(commit *core.Commit) (map[string]string, error) {
+	if commit.ParentHash() == "" {
+		return map[string]string{}, nil
+	}
+
+	return r.commitFiles(commit.ParentHash())
+}
+
+// pickLabel names a commit in conflict markers and messages: its short hash and subject
+func pickLabel(commitHash string, commit *core.Commit) string {
+	return fmt.Sprintf("%s (%s)", shortHash(commitHash), subject(commit.Message()))
+}
@@ -10,27 +10,21 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/xhad/yag/internal/core"
 
 	"github.com/xhad/yag/internal/storage"
 )
 
-// RepositoryStatus represents the status of files in the repository
-// @notice Contains the categorized status of files in the repository for status command
-// @dev Maps file paths to booleans for efficient lookups
-type RepositoryStatus struct {
-	Staged    map[string]bool // Files staged for commit
-	Unstaged  map[string]bool // Files modified but not staged
-	Untracked map[string]bool // Files not tracked by YAG
-}
-
 // Repository represents a YAG repository
 // @notice The main structure for interacting with a YAG repository
 // @dev Encapsulates storage implementation and provides high-level operations
 type Repository struct {
-	storage storage.Storage
-	path    string
+	storage      storage.Storage
+	path         string
+	mergeDrivers map[string]MergeDriver // Merge drivers by name, nil until first used
 }
 
 // Init initializes a new repository at the given path
@@ -87,13 +81,30 @@ func (r *Repository) Add(filePath string) error {
 
 	// Check if file exists
 	fi, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		// Adding a tracked path that was deleted stages its removal
+		removed, removeErr := r.removeMissingFromIndex(absPath)
+		if removeErr != nil {
+			return removeErr
+		}
+		if removed > 0 {
+			return nil
+		}
+		return err
+	}
 	if err != nil {
 		return err
 	}
 
 	// If path is a directory, add all files in the directory
 	if fi.IsDir() {
-		return r.addDirectory(absPath)
+		if err := r.addDirectory(absPath); err != nil {
+			return err
+		}
+
+		// Stage removals of tracked files deleted from the directory
+		_, err := r.removeMissingFromIndex(absPath)
+		return err
 	}
 
 	// Add a single file
@@ -120,7 +131,27 @@ func (r *Repository) addFile(absPath string) error {
 	}
 
 	// Add to index
-	return r.storage.UpdateIndex(relPath, blob.ID())
+	if err := r.storage.UpdateIndex(relPath, blob.ID()); err != nil {
+		return err
+	}
+
+	// Adding a conflicted file marks it as resolved
+	return r.markResolved(relPath)
+}
+
+// markResolved drops the conflict recorded for a path, if any
+func (r *Repository) markResolved(relPath string) error {
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return err
+	}
+
+	if _, conflicted := conflicts[relPath]; !conflicted {
+		return nil
+	}
+
+	delete(conflicts, relPath)
+	return r.storage.UpdateIndexConflicts(conflicts)
 }
 
 // addDirectory recursively adds all files in a directory
@@ -145,6 +176,64 @@ func (r *Repository) addDirectory(dir string) error {
 	})
 }
 
+// removeMissingFromIndex drops index entries at or below a path whose files no longer exist
+// @dev Conflicted paths whose files were deleted are resolved as deletions
+// @param absPath The absolute path of a file or directory
+// @return int, error The number of entries removed and nil on success, or 0 and an error on failure
+func (r *Repository) removeMissingFromIndex(absPath string) (int, error) {
+	relPath, err := filepath.Rel(r.path, absPath)
+	if err != nil {
+		return 0, err
+	}
+
+	indexEntries, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return 0, err
+	}
+
+	missing := func(path string) bool {
+		under := relPath == "." || path == relPath || strings.HasPrefix(path, relPath+string(filepath.Separator))
+		if !under {
+			return false
+		}
+		_, err := os.Stat(filepath.Join(r.path, path))
+		return os.IsNotExist(err)
+	}
+
+	removed := 0
+	for path := range indexEntries {
+		if missing(path) {
+			delete(indexEntries, path)
+			removed++
+		}
+	}
+
+	resolved := 0
+	for path := range conflicts {
+		if missing(path) {
+			delete(conflicts, path)
+			resolved++
+		}
+	}
+
+	if resolved > 0 {
+		if err := r.storage.UpdateIndexConflicts(conflicts); err != nil {
+			return 0, err
+		}
+	}
+
+	if removed == 0 {
+		return resolved, nil
+	}
+
+	return removed + resolved, r.storage.UpdateIndexEntries(indexEntries)
+}
+
 // Commit creates a new commit with the current staged files
 func (r *Repository) Commit(message string) (string, error) {
 	// Get current staged files
@@ -153,81 +242,209 @@ func (r *Repository) Commit(message string) (string, error) {
 		return "", err
 	}
 
-	if len(stagedFiles) == 0 {
-		return "", fmt.Errorf("nothing to commit, working tree clean")
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return "", err
 	}
 
-	// Build a tree from staged files
-	tree := core.BuildTreeFromPaths(stagedFiles)
+	if len(conflicts) > 0 {
+		return "", fmt.Errorf("cannot commit because you have unmerged files; fix them and use 'yag add <file>' to mark resolution")
+	}
 
-	// Store tree in object database
-	if err := r.storage.StoreObject(tree); err != nil {
+	// A concluded merge records the merged commit as second parent
+	mergeHead, err := r.storage.ReadState(storage.MergeHeadFile)
+	if err != nil {
 		return "", err
 	}
 
-	// Get parent commit hash
-	var parentHash string
+	// An empty index is only a change once there is a commit to remove files from
 	headCommit, err := r.storage.GetHeadCommit()
-	if err == nil && headCommit != nil {
-		// Get commit ID via core.Object interface to satisfy the linter
-		var obj core.Object = headCommit
-		parentHash = obj.ID()
+	if err != nil {
+		return "", err
+	}
+	if len(stagedFiles) == 0 && headCommit == nil {
+		return "", fmt.Errorf("nothing to commit, working tree clean")
 	}
 
-	// Get author information
-	currentUser, err := user.Current()
-	var author string
-	if err == nil {
-		author = currentUser.Username
-	} else {
-		author = "unknown"
+	// Build and store the tree hierarchy from staged files
+	treeHash, err := r.writeTree(stagedFiles)
+	if err != nil {
+		return "", err
 	}
 
-	// Create commit
-	commit := core.NewCommit(tree.ID(), parentHash, message, author)
+	// Get parent commit hash
+	var parents []string
+	if headCommit != nil {
+		// The index is the full snapshot, so an unchanged tree means nothing was staged
+		if headCommit.TreeHash() == treeHash && mergeHead == "" {
+			return "", fmt.Errorf("nothing to commit, working tree clean")
+		}
 
-	// Store commit in object database
-	if err := r.storage.StoreObject(commit); err != nil {
+		parents = append(parents, headCommit.ID())
+	}
+
+	if mergeHead != "" {
+		parents = append(parents, mergeHead)
+	}
+
+	// A concluded cherry-pick keeps the author of the replayed commit
+	author, reason := currentAuthor(), commitReason(parents, message)
+	picked, err := r.stoppedPick()
+	if err != nil {
 		return "", err
 	}
+	if picked != "" {
+		pickedCommit, err := r.readCommit(picked)
+		if err != nil {
+			return "", err
+		}
+		author, reason = pickedCommit.Author(), "commit (cherry-pick): "+subject(message)
+	}
 
-	// Update current branch to point to the new commit
-	head, err := r.storage.GetHead()
+	commit, err := r.commitTreeAs(treeHash, parents, message, author)
 	if err != nil {
 		return "", err
 	}
 
-	if err := r.storage.UpdateRef(head, commit.ID()); err != nil {
+	// Update current branch to point to the new commit
+	if err := r.advanceHead(commit.ID(), reason); err != nil {
 		return "", err
 	}
 
-	// Clear index
-	if err := r.storage.ClearIndex(); err != nil {
+	// The commit concludes a merge, revert or cherry-pick that stopped on conflicts
+	if err := r.clearMergeState(); err != nil {
 		return "", err
 	}
 
+	// Concluding the last commit of a cherry-pick finishes it
+	if picked != "" {
+		if err := r.clearFinishedSequencer(); err != nil {
+			return "", err
+		}
+	}
+
+	// The index is left as is: it already matches the new commit's tree
+	// and is the starting point for the next commit
 	return commit.ID(), nil
 }
 
+// writeTree builds the tree hierarchy for a snapshot and stores every tree in it
+// @param files File paths mapped to blob hashes
+// @return string, error The root tree hash and nil on success, or an empty string and an error on failure
+func (r *Repository) writeTree(files map[string]string) (string, error) {
+	tree, trees := core.BuildTreeHierarchy(files)
+
+	// Store every tree in the object database so the full snapshot is reachable
+	for _, t := range trees {
+		if err := r.storage.StoreObject(t); err != nil {
+			return "", err
+		}
+	}
+
+	return tree.ID(), nil
+}
+
+// commitTree creates and stores a commit for a tree, authored by the current user
+// @param treeHash The root tree of the commit
+// @param parents The parent commit hashes in order
+// @param message The commit message
+// @return *core.Commit, error The stored commit and nil on success, or nil and an error on failure
+func (r *Repository) commitTree(treeHash string, parents []string, message string) (*core.Commit, error) {
+	return r.commitTreeAs(treeHash, parents, message, currentAuthor())
+}
+
+// commitTreeAs creates and stores a commit for a tree with a given author, e.g. the author of a replayed commit
+func (r *Repository) commitTreeAs(treeHash string, parents []string, message, author string) (*core.Commit, error) {
+	commit := core.NewMergeCommit(treeHash, parents, message, author)
+
+	// Store commit in object database
+	if err := r.storage.StoreObject(commit); err != nil {
+		return nil, err
+	}
+
+	return commit, nil
+}
+
+// advanceHead points the current branch at a new commit, or moves HEAD itself when it is detached
+// @dev HEAD is a symbolic ref on a branch, so updating it moves the branch
+func (r *Repository) advanceHead(commitHash, reason string) error {
+	return r.updateRef(storage.HeadFile, commitHash, reason)
+}
+
+// commitReason builds the reflog message of a new commit
+func commitReason(parents []string, message string) string {
+	switch {
+	case len(parents) == 0:
+		return "commit (initial): " + subject(message)
+	case len(parents) > 1:
+		return "commit (merge): " + subject(message)
+	default:
+		return "commit: " + subject(message)
+	}
+}
+
+// currentAuthor returns the name recorded as author of new commits
+func currentAuthor() string {
+	currentUser, err := user.Current()
+	if err != nil {
+		return "unknown"
+	}
+	return currentUser.Username
+}
+
 // CreateBranch creates a new branch pointing to the current HEAD
 func (r *Repository) CreateBranch(name string) error {
+	return r.CreateBranchAt(name, "")
+}
+
+// CreateBranchAt creates a new branch pointing to a revision
+// @param name The branch name
+// @param startRev The commit the branch starts at; empty means HEAD
+// @return error Returns nil on success or an error if the name is invalid or taken, or the start point is not a commit
+func (r *Repository) CreateBranchAt(name, startRev string) error {
+	if err := validateRefName("branch", name); err != nil {
+		return err
+	}
+
+	if _, err := r.storage.GetRef(branchRef(name)); err == nil {
+		return fmt.Errorf("a branch named '%s' already exists", name)
+	}
+
+	startHash, err := r.branchStart(name, startRev)
+	if err != nil {
+		return err
+	}
+
+	if startRev == "" {
+		startRev = storage.HeadFile
+	}
+
+	// Update the branch reference
+	return r.updateRef(branchRef(name), startHash, "branch: Created from "+startRev)
+}
+
+// branchStart resolves the commit a new branch starts at
+func (r *Repository) branchStart(name, startRev string) (string, error) {
+	if startRev != "" {
+		return r.resolveCommit(startRev)
+	}
+
 	// Get current HEAD commit
 	headCommit, err := r.storage.GetHeadCommit()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if headCommit == nil {
-		return fmt.Errorf("cannot create branch '%s': you must create at least one commit first", name)
+		return "", fmt.Errorf("cannot create branch '%s': you must create at least one commit first", name)
 	}
 
-	// Update the branch reference
-	return r.storage.UpdateRef(name, headCommit.ID())
+	return headCommit.ID(), nil
 }
 
 // ListBranches lists all branches in the repository
 func (r *Repository) ListBranches() ([]string, error) {
-	refs, err := r.storage.ListRefs()
+	refs, err := storage.ListRefsIn(r.storage, storage.HeadsDir)
 	if err != nil {
 		return nil, err
 	}
@@ -236,108 +453,61 @@ func (r *Repository) ListBranches() ([]string, error) {
 	for branch := range refs {
 		branches = append(branches, branch)
 	}
+	sort.Strings(branches)
 
 	return branches, nil
 }
 
-// Checkout switches to the specified branch
-func (r *Repository) Checkout(branchName string) error {
-	// Check if branch exists
-	_, err := r.storage.GetRef(branchName)
-	if err != nil {
-		return fmt.Errorf("branch '%s' does not exist", branchName)
-	}
-
-	// Update HEAD to point to the branch
-	return r.storage.SetHead(branchName)
-}
-
 // GetStorage returns the repository's storage
 func (r *Repository) GetStorage() storage.Storage {
 	return r.storage
 }
 
 // GetCurrentBranch returns the name of the current branch
+// @return string, error The branch name, or an empty string when HEAD is detached
 func (r *Repository) GetCurrentBranch() (string, error) {
 	return r.storage.GetHead()
 }
 
-// Status returns the status of files in the repository
-func (r *Repository) Status() (*RepositoryStatus, error) {
-	// Initialize status
-	status := &RepositoryStatus{
-		Staged:    make(map[string]bool),
-		Unstaged:  make(map[string]bool),
-		Untracked: make(map[string]bool),
-	}
-
-	// Get staged files from index
-	indexEntries, err := r.storage.GetIndexEntries()
+// IsDetached reports whether HEAD points directly at a commit instead of a branch
+func (r *Repository) IsDetached() (bool, error) {
+	head, err := r.storage.GetHead()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get index entries: %v", err)
+		return false, err
 	}
+	return head == "", nil
+}
 
-	// Get all files in the workspace
-	workspaceFiles := make(map[string]bool)
-	if err := filepath.Walk(r.path, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip .yag directory
-		if info.IsDir() && filepath.Base(path) == storage.YAGDir {
-			return filepath.SkipDir
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+// IsRevision reports whether a string names a commit
+func (r *Repository) IsRevision(rev string) bool {
+	_, err := r.resolveCommit(rev)
+	return err == nil
+}
 
-		// Get relative path
-		relPath, err := filepath.Rel(r.path, path)
-		if err != nil {
-			return err
-		}
+// resolveCommit resolves a revision expression that must name a commit
+// @dev Annotated tags are followed to the commit they tag
+// @param rev The revision to resolve
+// @return string, error The commit hash and nil on success, or an empty string and an error if it does not resolve to a commit
+func (r *Repository) resolveCommit(rev string) (string, error) {
+	hash, err := r.ResolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
 
-		workspaceFiles[relPath] = true
-		return nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to walk workspace: %v", err)
-	}
-
-	// Compare workspace files with index
-	for file := range workspaceFiles {
-		_, inIndex := indexEntries[file]
-		if inIndex {
-			// File is in index, check if it's been modified
-			filePath := filepath.Join(r.path, file)
-			blob, err := core.NewBlobFromFile(filePath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create blob from file: %v", err)
-			}
-
-			// If the hash is different, file is unstaged
-			if blob.ID() != indexEntries[file] {
-				status.Unstaged[file] = true
-			}
-		} else {
-			// File is not in index, it's untracked
-			status.Untracked[file] = true
-		}
+	if hash, err = r.peel(hash); err != nil {
+		return "", err
 	}
 
-	// Add all staged files
-	for file := range indexEntries {
-		status.Staged[file] = true
+	if _, err := r.readCommit(hash); err != nil {
+		return "", fmt.Errorf("revision '%s' is not a commit", rev)
 	}
 
-	return status, nil
+	return hash, nil
 }
 
 // Unstage removes a file from the staging area
 // @notice Removes a file's changes from the staging area (index)
-// @dev Gets current index entries, converts the path to a relative path, removes the entry, and updates the index
+// @dev Resets the index entry to the version in the HEAD commit, or drops it if HEAD does not contain the file
 // @param filePath The path to the file to unstage (can be absolute or relative)
 // @return error Returns nil on success or an error if unstaging fails
 func (r *Repository) Unstage(filePath string) error {
@@ -347,6 +517,11 @@ func (r *Repository) Unstage(filePath string) error {
 		return fmt.Errorf("failed to get index entries: %v", err)
 	}
 
+	headFiles, err := r.headFiles()
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD commit: %v", err)
+	}
+
 	// Get absolute path and convert to relative path
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
@@ -358,13 +533,19 @@ func (r *Repository) Unstage(filePath string) error {
 		return fmt.Errorf("failed to get relative path: %v", err)
 	}
 
-	// Check if file is in the index
-	if _, exists := indexEntries[relPath]; !exists {
+	// Check if file is in the index or was staged for removal
+	_, inIndex := indexEntries[relPath]
+	headHash, inHead := headFiles[relPath]
+	if !inIndex && !inHead {
 		return fmt.Errorf("pathspec '%s' did not match any file in the index", filePath)
 	}
 
-	// Remove the entry from the index
-	delete(indexEntries, relPath)
+	// Reset the entry to what HEAD records
+	if inHead {
+		indexEntries[relPath] = headHash
+	} else {
+		delete(indexEntries, relPath)
+	}
 
 	// Update the index file
 	return r.storage.UpdateIndexEntries(indexEntries)
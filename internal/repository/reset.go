@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// ResetMode selects how much of the repository Reset rewrites
+type ResetMode int
+
+const (
+	// ResetSoft only moves the current branch; the index and working tree keep their content
+	ResetSoft ResetMode = iota
+
+	// ResetMixed moves the current branch and rewrites the index, keeping the working tree
+	ResetMixed
+
+	// ResetHard moves the current branch and rewrites the index and working tree, discarding every local change
+	ResetHard
+)
+
+// String returns the mode's name as used on the command line
+func (m ResetMode) String() string {
+	switch m {
+	case ResetSoft:
+		return "soft"
+	case ResetHard:
+		return "hard"
+	default:
+		return "mixed"
+	}
+}
+
+// Reset moves the current branch (or a detached HEAD) to a commit
+// @notice The previous position is saved as ORIG_HEAD and in the reflog, so a reset can be undone
+// @dev Mixed and hard resets also abandon an in-progress merge, revert or stopped cherry-pick; a cherry-pick with
+// commits left to replay stays in progress for --continue or --abort
+// @param rev The commit to move to; empty means HEAD
+// @param mode Whether to also rewrite the index (mixed) or the index and working tree (hard)
+// @return error Returns nil on success or an error if the revision is not a commit
+func (r *Repository) Reset(rev string, mode ResetMode) error {
+	if rev == "" {
+		rev = storage.HeadFile
+	}
+
+	target, err := r.resolveCommit(rev)
+	if err != nil {
+		return err
+	}
+
+	merging, err := r.mergeInProgress()
+	if err != nil {
+		return err
+	}
+	if merging && mode == ResetSoft {
+		return fmt.Errorf("cannot do a soft reset in the middle of a merge")
+	}
+
+	files, err := r.commitFiles(target)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case ResetHard:
+		if err := r.resetWorktree(files); err != nil {
+			return err
+		}
+	case ResetMixed:
+		if err := r.storage.UpdateIndexEntries(files); err != nil {
+			return err
+		}
+		if err := r.storage.UpdateIndexConflicts(nil); err != nil {
+			return err
+		}
+	}
+
+	if headCommit, err := r.storage.GetHeadCommit(); err == nil && headCommit != nil {
+		if err := r.storage.WriteState(storage.OrigHeadFile, headCommit.ID()); err != nil {
+			return err
+		}
+	}
+
+	if err := r.updateRef(storage.HeadFile, target, "reset: moving to "+rev); err != nil {
+		return err
+	}
+
+	if mode != ResetSoft {
+		if err := r.clearMergeState(); err != nil {
+			return err
+		}
+		return r.clearFinishedSequencer()
+	}
+
+	return nil
+}
+
+// ResetPaths sets index entries back to their version in a commit, leaving HEAD and the working tree alone
+// @notice The opposite of Add: "yag reset HEAD -- file" unstages file. Paths the commit does not have are removed from the index
+// @param rev The commit to take the entries from; empty means HEAD, or an empty snapshot before the first commit
+// @param pathspecs File paths, directories or glob patterns selecting the entries
+// @return []string, error The sorted list of reset paths and nil on success, or nil and an error if a pathspec matches nothing
+func (r *Repository) ResetPaths(rev string, pathspecs []string) ([]string, error) {
+	specs, err := r.parsePathspecs(pathspecs)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceFiles := map[string]string{}
+	if rev != "" {
+		target, err := r.resolveCommit(rev)
+		if err != nil {
+			return nil, err
+		}
+		if sourceFiles, err = r.commitFiles(target); err != nil {
+			return nil, err
+		}
+	} else if sourceFiles, err = r.headFiles(); err != nil {
+		return nil, err
+	}
+
+	indexEntries, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index conflicts: %v", err)
+	}
+
+	candidates := make(map[string]string, len(sourceFiles)+len(indexEntries)+len(conflicts))
+	for _, files := range []map[string]string{indexEntries, sourceFiles} {
+		for path, hash := range files {
+			candidates[path] = hash
+		}
+	}
+	for path := range conflicts {
+		candidates[path] = ""
+	}
+
+	selected, err := matchPathspecs(specs, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	reset := make([]string, 0, len(selected))
+	for path := range selected {
+		if hash, inSource := sourceFiles[path]; inSource {
+			indexEntries[path] = hash
+		} else {
+			delete(indexEntries, path)
+		}
+		delete(conflicts, path)
+		reset = append(reset, path)
+	}
+
+	if err := r.storage.UpdateIndexEntries(indexEntries); err != nil {
+		return nil, err
+	}
+	if err := r.storage.UpdateIndexConflicts(conflicts); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(reset)
+	return reset, nil
+}
+
+// resetWorktree makes the index and working tree match a snapshot, discarding every local change and unresolved conflict
+// @dev Files that are tracked only by the index are deleted from the working tree; untracked files are left alone
+func (r *Repository) resetWorktree(target map[string]string) error {
+	index, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return fmt.Errorf("failed to get index conflicts: %v", err)
+	}
+
+	tracked := make(map[string]bool, len(index)+len(conflicts))
+	for path := range index {
+		tracked[path] = true
+	}
+	for path := range conflicts {
+		tracked[path] = true
+	}
+
+	for path := range tracked {
+		if _, inTarget := target[path]; !inTarget {
+			if err := r.removeWorkingFile(path); err != nil {
+				return fmt.Errorf("failed to remove '%s': %v", path, err)
+			}
+		}
+	}
+
+	return r.switchSnapshot(target, true)
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Restore discards local modifications to the working tree
+// @notice Rewrites the selected files from the index, or from a commit's tree when a source revision is given
+// @dev When restoring from a source revision, tracked files that the source does not contain are deleted.
+// Selected files whose working copy already matches the source are left alone
+// @param pathspecs File paths, directories or glob patterns selecting what to restore
+// @param source The revision to restore from, or an empty string to restore from the index
+// @return []string, error The sorted list of paths that were rewritten or deleted and nil on success, or nil and an
+// error if a pathspec matches nothing
+func (r *Repository) Restore(pathspecs []string, source string) ([]string, error) {
+	specs, err := r.parsePathspecs(pathspecs)
+	if err != nil {
+		return nil, err
+	}
+
+	indexEntries, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	// Work out which snapshot the files come from
+	sourceFiles := indexEntries
+	if source != "" {
+		commitHash, err := r.resolveCommit(source)
+		if err != nil {
+			return nil, err
+		}
+
+		commit, err := r.readCommit(commitHash)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceFiles, err = r.flattenTree(commit.TreeHash())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tracked files missing from the source are candidates too, so they can be removed
+	candidates := make(map[string]string, len(sourceFiles)+len(indexEntries))
+	for path, hash := range indexEntries {
+		candidates[path] = hash
+	}
+	for path, hash := range sourceFiles {
+		candidates[path] = hash
+	}
+
+	selected, err := matchPathspecs(specs, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]string, 0, len(selected))
+	for path := range selected {
+		workingHash, err := r.hashWorkingFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, inSource := sourceFiles[path]
+		if workingHash == hash {
+			// Already identical, or already gone when the source does not have the file
+			continue
+		}
+
+		if !inSource {
+			if err := r.removeWorkingFile(path); err != nil {
+				return nil, fmt.Errorf("failed to remove '%s': %v", path, err)
+			}
+		} else if err := r.writeWorkingFile(path, hash); err != nil {
+			return nil, fmt.Errorf("failed to restore '%s': %v", path, err)
+		}
+
+		restored = append(restored, path)
+	}
+
+	sort.Strings(restored)
+	return restored, nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// RevertOutcome describes what a revert did
+type RevertOutcome struct {
+	CommitHash string   // The new commit undoing the reverted one, empty when the revert stopped on conflicts
+	Message    string   // The message of the new commit
+	Conflicts  []string // Paths left conflicted, sorted
+}
+
+// Revert records a new commit that undoes the changes another commit introduced
+// @notice The inverse of the commit's changes against its parent is applied to HEAD with a three-way merge, so later
+// changes to the same files are kept
+// @dev The working tree and index must be clean. A conflicted revert writes conflict markers, records the conflicting
+// versions in the index and waits for RevertContinue or RevertAbort
+// @param rev The commit to revert
+// @return *RevertOutcome, error What the revert did and nil on success, or nil and an error if the revert could not be attempted
+func (r *Repository) Revert(rev string) (*RevertOutcome, error) {
+	target, err := r.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.readCommit(target)
+	if err != nil {
+		return nil, err
+	}
+	if commit.IsMerge() {
+		return nil, fmt.Errorf("commit %s is a merge; reverting merges is not supported", shortHash(target))
+	}
+
+	if err := r.requireNoOperation(); err != nil {
+		return nil, err
+	}
+
+	if err := r.requireCleanWorktree("revert"); err != nil {
+		return nil, err
+	}
+
+	// Replaying the change from the commit to its parent turns it around
+	targetFiles, err := r.flattenTree(commit.TreeHash())
+	if err != nil {
+		return nil, err
+	}
+
+	parentFiles, err := r.parentFiles(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, err := r.replayChange(targetFiles, parentFiles, "parent of "+pickLabel(target, commit), "revert")
+	if err != nil {
+		return nil, err
+	}
+
+	message := revertMessage(target, commit.Message())
+
+	if len(replay.merged.conflicts) > 0 {
+		// Remember the revert so it can be continued or aborted
+		err := r.stopReplay(replay, map[string]string{
+			storage.RevertHeadFile: target,
+			storage.MergeMsgFile:   message,
+			storage.OrigHeadFile:   replay.head.ID(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &RevertOutcome{Message: message, Conflicts: replay.merged.paths()}, nil
+	}
+
+	hash, err := r.commitReplay(replay, message, currentAuthor(), "revert: "+subject(message), "revert")
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, fmt.Errorf("nothing to revert: the changes of %s are not in HEAD", shortHash(target))
+	}
+
+	return &RevertOutcome{CommitHash: hash, Message: message}, nil
+}
+
+// RevertContinue concludes a revert that stopped on conflicts
+// @notice Records the revert commit once every conflicted path has been resolved with Add
+// @return string, error The hash of the revert commit and nil on success, or an empty string and an error if no revert is in progress or conflicts remain
+func (r *Repository) RevertContinue() (string, error) {
+	reverting, err := r.revertInProgress()
+	if err != nil {
+		return "", err
+	}
+	if reverting == "" {
+		return "", fmt.Errorf("there is no revert in progress")
+	}
+
+	message, err := r.storage.ReadState(storage.MergeMsgFile)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Commit(message)
+}
+
+// RevertAbort abandons a revert that stopped on conflicts
+// @notice Restores the index and working tree to the HEAD commit and forgets the revert
+// @return error Returns nil on success or an error if no revert is in progress
+func (r *Repository) RevertAbort() error {
+	reverting, err := r.revertInProgress()
+	if err != nil {
+		return err
+	}
+	if reverting == "" {
+		return fmt.Errorf("there is no revert to abort")
+	}
+
+	if err := r.resetToHead(); err != nil {
+		return err
+	}
+
+	return r.clearMergeState()
+}
+
+// revertInProgress returns the commit a conflicted revert is undoing, or an empty string when no revert is in progress
+func (r *Repository) revertInProgress() (string, error) {
+	revertHead, err := r.storage.ReadState(storage.RevertHeadFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(revertHead), nil
+}
+
+// revertMessage builds the default message of a revert commit
+func revertMessage(commitHash, message string) string {
+	return fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", subject(message), commitHash)
+}
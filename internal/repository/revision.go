@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// minAbbrevLength is the shortest abbreviated hash accepted as a revision
+const minAbbrevLength = 4
+
+// ResolveRevision turns a revision expression into an object hash
+// @notice Accepts "HEAD" (or "@"), branch and tag names, full and abbreviated hashes, "<rev>~<n>" (n-th first-parent ancestor),
+// "<rev>^<n>" (n-th parent), "<ref>@{<n>}" (n-th previous value of a ref), "<rev>:<path>" (a file or directory in a commit)
+// and ":<path>" (a file in the index). Suffixes can be chained, e.g. "main~2^2"
+// @dev An abbreviated hash that matches several objects is an error rather than a guess
+// @param rev The revision expression
+// @return string, error The object hash and nil on success, or an empty string and an error if the expression does not resolve
+func (r *Repository) ResolveRevision(rev string) (string, error) {
+	if rev == "" {
+		return "", fmt.Errorf("empty revision")
+	}
+
+	// "<rev>:<path>" names an object inside a commit's tree
+	if i := strings.Index(rev, ":"); i >= 0 {
+		return r.resolvePath(rev[:i], rev[i+1:])
+	}
+
+	// The base ends at the first ancestry operator; ref names cannot contain '~' or '^'
+	end := strings.IndexAny(rev, "~^")
+	if end < 0 {
+		end = len(rev)
+	}
+
+	hash, err := r.resolveBase(rev[:end])
+	if err != nil {
+		return "", err
+	}
+
+	// Ancestry operators apply to the commit an annotated tag names
+	if end < len(rev) {
+		if hash, err = r.peel(hash); err != nil {
+			return "", err
+		}
+	}
+
+	// Walk the ancestry operators left to right
+	for suffix := rev[end:]; suffix != ""; {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(suffix[:digits]); err != nil {
+				return "", fmt.Errorf("invalid revision '%s'", rev)
+			}
+		}
+		suffix = suffix[digits:]
+
+		if op == '~' {
+			for i := 0; i < n; i++ {
+				if hash, err = r.nthParent(hash, 1, rev); err != nil {
+					return "", err
+				}
+			}
+		} else if n > 0 {
+			if hash, err = r.nthParent(hash, n, rev); err != nil {
+				return "", err
+			}
+		} else if _, err := r.readCommit(hash); err != nil {
+			// "^0" names the commit itself
+			return "", fmt.Errorf("revision '%s' is not a commit", rev)
+		}
+	}
+
+	return hash, nil
+}
+
+// resolveBase resolves a revision without ancestry operators: a reflog entry, a ref, or a full or abbreviated hash
+func (r *Repository) resolveBase(name string) (string, error) {
+	// "<ref>@{<n>}" selects an earlier value of a ref
+	if i := strings.Index(name, "@{"); i >= 0 && strings.HasSuffix(name, "}") {
+		n, err := strconv.Atoi(name[i+2 : len(name)-1])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("invalid reflog selector in '%s'", name)
+		}
+		return r.resolveReflog(name[:i], n)
+	}
+
+	if name == "HEAD" || name == "@" {
+		headCommit, err := r.storage.GetHeadCommit()
+		if err != nil {
+			return "", err
+		}
+		if headCommit == nil {
+			return "", fmt.Errorf("HEAD does not point to a commit yet")
+		}
+		return headCommit.ID(), nil
+	}
+
+	if hash, err := r.lookupRef(name); err == nil {
+		return hash, nil
+	}
+
+	if !isHex(name) || len(name) < minAbbrevLength {
+		return "", fmt.Errorf("unknown revision '%s'", name)
+	}
+
+	matches, err := r.storage.FindObjects(name)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown revision '%s'", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("short object ID %s is ambiguous: it matches %d objects", name, len(matches))
+	}
+}
+
+// lookupRef finds the hash a ref name points to
+// @dev Tries "refs/<name>", then tags, then branches, which is the order Git uses
+func (r *Repository) lookupRef(name string) (string, error) {
+	if strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid ref name '%s'", name)
+	}
+
+	name = strings.TrimPrefix(name, storage.RefsDir+"/")
+	for _, candidate := range []string{
+		name,
+		storage.TagsDir + "/" + name,
+		storage.HeadsDir + "/" + name,
+	} {
+		if hash, err := r.storage.GetRef(storage.RefsDir + "/" + candidate); err == nil {
+			return hash, nil
+		}
+	}
+
+	return "", fmt.Errorf("reference %s not found", name)
+}
+
+// nthParent returns the n-th parent (1-based) of a commit
+func (r *Repository) nthParent(hash string, n int, rev string) (string, error) {
+	commit, err := r.readCommit(hash)
+	if err != nil {
+		return "", fmt.Errorf("revision '%s' is not a commit", rev)
+	}
+
+	parents := commit.ParentHashes()
+	if n > len(parents) {
+		return "", fmt.Errorf("unknown revision '%s': commit %s has %d parent(s)", rev, hash[:8], len(parents))
+	}
+
+	return parents[n-1], nil
+}
+
+// resolvePath resolves "<rev>:<path>" to the blob or tree at path, or ":<path>" to the blob staged in the index
+func (r *Repository) resolvePath(rev, path string) (string, error) {
+	path = filepath.Clean(filepath.FromSlash(path))
+
+	if rev == "" {
+		indexEntries, err := r.storage.GetIndexEntries()
+		if err != nil {
+			return "", fmt.Errorf("failed to get index entries: %v", err)
+		}
+
+		hash, ok := indexEntries[path]
+		if !ok {
+			return "", fmt.Errorf("path '%s' is not in the index", path)
+		}
+		return hash, nil
+	}
+
+	commitHash, err := r.resolveCommit(rev)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.readCommit(commitHash)
+	if err != nil {
+		return "", err
+	}
+
+	// Descend one directory at a time
+	hash := commit.TreeHash()
+	if path == "." {
+		return hash, nil
+	}
+
+	for _, name := range strings.Split(path, string(filepath.Separator)) {
+		tree, err := r.readTree(hash)
+		if err != nil {
+			return "", fmt.Errorf("path '%s' does not exist in '%s'", path, rev)
+		}
+
+		var found *core.TreeEntry
+		for _, entry := range tree.GetEntries() {
+			if entry.Name == name {
+				found = entry
+				break
+			}
+		}
+		if found == nil {
+			return "", fmt.Errorf("path '%s' does not exist in '%s'", path, rev)
+		}
+		hash = found.Hash
+	}
+
+	return hash, nil
+}
+
+// isHex reports whether a string consists only of lowercase hexadecimal digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return s != ""
+}
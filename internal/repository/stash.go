@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// StashEntry is a set of local changes saved by StashPush
+type StashEntry struct {
+	Index   int    // n in "stash@{n}"; 0 is the latest entry
+	Commit  string // The commit holding the working tree state; its parents are the base commit and the index state
+	Message string // Describes the entry, e.g. "WIP on master: 1a2b3c4d Subject"
+}
+
+// Name returns the entry's name as used on the command line
+func (e StashEntry) Name() string {
+	return fmt.Sprintf("stash@{%d}", e.Index)
+}
+
+// StashApplyOutcome describes what applying a stash entry did
+type StashApplyOutcome struct {
+	Entry     StashEntry // The applied entry
+	Conflicts []string   // Paths left conflicted, sorted
+	Dropped   bool       // Whether the entry was dropped after applying cleanly
+}
+
+// stashRefPattern matches "stash@{n}"
+var stashRefPattern = regexp.MustCompile(`^stash@\{(\d+)\}$`)
+
+// StashPush saves the index and the working tree changes to tracked files, then resets them to HEAD
+// @notice The index is recorded as a commit on HEAD, and the working tree as a commit with HEAD and the index commit as
+// parents. refs/stash points to the working tree commit; earlier entries live on in its reflog. Untracked files are left alone
+// @param message Describes the entry; empty for the default "WIP on <branch>: <commit> <subject>"
+// @return *StashEntry, error The new entry and nil on success, nil and nil when there are no local changes, or nil and an error on failure
+func (r *Repository) StashPush(message string) (*StashEntry, error) {
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	if headCommit == nil {
+		return nil, fmt.Errorf("cannot stash: your current branch does not have any commits yet")
+	}
+
+	status, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Unmerged) > 0 {
+		return nil, fmt.Errorf("cannot stash: you have unmerged files; resolve them first")
+	}
+	if len(status.Staged) == 0 && len(status.Unstaged) == 0 {
+		return nil, nil
+	}
+
+	index, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	worktree, err := r.trackedWorktreeFiles(index)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := "(no branch)"
+	if current, err := r.storage.GetHead(); err == nil && current != "" {
+		branch = current
+	}
+	base := fmt.Sprintf("%s: %s %s", branch, shortHash(headCommit.ID()), subject(headCommit.Message()))
+	if message == "" {
+		message = "WIP on " + base
+	} else {
+		message = fmt.Sprintf("On %s: %s", branch, message)
+	}
+
+	indexTree, err := r.writeTree(index)
+	if err != nil {
+		return nil, err
+	}
+	indexCommit, err := r.commitTree(indexTree, []string{headCommit.ID()}, "index on "+base)
+	if err != nil {
+		return nil, err
+	}
+
+	worktreeTree, err := r.writeTree(worktree)
+	if err != nil {
+		return nil, err
+	}
+	stash, err := r.commitTree(worktreeTree, []string{headCommit.ID(), indexCommit.ID()}, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.updateRef(storage.StashRef, stash.ID(), message); err != nil {
+		return nil, err
+	}
+
+	if err := r.resetToHead(); err != nil {
+		return nil, err
+	}
+
+	return &StashEntry{Index: 0, Commit: stash.ID(), Message: message}, nil
+}
+
+// StashList lists the stash entries, newest first
+// @return []StashEntry, error The entries and nil on success, or nil and an error on failure
+func (r *Repository) StashList() ([]StashEntry, error) {
+	entries, err := r.Reflog(storage.StashRef)
+	if err != nil {
+		return nil, err
+	}
+
+	stashes := make([]StashEntry, len(entries))
+	for i, entry := range entries {
+		stashes[i] = StashEntry{Index: i, Commit: entry.NewHash, Message: entry.Reason}
+	}
+
+	return stashes, nil
+}
+
+// StashEntryFor finds a stash entry by name
+// @param ref "stash@{n}", just n, or empty for the latest entry
+// @return *StashEntry, error The entry and nil on success, or nil and an error if there is no such entry
+func (r *Repository) StashEntryFor(ref string) (*StashEntry, error) {
+	n := 0
+	if ref != "" {
+		digits := ref
+		if match := stashRefPattern.FindStringSubmatch(ref); match != nil {
+			digits = match[1]
+		}
+
+		var err error
+		if n, err = strconv.Atoi(digits); err != nil || n < 0 {
+			return nil, fmt.Errorf("'%s' is not a stash reference", ref)
+		}
+	}
+
+	stashes, err := r.StashList()
+	if err != nil {
+		return nil, err
+	}
+	if len(stashes) == 0 {
+		return nil, fmt.Errorf("no stash entries found")
+	}
+	if n >= len(stashes) {
+		return nil, fmt.Errorf("stash@{%d} does not exist; there are %d entries", n, len(stashes))
+	}
+
+	return &stashes[n], nil
+}
+
+// StashApply restores the changes of a stash entry onto HEAD
+// @notice The entry's changes against the commit it was made on are applied with a three-way merge, so it can be
+// applied on another branch or after HEAD moved on. Changes come back unstaged, except new files, which stay tracked
+// @dev The index and working tree must be clean. Conflicts are written with markers and recorded in the index; the entry is kept
+// @param ref The entry to apply: "stash@{n}", n, or empty for the latest
+// @param restoreIndex Whether to also restore which changes were staged
+// @return *StashApplyOutcome, error What was applied and nil on success, or nil and an error if the entry could not be applied
+func (r *Repository) StashApply(ref string, restoreIndex bool) (*StashApplyOutcome, error) {
+	entry, err := r.StashEntryFor(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	stash, err := r.readCommit(entry.Commit)
+	if err != nil {
+		return nil, err
+	}
+	parents := stash.ParentHashes()
+	if len(parents) != 2 {
+		return nil, fmt.Errorf("%s is not a stash commit", entry.Name())
+	}
+
+	if err := r.requireCleanWorktree("apply a stash"); err != nil {
+		return nil, err
+	}
+
+	base, err := r.commitFiles(parents[0])
+	if err != nil {
+		return nil, err
+	}
+
+	stashed, err := r.flattenTree(stash.TreeHash())
+	if err != nil {
+		return nil, err
+	}
+
+	ours, err := r.headFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := mergeSettings{oursLabel: "Updated upstream", theirsLabel: "Stashed changes"}
+
+	// The staged state is merged first, so a failure leaves everything untouched
+	var index map[string]string
+	if restoreIndex {
+		stagedFiles, err := r.commitFiles(parents[1])
+		if err != nil {
+			return nil, err
+		}
+
+		staged, err := r.mergeSnapshots(base, ours, stagedFiles, settings)
+		if err != nil {
+			return nil, err
+		}
+		if len(staged.conflicts) > 0 {
+			return nil, fmt.Errorf("conflicts in the stashed index; try without restoring the index")
+		}
+		index = staged.files
+	}
+
+	merged, err := r.mergeSnapshots(base, ours, stashed, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(merged.conflicts) > 0 {
+		if err := r.applyConflictedMerge(ours, merged); err != nil {
+			return nil, err
+		}
+		return &StashApplyOutcome{Entry: *entry, Conflicts: merged.paths()}, nil
+	}
+
+	if err := r.checkUntrackedOverwritten(ours, merged.files, "stash apply"); err != nil {
+		return nil, err
+	}
+
+	if err := r.switchSnapshot(merged.files, false); err != nil {
+		return nil, err
+	}
+
+	// Without the stashed index, HEAD stays staged and only new files are tracked
+	if index == nil {
+		index = make(map[string]string, len(merged.files))
+		for path, hash := range ours {
+			index[path] = hash
+		}
+		for path, hash := range merged.files {
+			if _, tracked := ours[path]; !tracked {
+				index[path] = hash
+			}
+		}
+	}
+
+	if err := r.storage.UpdateIndexEntries(index); err != nil {
+		return nil, err
+	}
+
+	return &StashApplyOutcome{Entry: *entry}, nil
+}
+
+// StashPop applies a stash entry and drops it if it applied without conflicts
+// @param ref The entry to apply: "stash@{n}", n, or empty for the latest
+// @param restoreIndex Whether to also restore which changes were staged
+// @return *StashApplyOutcome, error What was applied and nil on success, or nil and an error if the entry could not be applied
+func (r *Repository) StashPop(ref string, restoreIndex bool) (*StashApplyOutcome, error) {
+	outcome, err := r.StashApply(ref, restoreIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(outcome.Conflicts) > 0 {
+		return outcome, nil
+	}
+
+	if _, err := r.StashDrop(outcome.Entry.Name()); err != nil {
+		return nil, err
+	}
+	outcome.Dropped = true
+
+	return outcome, nil
+}
+
+// StashDrop removes a stash entry
+// @dev The stash reflog is rewritten without the entry; refs/stash moves to the newest remaining entry or is deleted
+// @param ref The entry to drop: "stash@{n}", n, or empty for the latest
+// @return *StashEntry, error The dropped entry and nil on success, or nil and an error if there is no such entry
+func (r *Repository) StashDrop(ref string) (*StashEntry, error) {
+	entry, err := r.StashEntryFor(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.storage.ReadReflog(storage.StashRef)
+	if err != nil {
+		return nil, err
+	}
+
+	// The reflog is oldest first, the entry index counts from the newest
+	drop := len(entries) - 1 - entry.Index
+	remaining := append(entries[:drop:drop], entries[drop+1:]...)
+
+	if err := r.storage.DeleteRef(storage.StashRef); err != nil {
+		return nil, err
+	}
+
+	if len(remaining) > 0 {
+		if err := r.storage.UpdateRef(storage.StashRef, remaining[len(remaining)-1].NewHash); err != nil {
+			return nil, err
+		}
+		if err := r.appendReflog(storage.StashRef, remaining); err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// trackedWorktreeFiles snapshots the working tree versions of the files in the index, storing their content
+// @dev Files deleted from the working tree are left out
+func (r *Repository) trackedWorktreeFiles(index map[string]string) (map[string]string, error) {
+	files := make(map[string]string, len(index))
+	for path, indexHash := range index {
+		hash, err := r.hashWorkingFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			continue
+		}
+
+		if hash != indexHash {
+			blob, err := core.NewBlobFromFile(filepath.Join(r.path, path))
+			if err != nil {
+				return nil, err
+			}
+			if err := r.storage.StoreObject(blob); err != nil {
+				return nil, err
+			}
+		}
+
+		files[path] = hash
+	}
+
+	return files, nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// FileChange describes how a file differs between two snapshots
+// @notice Used to label entries in the status output
+type FileChange string
+
+const (
+	// ChangeAdded means the file only exists in the newer snapshot
+	ChangeAdded FileChange = "added"
+
+	// ChangeModified means the file exists in both snapshots with different content
+	ChangeModified FileChange = "modified"
+
+	// ChangeDeleted means the file only exists in the older snapshot
+	ChangeDeleted FileChange = "deleted"
+)
+
+// UnmergedState describes how the two sides of a merge disagree about a conflicted file
+type UnmergedState string
+
+const (
+	// UnmergedBothModified means both sides changed the file in incompatible ways
+	UnmergedBothModified UnmergedState = "both modified"
+
+	// UnmergedBothAdded means both sides added the file with different content
+	UnmergedBothAdded UnmergedState = "both added"
+
+	// UnmergedDeletedByUs means the current branch deleted a file the other side modified
+	UnmergedDeletedByUs UnmergedState = "deleted by us"
+
+	// UnmergedDeletedByThem means the other side deleted a file the current branch modified
+	UnmergedDeletedByThem UnmergedState = "deleted by them"
+)
+
+// unmergedState classifies a conflict by which versions exist
+func unmergedState(conflict storage.IndexConflict) UnmergedState {
+	switch {
+	case conflict.Ours == "":
+		return UnmergedDeletedByUs
+	case conflict.Theirs == "":
+		return UnmergedDeletedByThem
+	case conflict.Base == "":
+		return UnmergedBothAdded
+	default:
+		return UnmergedBothModified
+	}
+}
+
+// RepositoryStatus represents the status of files in the repository
+// @notice Contains the categorized status of files in the repository for status command
+// @dev Staged compares the index with the HEAD commit, Unstaged compares the working tree with the index.
+// Unmerged paths are listed only in Unmerged
+type RepositoryStatus struct {
+	Staged    map[string]FileChange    // Changes between the HEAD commit and the index
+	Unstaged  map[string]FileChange    // Changes between the index and the working tree
+	Untracked map[string]bool          // Files not tracked by YAG
+	Unmerged  map[string]UnmergedState // Files left conflicted by a merge
+	Merging   bool                     // Whether a merge is waiting to be concluded
+	Reverting string                   // The commit a revert waiting to be concluded is undoing, or empty
+	Picking   string                   // The commit a cherry-pick stopped on, or empty
+	PicksLeft int                      // The number of commits a cherry-pick still has to replay
+	Rebasing  string                   // The commit a rebase in progress replays onto, or empty
+}
+
+// IsClean reports whether there is nothing to commit and no untracked files
+func (s *RepositoryStatus) IsClean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0 && len(s.Unmerged) == 0
+}
+
+// Status returns the status of files in the repository
+// @notice Performs a three-way comparison between the HEAD commit, the index and the working tree
+// @return *RepositoryStatus, error The categorized file changes and nil on success, or nil and an error on failure
+func (r *Repository) Status() (*RepositoryStatus, error) {
+	// Initialize status
+	status := &RepositoryStatus{
+		Staged:    make(map[string]FileChange),
+		Unstaged:  make(map[string]FileChange),
+		Untracked: make(map[string]bool),
+		Unmerged:  make(map[string]UnmergedState),
+	}
+
+	headFiles, err := r.headFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %v", err)
+	}
+
+	indexEntries, err := r.storage.GetIndexEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index entries: %v", err)
+	}
+
+	workspaceFiles, err := r.workingFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := r.storage.GetIndexConflicts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index conflicts: %v", err)
+	}
+
+	if status.Merging, err = r.mergeInProgress(); err != nil {
+		return nil, err
+	}
+
+	if status.Reverting, err = r.revertInProgress(); err != nil {
+		return nil, err
+	}
+
+	if status.Picking, err = r.stoppedPick(); err != nil {
+		return nil, err
+	}
+
+	todo, err := r.readPickTodo()
+	if err != nil {
+		return nil, err
+	}
+	status.PicksLeft = len(todo)
+
+	rebase, err := r.readRebaseState()
+	if err != nil {
+		return nil, err
+	}
+	if rebase != nil {
+		status.Rebasing = rebase.onto
+	}
+
+	// Conflicted files are reported on their own and left out of the other comparisons
+	for file, conflict := range conflicts {
+		status.Unmerged[file] = unmergedState(conflict)
+		delete(headFiles, file)
+		delete(indexEntries, file)
+		delete(workspaceFiles, file)
+	}
+
+	// Compare the index with the HEAD commit
+	for file, hash := range indexEntries {
+		headHash, inHead := headFiles[file]
+		if !inHead {
+			status.Staged[file] = ChangeAdded
+		} else if headHash != hash {
+			status.Staged[file] = ChangeModified
+		}
+	}
+	for file := range headFiles {
+		if _, inIndex := indexEntries[file]; !inIndex {
+			status.Staged[file] = ChangeDeleted
+		}
+	}
+
+	// Compare the working tree with the index
+	for file, hash := range indexEntries {
+		if !workspaceFiles[file] {
+			status.Unstaged[file] = ChangeDeleted
+			continue
+		}
+
+		workingHash, err := r.hashWorkingFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create blob from file: %v", err)
+		}
+
+		if workingHash != hash {
+			status.Unstaged[file] = ChangeModified
+		}
+	}
+
+	// Anything left in the working tree is untracked
+	for file := range workspaceFiles {
+		if _, inIndex := indexEntries[file]; !inIndex {
+			status.Untracked[file] = true
+		}
+	}
+
+	return status, nil
+}
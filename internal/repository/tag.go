@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// TagInfo describes a tag and what it points to
+type TagInfo struct {
+	Name   string    // Tag name without the refs/tags/ prefix
+	Hash   string    // Hash the tag ref points to: the tag object for annotated tags, the target otherwise
+	Target string    // Hash of the tagged object after following annotated tags
+	Tag    *core.Tag // The tag object, nil for lightweight tags
+}
+
+// Annotated reports whether the tag is an annotated tag object rather than a plain ref
+func (t *TagInfo) Annotated() bool {
+	return t.Tag != nil
+}
+
+// CreateTag creates a tag pointing to a revision
+// @notice With an empty message a lightweight tag (a plain ref) is created, otherwise an annotated tag object
+// @param name The tag name
+// @param rev The revision to tag; empty means HEAD
+// @param message The annotation message, or an empty string for a lightweight tag
+// @param force Whether to replace an existing tag of the same name
+// @return string, error The hash the tag ref points to and nil on success, or an empty string and an error on failure
+func (r *Repository) CreateTag(name, rev, message string, force bool) (string, error) {
+	if err := validateRefName("tag", name); err != nil {
+		return "", err
+	}
+
+	if !force {
+		if _, err := r.storage.GetRef(tagRef(name)); err == nil {
+			return "", fmt.Errorf("tag '%s' already exists", name)
+		}
+	}
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	target, err := r.ResolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
+
+	hash := target
+	if message != "" {
+		obj, err := r.storage.GetObject(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read object %s: %v", target, err)
+		}
+
+		tag := core.NewTag(name, target, obj.Type(), message, currentAuthor())
+		if err := r.storage.StoreObject(tag); err != nil {
+			return "", err
+		}
+		hash = tag.ID()
+	}
+
+	if err := r.storage.UpdateRef(tagRef(name), hash); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// ListTags lists the names of all tags in sorted order
+func (r *Repository) ListTags() ([]string, error) {
+	refs, err := storage.ListRefsIn(r.storage, storage.TagsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// DeleteTag removes a tag
+// @notice Only the ref is removed; an annotated tag object stays in the object database
+// @param name The tag name
+// @return error Returns nil on success or an error if the tag does not exist
+func (r *Repository) DeleteTag(name string) error {
+	if _, err := r.storage.GetRef(tagRef(name)); err != nil {
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+
+	return r.storage.DeleteRef(tagRef(name))
+}
+
+// GetTag looks up a tag and follows it to the object it names
+// @param name The tag name
+// @return *TagInfo, error The tag details and nil on success, or nil and an error if the tag does not exist
+func (r *Repository) GetTag(name string) (*TagInfo, error) {
+	hash, err := r.storage.GetRef(tagRef(name))
+	if err != nil {
+		return nil, fmt.Errorf("tag '%s' not found", name)
+	}
+
+	info := &TagInfo{Name: name, Hash: hash, Target: hash}
+
+	obj, err := r.storage.GetObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %v", hash, err)
+	}
+
+	if tag, ok := obj.(*core.Tag); ok {
+		info.Tag = tag
+		if info.Target, err = r.peel(hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// peel follows annotated tags until it reaches an object that is not a tag
+func (r *Repository) peel(hash string) (string, error) {
+	// Guard against tag cycles, which can only come from corrupt data
+	for depth := 0; depth < 100; depth++ {
+		obj, err := r.storage.GetObject(hash)
+		if err != nil {
+			return "", fmt.Errorf("failed to read object %s: %v", hash, err)
+		}
+
+		tag, ok := obj.(*core.Tag)
+		if !ok {
+			return hash, nil
+		}
+		hash = tag.TargetHash()
+	}
+
+	return "", fmt.Errorf("too many levels of tags at %s", hash)
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// readCommit loads a commit object from storage
+// @param hash The hash of the commit to load
+// @return *core.Commit, error The commit and nil on success, or nil and an error if the object is missing or not a commit
+func (r *Repository) readCommit(hash string) (*core.Commit, error) {
+	obj, err := r.storage.GetObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+
+	commit, ok := obj.(*core.Commit)
+	if !ok {
+		return nil, fmt.Errorf("object %s is not a commit", hash)
+	}
+
+	return commit, nil
+}
+
+// readTree loads a tree object from storage
+// @param hash The hash of the tree to load
+// @return *core.Tree, error The tree and nil on success, or nil and an error if the object is missing or not a tree
+func (r *Repository) readTree(hash string) (*core.Tree, error) {
+	obj, err := r.storage.GetObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree %s: %v", hash, err)
+	}
+
+	tree, ok := obj.(*core.Tree)
+	if !ok {
+		return nil, fmt.Errorf("object %s is not a tree", hash)
+	}
+
+	return tree, nil
+}
+
+// readBlob loads a blob object from storage
+// @param hash The hash of the blob to load
+// @return *core.Blob, error The blob and nil on success, or nil and an error if the object is missing or not a blob
+func (r *Repository) readBlob(hash string) (*core.Blob, error) {
+	obj, err := r.storage.GetObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %v", hash, err)
+	}
+
+	blob, ok := obj.(*core.Blob)
+	if !ok {
+		return nil, fmt.Errorf("object %s is not a blob", hash)
+	}
+
+	return blob, nil
+}
+
+// flattenTree lists every file reachable from a tree
+// @param hash The hash of the root tree
+// @return map[string]string, error File paths relative to the repository root mapped to blob hashes
+func (r *Repository) flattenTree(hash string) (map[string]string, error) {
+	files := make(map[string]string)
+
+	var walk func(treeHash, prefix string) error
+	walk = func(treeHash, prefix string) error {
+		tree, err := r.readTree(treeHash)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range tree.GetEntries() {
+			path := filepath.Join(prefix, entry.Name)
+			if entry.Mode == core.ModeDir {
+				if err := walk(entry.Hash, path); err != nil {
+					return err
+				}
+				continue
+			}
+			files[path] = entry.Hash
+		}
+
+		return nil
+	}
+
+	if err := walk(hash, ""); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// headFiles lists the files recorded in the HEAD commit
+// @return map[string]string, error File paths mapped to blob hashes, empty when there are no commits yet
+func (r *Repository) headFiles() (map[string]string, error) {
+	headCommit, err := r.storage.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	if headCommit == nil {
+		return make(map[string]string), nil
+	}
+
+	return r.flattenTree(headCommit.TreeHash())
+}
+
+// workingFiles lists every file in the working directory, skipping the .yag directory
+// @return map[string]bool, error File paths relative to the repository root
+func (r *Repository) workingFiles() (map[string]bool, error) {
+	files := make(map[string]bool)
+
+	err := filepath.Walk(r.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip .yag directory
+		if info.IsDir() && filepath.Base(path) == storage.YAGDir {
+			return filepath.SkipDir
+		}
+
+		// Skip directories
+		if info.IsDir() {
+			return nil
+		}
+
+		// Get relative path
+		relPath, err := filepath.Rel(r.path, path)
+		if err != nil {
+			return err
+		}
+
+		files[relPath] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk workspace: %v", err)
+	}
+
+	return files, nil
+}
+
+// hashWorkingFile computes the blob hash of a file in the working directory
+// @param relPath The file path relative to the repository root
+// @return string, error The blob hash, or an empty string if the file does not exist
+func (r *Repository) hashWorkingFile(relPath string) (string, error) {
+	blob, err := core.NewBlobFromFile(filepath.Join(r.path, relPath))
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(r.path, relPath)); os.IsNotExist(statErr) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return blob.ID(), nil
+}
+
+// writeWorkingFile writes the content of a stored blob into the working directory
+// @param relPath The file path relative to the repository root
+// @param blobHash The hash of the blob holding the file content
+// @return error Returns nil on success or an error if the blob cannot be read or the file cannot be written
+func (r *Repository) writeWorkingFile(relPath, blobHash string) error {
+	blob, err := r.readBlob(blobHash)
+	if err != nil {
+		return err
+	}
+
+	return r.writeWorkingContent(relPath, blob.Content())
+}
+
+// writeWorkingContent writes raw content to a file in the working directory, creating parent directories
+// @param relPath The file path relative to the repository root
+// @param content The content to write
+// @return error Returns nil on success or an error if the file cannot be written
+func (r *Repository) writeWorkingContent(relPath string, content []byte) error {
+	absPath := filepath.Join(r.path, relPath)
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(absPath, content, 0644)
+}
+
+// removeWorkingFile deletes a file from the working directory and prunes any parent directories left empty
+// @param relPath The file path relative to the repository root
+// @return error Returns nil on success (including when the file is already gone) or an error if removal fails
+func (r *Repository) removeWorkingFile(relPath string) error {
+	absPath := filepath.Join(r.path, relPath)
+	if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Remove parent directories until we reach a non-empty one or the repository root
+	root := filepath.Clean(r.path)
+	for dir := filepath.Dir(absPath); dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/xhad/yag/internal/core"
 )
@@ -15,11 +16,56 @@ const (
 	ObjectsDir    = "objects"
 	RefsDir       = "refs"
 	HeadsDir      = "heads"
+	TagsDir       = "tags"
 	IndexFile     = "index"
 	HeadFile      = "HEAD"
 	DefaultBranch = "master"
+	ConflictsFile = "index.conflicts"
+	MergeHeadFile = "MERGE_HEAD"
+	MergeMsgFile  = "MERGE_MSG"
+	OrigHeadFile  = "ORIG_HEAD"
+
+	// RevertHeadFile names the commit a revert that stopped on conflicts is undoing
+	RevertHeadFile = "REVERT_HEAD"
+
+	// CherryPickHeadFile names the commit a cherry-pick that stopped on conflicts is replaying
+	CherryPickHeadFile = "CHERRY_PICK_HEAD"
+
+	// SequencerTodoFile lists the commits a multi-commit cherry-pick still has to replay, one hash per line
+	SequencerTodoFile = "sequencer/todo"
+
+	// SequencerHeadFile remembers where HEAD was before a multi-commit cherry-pick, so it can be aborted
+	SequencerHeadFile = "sequencer/head"
+
+	// The rebase-merge/ entries hold a rebase in progress: the branch being rebased (empty when HEAD was detached),
+	// its tip before the rebase, the commit it is replayed onto, the steps left to run, the step that stopped on
+	// conflicts and the commit an edit step stopped at
+	RebaseHeadNameFile = "rebase-merge/head-name"
+	RebaseOrigHeadFile = "rebase-merge/orig-head"
+	RebaseOntoFile     = "rebase-merge/onto"
+	RebaseTodoFile     = "rebase-merge/git-rebase-todo"
+	RebaseStoppedFile  = "rebase-merge/stopped"
+	RebaseAmendFile    = "rebase-merge/amend"
+
+	// CommitEditMsgFile is where commit messages are edited
+	CommitEditMsgFile = "COMMIT_EDITMSG"
+
+	// StashRef points to the latest stash entry; its reflog holds the older ones
+	StashRef = "refs/stash"
+
+	// LogsDir holds the reflogs, mirroring the layout of HEAD and refs/
+	LogsDir = "logs"
+
+	// ZeroHash stands for "no commit" in a reflog, e.g. as the old value of a newly created branch
+	ZeroHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	// SymbolicRefPrefix starts the content of a ref that points to another ref, e.g. "ref: refs/heads/master"
+	SymbolicRefPrefix = "ref: "
 )
 
+// maxSymbolicRefDepth bounds how many symbolic refs are followed, guarding against cycles
+const maxSymbolicRefDepth = 5
+
 // FileSystemStorage implements the Storage interface using the file system
 type FileSystemStorage struct {
 	rootPath string
@@ -44,14 +90,16 @@ func (fs *FileSystemStorage) Initialize() error {
 		return err
 	}
 
-	// Create refs/heads directory
-	if err := os.MkdirAll(filepath.Join(fs.rootPath, YAGDir, RefsDir, HeadsDir), 0755); err != nil {
-		return err
+	// Create refs/heads and refs/tags directories
+	for _, namespace := range []string{HeadsDir, TagsDir} {
+		if err := os.MkdirAll(filepath.Join(fs.rootPath, YAGDir, RefsDir, namespace), 0755); err != nil {
+			return err
+		}
 	}
 
 	// Create HEAD file pointing to master branch
 	headPath := filepath.Join(fs.rootPath, YAGDir, HeadFile)
-	if err := os.WriteFile(headPath, []byte("ref: refs/heads/"+DefaultBranch), 0644); err != nil {
+	if err := os.WriteFile(headPath, []byte(SymbolicRefPrefix+RefsDir+"/"+HeadsDir+"/"+DefaultBranch), 0644); err != nil {
 		return err
 	}
 
@@ -69,9 +117,28 @@ func (fs *FileSystemStorage) objectPath(hash string) string {
 	return filepath.Join(fs.rootPath, YAGDir, ObjectsDir, hash)
 }
 
+// qualifyRef returns the fully qualified name of a ref
+// @dev "HEAD" and names starting with "refs/" are already qualified; any other name is a branch under refs/heads
+func qualifyRef(name string) string {
+	if name == HeadFile || strings.HasPrefix(name, RefsDir+"/") {
+		return name
+	}
+	return RefsDir + "/" + HeadsDir + "/" + name
+}
+
 // refPath returns the path to a ref file
 func (fs *FileSystemStorage) refPath(name string) string {
-	return filepath.Join(fs.rootPath, YAGDir, RefsDir, HeadsDir, name)
+	return filepath.Join(fs.rootPath, YAGDir, filepath.FromSlash(qualifyRef(name)))
+}
+
+// checkRefName rejects ref names with empty, "." or ".." components, which could escape the refs directory
+func checkRefName(name string) error {
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || component == "." || component == ".." {
+			return fmt.Errorf("invalid reference name '%s'", name)
+		}
+	}
+	return nil
 }
 
 // StoreObject stores an object in the storage
@@ -106,6 +173,27 @@ func (fs *FileSystemStorage) HasObject(hash string) (bool, error) {
 	return true, nil
 }
 
+// FindObjects lists the stored objects whose hash starts with a prefix
+func (fs *FileSystemStorage) FindObjects(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(fs.rootPath, YAGDir, ObjectsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Directory entries come back sorted by name
+	var matches []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			matches = append(matches, entry.Name())
+		}
+	}
+
+	return matches, nil
+}
+
 // GetObject retrieves an object from storage by its hash
 func (fs *FileSystemStorage) GetObject(hash string) (core.Object, error) {
 	path := fs.objectPath(hash)
@@ -124,70 +212,232 @@ func (fs *FileSystemStorage) GetObject(hash string) (core.Object, error) {
 	case core.BlobType:
 		return core.NewBlob(objData), nil
 	case core.TreeType:
-		// TODO: Implement Tree deserialization
-		return nil, fmt.Errorf("tree deserialization not implemented")
+		return core.DeserializeTree(objData)
 	case core.CommitType:
 		return core.DeserializeCommit(objData)
+	case core.TagType:
+		return core.DeserializeTag(objData)
 	default:
 		return nil, fmt.Errorf("unknown object type: %s", objType)
 	}
 }
 
+// readRef reads a ref file without following it
+// @return string, bool, error The hash or, for a symbolic ref, the name of the ref it points to, whether the ref is symbolic,
+// and nil on success. A missing ref yields an empty value and no error
+func (fs *FileSystemStorage) readRef(name string) (string, bool, error) {
+	path := fs.refPath(name)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// A directory holds nested refs, it is not a ref itself
+		if info, statErr := os.Stat(path); os.IsNotExist(err) || (statErr == nil && info.IsDir()) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	content := strings.TrimSpace(string(data))
+	if target, ok := strings.CutPrefix(content, SymbolicRefPrefix); ok {
+		return target, true, nil
+	}
+
+	return content, false, nil
+}
+
+// writeRef writes a ref file, creating the directories of a nested name
+// @dev A ref cannot be both a file and a directory, so "a" and "a/b" cannot exist at the same time
+func (fs *FileSystemStorage) writeRef(name string, content string) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
+
+	path := fs.refPath(name)
+	yagDir := filepath.Join(fs.rootPath, YAGDir)
+
+	// Check every parent directory so a clash is reported by ref name rather than as a file system error
+	for dir := filepath.Dir(path); dir != yagDir && dir != "."; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(yagDir, dir)
+			return fmt.Errorf("cannot create ref '%s': '%s' exists", name, filepath.ToSlash(rel))
+		}
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("cannot create ref '%s': there are refs below it", name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// followRef follows symbolic refs from name to the ref that holds a hash
+// @return string, error The qualified name at the end of the chain, which may not exist yet (e.g. an unborn branch)
+func (fs *FileSystemStorage) followRef(name string) (string, error) {
+	name = qualifyRef(name)
+
+	for depth := 0; depth < maxSymbolicRefDepth; depth++ {
+		value, symbolic, err := fs.readRef(name)
+		if err != nil {
+			return "", err
+		}
+		if !symbolic {
+			return name, nil
+		}
+		name = value
+	}
+
+	return "", fmt.Errorf("too many levels of symbolic refs at %s", name)
+}
+
 // UpdateRef updates a reference (like a branch) to point to a commit
+// @dev Symbolic refs are followed, so updating one moves the ref it points to
 func (fs *FileSystemStorage) UpdateRef(name string, commitHash string) error {
-	refPath := fs.refPath(name)
+	if err := checkRefName(name); err != nil {
+		return err
+	}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(refPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	target, err := fs.followRef(name)
+	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(refPath, []byte(commitHash), 0644)
+	return fs.writeRef(target, commitHash)
 }
 
-// GetRef gets the commit hash that a reference points to
+// GetRef gets the commit hash that a reference points to, following symbolic refs
 func (fs *FileSystemStorage) GetRef(name string) (string, error) {
-	refPath := fs.refPath(name)
+	target, err := fs.followRef(name)
+	if err != nil {
+		return "", err
+	}
 
-	data, err := os.ReadFile(refPath)
+	hash, _, err := fs.readRef(target)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return "", fmt.Errorf("reference %s not found", name)
-		}
 		return "", err
 	}
+	if hash == "" {
+		return "", fmt.Errorf("reference %s not found", name)
+	}
 
-	return string(data), nil
+	return hash, nil
 }
 
-// ListRefs lists all references (branches)
-func (fs *FileSystemStorage) ListRefs() (map[string]string, error) {
-	refsDir := filepath.Join(fs.rootPath, YAGDir, RefsDir, HeadsDir)
+// UpdateSymbolicRef makes a ref point to another ref
+func (fs *FileSystemStorage) UpdateSymbolicRef(name string, target string) error {
+	if err := checkRefName(target); err != nil {
+		return err
+	}
+	return fs.writeRef(name, SymbolicRefPrefix+qualifyRef(target))
+}
 
-	// Read the refs directory
-	files, err := os.ReadDir(refsDir)
+// GetSymbolicRef returns the qualified name of the ref a symbolic ref points to
+func (fs *FileSystemStorage) GetSymbolicRef(name string) (string, error) {
+	value, symbolic, err := fs.readRef(name)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return make(map[string]string), nil
+		return "", err
+	}
+	if !symbolic {
+		return "", nil
+	}
+	return value, nil
+}
+
+// DeleteRef removes a reference
+// @dev A symbolic ref is removed itself rather than the ref it points to. Directories left empty are pruned,
+// but namespace directories such as refs/heads are kept
+func (fs *FileSystemStorage) DeleteRef(name string) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
+
+	path := fs.refPath(name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		if err == nil || os.IsNotExist(err) {
+			return fmt.Errorf("reference %s not found", name)
 		}
-		return nil, err
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	qualified := qualifyRef(name)
+	pruneEmptyDirs(filepath.Dir(path), filepath.Join(fs.rootPath, YAGDir, namespaceDir(qualified)))
+
+	// The ref's history goes with it
+	logPath := fs.reflogPath(qualified)
+	if err := os.Remove(logPath); err != nil && !os.IsNotExist(err) {
+		return err
 	}
+	pruneEmptyDirs(filepath.Dir(logPath), filepath.Join(fs.rootPath, YAGDir, LogsDir, namespaceDir(qualified)))
 
+	return nil
+}
+
+// namespaceDir returns the namespace directory of a qualified ref name, e.g. "refs/heads" for "refs/heads/feature/login"
+func namespaceDir(qualified string) string {
+	parts := strings.SplitN(qualified, "/", 3)
+	if len(parts) < 3 {
+		return filepath.Dir(filepath.FromSlash(qualified))
+	}
+	return filepath.Join(parts[0], parts[1])
+}
+
+// pruneEmptyDirs removes dir and its parents while they are empty, never removing keep or anything above it
+func pruneEmptyDirs(dir, keep string) {
+	for ; strings.HasPrefix(dir, keep+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		// Removing a directory that still has entries fails, which ends the pruning
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// ListRefsWithPrefix lists every ref whose fully qualified name starts with prefix
+func (fs *FileSystemStorage) ListRefsWithPrefix(prefix string) (map[string]string, error) {
+	yagDir := filepath.Join(fs.rootPath, YAGDir)
 	refs := make(map[string]string)
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	err := filepath.WalkDir(filepath.Join(yagDir, RefsDir), func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
 		}
 
-		path := filepath.Join(refsDir, file.Name())
-		data, err := os.ReadFile(path)
+		rel, err := filepath.Rel(yagDir, path)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
 		}
 
-		refs[file.Name()] = string(data)
+		// Symbolic refs are listed with the hash they resolve to; dangling ones are skipped
+		target, err := fs.followRef(name)
+		if err != nil {
+			return err
+		}
+		hash, _, err := fs.readRef(target)
+		if err != nil {
+			return err
+		}
+		if hash != "" {
+			refs[name] = hash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return refs, nil
@@ -195,63 +445,124 @@ func (fs *FileSystemStorage) ListRefs() (map[string]string, error) {
 
 // GetHead returns the current HEAD reference
 func (fs *FileSystemStorage) GetHead() (string, error) {
-	headPath := filepath.Join(fs.rootPath, YAGDir, HeadFile)
-
-	data, err := os.ReadFile(headPath)
+	target, err := fs.GetSymbolicRef(HeadFile)
 	if err != nil {
 		return "", err
 	}
 
-	headContent := string(data)
-
-	// If HEAD is a symbolic ref (points to a branch)
-	if strings.HasPrefix(headContent, "ref: ") {
-		// Extract branch name
-		return strings.TrimPrefix(headContent, "ref: refs/heads/"), nil
-	}
-
-	// If HEAD is detached (points directly to a commit)
-	return "", nil
+	// A detached HEAD holds a commit hash rather than naming a branch
+	return strings.TrimPrefix(target, RefsDir+"/"+HeadsDir+"/"), nil
 }
 
 // SetHead sets the HEAD reference
 func (fs *FileSystemStorage) SetHead(ref string) error {
-	headPath := filepath.Join(fs.rootPath, YAGDir, HeadFile)
-	content := "ref: refs/heads/" + ref
-	return os.WriteFile(headPath, []byte(content), 0644)
+	return fs.UpdateSymbolicRef(HeadFile, ref)
 }
 
-// GetHeadCommit returns the commit that HEAD points to
-func (fs *FileSystemStorage) GetHeadCommit() (*core.Commit, error) {
-	headPath := filepath.Join(fs.rootPath, YAGDir, HeadFile)
+// DetachHead points HEAD directly at a commit instead of a branch
+func (fs *FileSystemStorage) DetachHead(commitHash string) error {
+	return fs.writeRef(HeadFile, commitHash)
+}
+
+// reflogPath returns the path to the reflog of a ref
+func (fs *FileSystemStorage) reflogPath(name string) string {
+	return filepath.Join(fs.rootPath, YAGDir, LogsDir, filepath.FromSlash(qualifyRef(name)))
+}
+
+// AppendReflog records a change of a ref at the end of its reflog
+// @dev Each entry is one line: "<old> <new> <time> <identity>\t<reason>", with ZeroHash standing in for a missing hash
+func (fs *FileSystemStorage) AppendReflog(name string, entry ReflogEntry) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
 
-	data, err := os.ReadFile(headPath)
+	path := fs.reflogPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer file.Close()
+
+	// Tabs and newlines would break the line format
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	line := fmt.Sprintf("%s %s %s %s\t%s\n",
+		orZeroHash(entry.OldHash),
+		orZeroHash(entry.NewHash),
+		entry.Timestamp.Format(time.RFC3339),
+		clean.Replace(entry.Identity),
+		clean.Replace(entry.Reason))
+
+	_, err = file.WriteString(line)
+	return err
+}
 
-	headContent := string(data)
+// ReadReflog returns the recorded changes of a ref, oldest first
+func (fs *FileSystemStorage) ReadReflog(name string) ([]ReflogEntry, error) {
+	data, err := os.ReadFile(fs.reflogPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-	var commitHash string
+	var entries []ReflogEntry
+	for lineNo, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
 
-	// If HEAD is a symbolic ref (points to a branch)
-	if strings.HasPrefix(headContent, "ref: ") {
-		branchPath := strings.TrimPrefix(headContent, "ref: ")
-		branchPath = filepath.Join(fs.rootPath, YAGDir, branchPath)
+		header, reason, _ := strings.Cut(line, "\t")
+		fields := strings.SplitN(header, " ", 4)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("corrupt reflog for %s at line %d", name, lineNo+1)
+		}
 
-		// Read the commit hash from the branch file
-		hashData, err := os.ReadFile(branchPath)
+		timestamp, err := time.Parse(time.RFC3339, fields[2])
 		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, nil // Branch exists but has no commits
-			}
-			return nil, err
+			return nil, fmt.Errorf("corrupt reflog for %s at line %d: %v", name, lineNo+1, err)
 		}
 
-		commitHash = string(hashData)
-	} else {
-		// If HEAD is detached (points directly to a commit)
-		commitHash = headContent
+		entry := ReflogEntry{
+			OldHash:   strings.TrimPrefix(fields[0], ZeroHash),
+			NewHash:   strings.TrimPrefix(fields[1], ZeroHash),
+			Timestamp: timestamp,
+			Reason:    reason,
+		}
+		if len(fields) == 4 {
+			entry.Identity = fields[3]
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// orZeroHash returns hash, or ZeroHash when it is empty
+func orZeroHash(hash string) string {
+	if hash == "" {
+		return ZeroHash
+	}
+	return hash
+}
+
+// GetHeadCommit returns the commit that HEAD points to
+func (fs *FileSystemStorage) GetHeadCommit() (*core.Commit, error) {
+	target, err := fs.followRef(HeadFile)
+	if err != nil {
+		return nil, err
+	}
+
+	commitHash, _, err := fs.readRef(target)
+	if err != nil {
+		return nil, err
+	}
+	if commitHash == "" {
+		return nil, nil // Branch exists but has no commits
 	}
 
 	// Get the commit object
@@ -334,3 +645,84 @@ func (fs *FileSystemStorage) ClearIndex() error {
 	indexPath := filepath.Join(fs.rootPath, YAGDir, IndexFile)
 	return os.WriteFile(indexPath, []byte("{}"), 0644)
 }
+
+// GetIndexConflicts returns the unmerged paths recorded in the staging area
+// @dev Conflicts live in their own file next to the index so the index keeps its flat path-to-hash format
+func (fs *FileSystemStorage) GetIndexConflicts() (map[string]IndexConflict, error) {
+	conflictsPath := filepath.Join(fs.rootPath, YAGDir, ConflictsFile)
+
+	conflicts := make(map[string]IndexConflict)
+	data, err := os.ReadFile(conflictsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conflicts, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &conflicts); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ConflictsFile, err)
+	}
+
+	return conflicts, nil
+}
+
+// UpdateIndexConflicts replaces the unmerged paths recorded in the staging area
+func (fs *FileSystemStorage) UpdateIndexConflicts(conflicts map[string]IndexConflict) error {
+	conflictsPath := filepath.Join(fs.rootPath, YAGDir, ConflictsFile)
+
+	// No conflicts left: drop the file altogether
+	if len(conflicts) == 0 {
+		if err := os.Remove(conflictsPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(conflicts)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(conflictsPath, data, 0644)
+}
+
+// statePath returns the path to a state file
+func (fs *FileSystemStorage) statePath(name string) string {
+	return filepath.Join(fs.rootPath, YAGDir, name)
+}
+
+// ReadState reads a named piece of operation state
+func (fs *FileSystemStorage) ReadState(name string) (string, error) {
+	data, err := os.ReadFile(fs.statePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// WriteState stores a named piece of operation state
+func (fs *FileSystemStorage) WriteState(name string, content string) error {
+	path := fs.statePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// RemoveState deletes a named piece of operation state
+// @dev Directories grouping state entries, such as sequencer/, are removed with their last entry
+func (fs *FileSystemStorage) RemoveState(name string) error {
+	path := fs.statePath(name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	pruneEmptyDirs(filepath.Dir(path), filepath.Join(fs.rootPath, YAGDir))
+	return nil
+}
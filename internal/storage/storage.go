@@ -6,9 +6,32 @@
 package storage
 
 import (
+	"strings"
+	"time"
+
 	"github.com/xhad/yag/internal/core"
 )
 
+// IndexConflict records the versions of a path that a merge could not combine automatically
+// @notice Mirrors Git's index stages: Base is stage 1, Ours stage 2 and Theirs stage 3
+// @dev An empty hash means the path does not exist in that version
+type IndexConflict struct {
+	Base   string `json:"base,omitempty"`   // Blob hash in the common ancestor
+	Ours   string `json:"ours,omitempty"`   // Blob hash in the current branch
+	Theirs string `json:"theirs,omitempty"` // Blob hash in the branch being merged
+}
+
+// ReflogEntry records one change of a ref
+// @notice Reflogs keep the previous values of HEAD and branches so a bad reset or branch move can be undone
+// @dev An empty hash means the ref did not exist (OldHash) or was unborn
+type ReflogEntry struct {
+	OldHash   string    // Hash the ref pointed to before the change
+	NewHash   string    // Hash the ref points to after the change
+	Identity  string    // Who made the change
+	Timestamp time.Time // When the change was made
+	Reason    string    // What made the change, e.g. "commit: Fix typo" or "checkout: moving from master to feature"
+}
+
 // Storage is an interface for storing and retrieving YAG objects
 // @notice Defines the contract for any storage implementation in YAG
 // @dev Any storage implementation (filesystem, database, etc.) must implement this interface
@@ -30,6 +53,12 @@ type Storage interface {
 	// @return core.Object, error Returns the object and nil on success, or nil and an error if retrieval fails
 	GetObject(hash string) (core.Object, error)
 
+	// FindObjects lists the stored objects whose hash starts with a prefix
+	// @notice Used to expand abbreviated hashes
+	// @param prefix The leading characters of the hash
+	// @return []string, error Returns the matching hashes in sorted order, or an error if listing fails
+	FindObjects(prefix string) ([]string, error)
+
 	// HasObject checks if an object exists in storage
 	// @notice Checks for the existence of an object without retrieving it fully
 	// @param hash The object ID/hash to check
@@ -38,25 +67,58 @@ type Storage interface {
 
 	// UpdateRef updates a reference (like a branch) to point to a commit
 	// @notice Changes where a named reference points to
+	// @dev Short names are branches; fully qualified names such as "refs/tags/v1" select another namespace.
+	// Names can be nested ("feature/login"), and a symbolic ref is followed so the ref it points to moves
 	// @param name The name of the reference to update
 	// @param commitHash The commit hash the reference should point to
 	// @return error Returns nil on success or an error if the update fails
 	UpdateRef(name string, commitHash string) error
 
 	// GetRef gets the commit hash that a reference points to
-	// @notice Retrieves the commit hash that a named reference points to
-	// @param name The name of the reference to query
+	// @notice Retrieves the commit hash that a named reference points to, following symbolic refs
+	// @param name The name of the reference to query, short for branches or fully qualified
 	// @return string, error Returns the commit hash and nil on success, or an empty string and an error if retrieval fails
 	GetRef(name string) (string, error)
 
-	// ListRefs lists all references (branches)
-	// @notice Gets all named references and their target commit hashes
-	// @return map[string]string, error Returns a map of reference names to commit hashes, or an error if listing fails
-	ListRefs() (map[string]string, error)
+	// UpdateSymbolicRef makes a reference point to another reference instead of a commit
+	// @notice HEAD is the usual symbolic ref: on a branch it points to refs/heads/<branch>
+	// @param name The name of the symbolic reference, e.g. "HEAD"
+	// @param target The reference it should point to, short for branches or fully qualified
+	// @return error Returns nil on success or an error if the update fails
+	UpdateSymbolicRef(name string, target string) error
+
+	// GetSymbolicRef returns the reference a symbolic reference points to
+	// @param name The name of the symbolic reference
+	// @return string, error Returns the fully qualified target name, or an empty string if the reference is not symbolic
+	GetSymbolicRef(name string) (string, error)
+
+	// DeleteRef removes a reference and its reflog
+	// @dev Deleting a symbolic reference removes it rather than the reference it points to
+	// @param name The name of the reference to delete, short for branches or fully qualified
+	// @return error Returns nil on success or an error if the reference does not exist or cannot be removed
+	DeleteRef(name string) error
+
+	// ListRefsWithPrefix lists the references whose fully qualified name starts with a prefix
+	// @notice ListRefsWithPrefix("refs/") lists every reference, ListRefsWithPrefix("refs/heads/feature/") one group of branches
+	// @param prefix The leading part of the fully qualified names to list
+	// @return map[string]string, error Returns a map of fully qualified names to hashes, or an error if listing fails
+	ListRefsWithPrefix(prefix string) (map[string]string, error)
+
+	// AppendReflog records a change of a reference in its reflog
+	// @notice Reflogs are append-only; updating a reference does not write one by itself
+	// @param name The name of the reference, short for branches or fully qualified, or "HEAD"
+	// @param entry The change to record
+	// @return error Returns nil on success or an error if writing fails
+	AppendReflog(name string, entry ReflogEntry) error
+
+	// ReadReflog returns the recorded changes of a reference
+	// @param name The name of the reference, short for branches or fully qualified, or "HEAD"
+	// @return []ReflogEntry, error Returns the entries oldest first (none if the reference has no reflog), or an error if reading fails
+	ReadReflog(name string) ([]ReflogEntry, error)
 
 	// GetHead returns the current HEAD reference
 	// @notice Gets the current HEAD reference (usually a branch name)
-	// @return string, error Returns the HEAD reference and nil on success, or an empty string and an error if retrieval fails
+	// @return string, error Returns the HEAD reference and nil on success, an empty string if HEAD is detached, or an empty string and an error if retrieval fails
 	GetHead() (string, error)
 
 	// SetHead sets the HEAD reference
@@ -65,6 +127,12 @@ type Storage interface {
 	// @return error Returns nil on success or an error if the update fails
 	SetHead(ref string) error
 
+	// DetachHead points HEAD directly at a commit instead of a branch
+	// @notice Used to check out arbitrary commits; new commits then move HEAD itself
+	// @param commitHash The commit HEAD should point to
+	// @return error Returns nil on success or an error if the update fails
+	DetachHead(commitHash string) error
+
 	// GetHeadCommit returns the commit that HEAD points to
 	// @notice Resolves HEAD to a commit object
 	// @return *core.Commit, error Returns the commit object and nil on success, or nil and an error if resolution fails
@@ -92,4 +160,53 @@ type Storage interface {
 	// @notice Removes all entries from the staging area
 	// @return error Returns nil on success or an error if clearing fails
 	ClearIndex() error
+
+	// GetIndexConflicts returns the unmerged paths recorded in the staging area
+	// @notice Gets the base/ours/theirs versions of every path left conflicted by a merge
+	// @return map[string]IndexConflict, error Returns a map of file paths to their conflicting versions, or an error if retrieval fails
+	GetIndexConflicts() (map[string]IndexConflict, error)
+
+	// UpdateIndexConflicts replaces the unmerged paths recorded in the staging area
+	// @notice An empty map marks every conflict as resolved
+	// @param conflicts A map of file paths to their conflicting versions
+	// @return error Returns nil on success or an error if the update fails
+	UpdateIndexConflicts(conflicts map[string]IndexConflict) error
+
+	// ReadState reads a named piece of operation state, such as the commit being merged
+	// @notice Used to remember in-progress operations between commands
+	// @param name The name of the state entry, e.g. MergeHeadFile; names may contain "/" to group entries
+	// @return string, error Returns the stored content, or an empty string if the entry does not exist
+	ReadState(name string) (string, error)
+
+	// WriteState stores a named piece of operation state
+	// @param name The name of the state entry
+	// @param content The content to store
+	// @return error Returns nil on success or an error if writing fails
+	WriteState(name string, content string) error
+
+	// RemoveState deletes a named piece of operation state
+	// @param name The name of the state entry
+	// @return error Returns nil on success (including when the entry does not exist) or an error if removal fails
+	RemoveState(name string) error
+}
+
+// ListRefsIn lists the references in one namespace
+// @notice ListRefsIn(s, HeadsDir) lists branches, ListRefsIn(s, TagsDir) lists tags
+// @param s The storage to list from
+// @param namespace The directory under refs/ to list
+// @return map[string]string, error Returns a map of reference names relative to the namespace to hashes, or an error if listing fails
+func ListRefsIn(s Storage, namespace string) (map[string]string, error) {
+	prefix := RefsDir + "/" + namespace + "/"
+
+	qualified, err := s.ListRefsWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]string, len(qualified))
+	for name, hash := range qualified {
+		refs[strings.TrimPrefix(name, prefix)] = hash
+	}
+
+	return refs, nil
 }
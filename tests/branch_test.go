@@ -188,3 +188,98 @@ func TestBranchCommand(t *testing.T) {
 		t.Errorf("BranchCommand should fail in a repository with no commits")
 	}
 }
+
+// TestBranchDeleteAndRename tests deleting branches with the unmerged-work check and renaming branches
+func TestBranchDeleteAndRename(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	base := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "Base")
+	for _, name := range []string{"merged", "experiment"} {
+		if err := repo.CreateBranch(name); err != nil {
+			t.Fatalf("Failed to create branch %s: %v", name, err)
+		}
+	}
+
+	// Give experiment a commit master does not have
+	if err := repo.Checkout("experiment", false); err != nil {
+		t.Fatalf("Failed to checkout experiment: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "wip\n"}, "Experiment")
+
+	// The checked out branch cannot be deleted
+	if _, err := repo.DeleteBranch("experiment", true); err == nil {
+		t.Errorf("Expected deleting the current branch to fail")
+	}
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+
+	// Unmerged work needs force
+	if _, err := repo.DeleteBranch("experiment", false); err == nil || !strings.Contains(err.Error(), "not fully merged") {
+		t.Errorf("Expected an unmerged branch error, got %v", err)
+	}
+
+	output, err := CaptureOutput(t, func() error {
+		return commands.BranchCommandWithOptions([]string{"merged"}, commands.BranchOptions{Delete: true})
+	})
+	if err != nil {
+		t.Fatalf("Deleting a merged branch failed: %v", err)
+	}
+	if !strings.Contains(output, "Deleted branch merged (was "+base[:8]+").") {
+		t.Errorf("Expected a deletion message, got %q", output)
+	}
+
+	if _, err := CaptureOutput(t, func() error {
+		return commands.BranchCommandWithOptions([]string{"experiment"}, commands.BranchOptions{Delete: true, Force: true})
+	}); err != nil {
+		t.Fatalf("Forced deletion failed: %v", err)
+	}
+	if _, err := repo.DeleteBranch("experiment", true); err == nil {
+		t.Errorf("Expected deleting a missing branch to fail")
+	}
+
+	// Renaming the current branch moves HEAD along
+	if err := repo.CreateBranch("other"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	output, err = CaptureOutput(t, func() error {
+		return commands.BranchCommandWithOptions([]string{"main"}, commands.BranchOptions{Rename: true})
+	})
+	if err != nil {
+		t.Fatalf("Renaming the current branch failed: %v", err)
+	}
+	if !strings.Contains(output, "Renamed branch 'master' to 'main'") {
+		t.Errorf("Expected a rename message, got %q", output)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "main" {
+		t.Errorf("Expected HEAD to follow the rename, got %q", branch)
+	}
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+	if hash, _ := repo.GetStorage().GetRef("main"); hash != second {
+		t.Errorf("Expected commits to advance the renamed branch")
+	}
+
+	// Renaming onto an existing branch needs force
+	if err := repo.RenameBranch("other", "main", false); err == nil {
+		t.Errorf("Expected renaming onto an existing branch to fail")
+	}
+	if err := repo.RenameBranch("other", "topic/other", false); err != nil {
+		t.Fatalf("Failed to rename other branch: %v", err)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "main" {
+		t.Errorf("Renaming another branch must not move HEAD, got %q", branch)
+	}
+
+	// Verbose listing shows each tip and subject
+	output, err = CaptureOutput(t, func() error {
+		return commands.BranchCommandWithOptions(nil, commands.BranchOptions{Verbose: true})
+	})
+	if err != nil {
+		t.Fatalf("Verbose listing failed: %v", err)
+	}
+	expected := "* main        " + second[:8] + " Second\n" +
+		"  topic/other " + base[:8] + " Base\n"
+	if output != expected {
+		t.Errorf("Expected verbose listing\n%q\ngot\n%q", expected, output)
+	}
+}
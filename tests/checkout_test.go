@@ -158,3 +158,226 @@ func TestCheckoutCommand(t *testing.T) {
 		t.Errorf("CheckoutCommand should fail with empty branch name")
 	}
 }
+
+// TestCheckoutUpdatesWorkingTree tests that checkout materializes the target branch on disk and in the index
+func TestCheckoutUpdatesWorkingTree(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	// Commit two files on master
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		"shared.txt":        "master version",
+		"docs/old_file.txt": "only on master",
+	}, "Initial commit")
+
+	if err := repo.CreateBranch("feature"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+
+	// On feature: change shared.txt, drop the docs file and add a new one
+	if err := os.RemoveAll(filepath.Join(tempDir, "docs")); err != nil {
+		t.Fatalf("Failed to remove docs directory: %v", err)
+	}
+	if err := repo.Add("docs"); err != nil {
+		t.Fatalf("Failed to stage removal of docs: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		"shared.txt":       "feature version",
+		"feature_only.txt": "only on feature",
+	}, "Feature work")
+
+	// Switching back to master restores its snapshot
+	if err := repo.Checkout(storage.DefaultBranch, false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != "master version" {
+		t.Errorf("shared.txt should contain master version, got %q", got)
+	}
+	if got := ReadTestFile(t, tempDir, filepath.Join("docs", "old_file.txt")); got != "only on master" {
+		t.Errorf("docs/old_file.txt should be restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "feature_only.txt")); !os.IsNotExist(err) {
+		t.Errorf("feature_only.txt should be deleted when switching to master")
+	}
+
+	// The index matches master's tree
+	index, err := repo.GetStorage().GetIndexEntries()
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if _, ok := index["feature_only.txt"]; ok {
+		t.Errorf("Index should not contain feature_only.txt after checking out master")
+	}
+	if _, ok := index[filepath.Join("docs", "old_file.txt")]; !ok {
+		t.Errorf("Index should contain docs/old_file.txt after checking out master")
+	}
+
+	// Local modifications that checkout would overwrite are protected
+	WriteTestFile(t, tempDir, "shared.txt", "uncommitted edit")
+	err = repo.Checkout("feature", false)
+	if err == nil {
+		t.Fatalf("Checkout should refuse to overwrite local modifications")
+	}
+	if !strings.Contains(err.Error(), "shared.txt") {
+		t.Errorf("Error should name the modified file, got: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != "uncommitted edit" {
+		t.Errorf("Refused checkout must leave the local edit alone, got %q", got)
+	}
+
+	// Forcing the checkout discards them
+	if err := commands.CheckoutCommandWithOptions("feature", commands.CheckoutOptions{Force: true}); err != nil {
+		t.Fatalf("Forced checkout failed: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != "feature version" {
+		t.Errorf("shared.txt should contain feature version after forced checkout, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "docs")); !os.IsNotExist(err) {
+		t.Errorf("Empty docs directory should be pruned on feature")
+	}
+}
+
+// TestCheckoutDetachedHead tests checking out a commit, committing on top of it and reporting the detached state
+func TestCheckoutDetachedHead(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+
+	// Checking out a commit detaches HEAD
+	output, err := CaptureOutput(t, func() error { return commands.CheckoutCommand(first[:10]) })
+	if err != nil {
+		t.Fatalf("Checkout of a commit failed: %v", err)
+	}
+	if !strings.Contains(output, "HEAD is now at "+first[:8]) {
+		t.Errorf("Expected a detached HEAD message, got %q", output)
+	}
+	if detached, _ := repo.IsDetached(); !detached {
+		t.Fatalf("Expected HEAD to be detached")
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "one\n" {
+		t.Errorf("Expected the working tree to match the checked out commit")
+	}
+
+	output, err = CaptureOutput(t, func() error { return commands.StatusCommand(nil) })
+	if err != nil || !strings.Contains(output, "HEAD detached at "+first[:8]) {
+		t.Errorf("Expected status to report the detached HEAD, got %q (%v)", output, err)
+	}
+
+	// Committing moves HEAD itself and leaves master alone
+	third := CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b\n"}, "Detached work")
+	if head, _ := repo.GetStorage().GetHeadCommit(); head.ID() != third || head.ParentHash() != first {
+		t.Errorf("Expected HEAD to move to the new commit on top of %s", first)
+	}
+	if master, _ := repo.GetStorage().GetRef("master"); master != second {
+		t.Errorf("Expected master to stay at %s, got %s", second, master)
+	}
+	if _, err := repo.GetStorage().GetRef(""); err == nil {
+		t.Errorf("Expected no ref to be written for a detached commit")
+	}
+
+	output, err = CaptureOutput(t, func() error { return commands.BranchCommand(nil) })
+	if err != nil || !strings.Contains(output, "* (HEAD detached at "+third[:8]+")") {
+		t.Errorf("Expected the branch list to show the detached HEAD, got %q (%v)", output, err)
+	}
+
+	// A branch created here keeps the detached work; --detach works on branch names too
+	if err := repo.CreateBranch("rescue"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := commands.CheckoutCommandWithOptions("master", commands.CheckoutOptions{Detach: true}); err != nil {
+		t.Fatalf("Checkout --detach failed: %v", err)
+	}
+	if head, _ := repo.GetStorage().GetHeadCommit(); head.ID() != second {
+		t.Errorf("Expected HEAD at master's commit")
+	}
+	if detached, _ := repo.IsDetached(); !detached {
+		t.Errorf("Expected HEAD to stay detached with --detach")
+	}
+
+	if err := repo.Checkout("rescue", false); err != nil {
+		t.Fatalf("Failed to checkout rescue: %v", err)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "rescue" {
+		t.Errorf("Expected to be on rescue, got %q", branch)
+	}
+	if ReadTestFile(t, tempDir, "b.txt") != "b\n" {
+		t.Errorf("Expected the detached work on the rescue branch")
+	}
+}
+
+// TestCheckoutNewBranch tests creating branches at a start point, with and without switching to them
+func TestCheckoutNewBranch(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	// Right after init the new branch is unborn until the first commit
+	output, err := CaptureOutput(t, func() error {
+		return commands.CheckoutCommandWithOptions("", commands.CheckoutOptions{NewBranch: "main"})
+	})
+	if err != nil {
+		t.Fatalf("checkout -b in a fresh repository failed: %v", err)
+	}
+	if !strings.Contains(output, "Switched to a new branch 'main'") {
+		t.Errorf("Expected a switch message, got %q", output)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "main" {
+		t.Errorf("Expected to be on main, got %q", branch)
+	}
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+
+	branches, err := repo.ListBranches()
+	if err != nil {
+		t.Fatalf("Failed to list branches: %v", err)
+	}
+	if strings.Join(branches, ",") != "main" {
+		t.Errorf("Expected only main to exist, got %v", branches)
+	}
+
+	// A branch can start at any commit without switching to it
+	if _, err := CaptureOutput(t, func() error { return commands.BranchCommand([]string{"old", "HEAD~1"}) }); err != nil {
+		t.Fatalf("Creating a branch at a start point failed: %v", err)
+	}
+	if hash, _ := repo.GetStorage().GetRef("old"); hash != first {
+		t.Errorf("Expected old at %s, got %s", first, hash)
+	}
+	if err := repo.CreateBranchAt("old", ""); err == nil {
+		t.Errorf("Expected creating an existing branch to fail")
+	}
+	if err := repo.CreateBranchAt("bad", "no-such-rev"); err == nil {
+		t.Errorf("Expected an unknown start point to fail")
+	}
+
+	// checkout -b with a start point switches the working tree too
+	if _, err := CaptureOutput(t, func() error {
+		return commands.CheckoutCommandWithOptions(first[:10], commands.CheckoutOptions{NewBranch: "fix"})
+	}); err != nil {
+		t.Fatalf("checkout -b with a start point failed: %v", err)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "fix" {
+		t.Errorf("Expected to be on fix, got %q", branch)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "one\n" {
+		t.Errorf("Expected the working tree to match the start point")
+	}
+
+	// A refused checkout leaves no branch behind
+	WriteTestFile(t, tempDir, "a.txt", "local edit\n")
+	if err := repo.CheckoutNewBranch("refused", second, false); err == nil {
+		t.Fatalf("Expected checkout -b to refuse overwriting local changes")
+	}
+	if _, err := repo.GetStorage().GetRef("refused"); err == nil {
+		t.Errorf("A refused checkout -b must not create the branch")
+	}
+
+	// Without a start point local changes are carried over to the new branch
+	if err := repo.CheckoutNewBranch("carry", "", false); err != nil {
+		t.Fatalf("checkout -b at HEAD failed: %v", err)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "local edit\n" {
+		t.Errorf("Expected local changes to be kept")
+	}
+}
@@ -0,0 +1,315 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// commitAs rewrites the tip of a branch to carry another author, as if someone else had made the commit
+func commitAs(t *testing.T, repo *repository.Repository, branch, author string) string {
+	t.Helper()
+
+	store := repo.GetStorage()
+	tip, err := store.GetRef(branch)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", branch, err)
+	}
+	obj, err := store.GetObject(tip)
+	if err != nil {
+		t.Fatalf("Failed to read commit %s: %v", tip, err)
+	}
+	original := obj.(*core.Commit)
+
+	commit := core.NewCommit(original.TreeHash(), original.ParentHash(), original.Message(), author)
+	if err := store.StoreObject(commit); err != nil {
+		t.Fatalf("Failed to store commit: %v", err)
+	}
+	if err := store.UpdateRef(branch, commit.ID()); err != nil {
+		t.Fatalf("Failed to update %s: %v", branch, err)
+	}
+
+	return commit.ID()
+}
+
+// TestCherryPick tests replaying commits from another branch
+func TestCherryPick(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\ntwo\nthree\n", "b.txt": "b\n"}, "Base")
+	if err := repo.CheckoutNewBranch("feature", "", false); err != nil {
+		t.Fatalf("Failed to create feature: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"c.txt": "new\n"}, "Add c")
+	first := commitAs(t, repo, "feature", "alice")
+	if err := repo.Checkout("feature", true); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\nTWO\nthree\n"}, "Change two\n\nWith a body.")
+	second := commitAs(t, repo, "feature", "bob")
+
+	if err := repo.Checkout("master", true); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	mainline := CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b changed\n"}, "Change b")
+
+	outcome, err := repo.CherryPick([]string{"feature~1", "feature"})
+	if err != nil {
+		t.Fatalf("Cherry-pick failed: %v", err)
+	}
+	if outcome.Stopped != "" || len(outcome.Picks) != 2 {
+		t.Fatalf("Expected two clean picks, got %+v", outcome)
+	}
+
+	expected := []struct {
+		source  string
+		parent  string
+		author  string
+		message string
+	}{
+		{first, mainline, "alice", "Add c"},
+		{second, outcome.Picks[0].CommitHash, "bob", "Change two\n\nWith a body."},
+	}
+	for i, want := range expected {
+		pick := outcome.Picks[i]
+		if pick.Source != want.source {
+			t.Errorf("Pick %d: expected source %s, got %s", i, want.source[:8], pick.Source)
+		}
+		obj, err := repo.GetStorage().GetObject(pick.CommitHash)
+		if err != nil {
+			t.Fatalf("Failed to read pick %d: %v", i, err)
+		}
+		commit := obj.(*core.Commit)
+		if commit.ParentHash() != want.parent || commit.Author() != want.author || commit.Message() != want.message {
+			t.Errorf("Pick %d: expected parent %s, author %s and message %q, got %s, %s and %q",
+				i, want.parent[:8], want.author, want.message, commit.ParentHash(), commit.Author(), commit.Message())
+		}
+	}
+
+	if head, _ := repo.GetStorage().GetRef("master"); head != outcome.Picks[1].CommitHash {
+		t.Errorf("Expected master at the last pick, got %s", head)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "one\nTWO\nthree\n" || ReadTestFile(t, tempDir, "c.txt") != "new\n" {
+		t.Errorf("Expected the picked changes in the working tree")
+	}
+	if ReadTestFile(t, tempDir, "b.txt") != "b changed\n" {
+		t.Errorf("Expected master's own changes to be kept")
+	}
+	entries, _ := repo.Reflog("HEAD")
+	if entries[0].Reason != "cherry-pick: Change two" {
+		t.Errorf("Expected the pick in the reflog, got %q", entries[0].Reason)
+	}
+
+	// A commit whose changes are already in HEAD is dropped
+	outcome, err = repo.CherryPick([]string{first})
+	if err != nil {
+		t.Fatalf("Cherry-pick of an applied commit failed: %v", err)
+	}
+	if len(outcome.Picks) != 1 || outcome.Picks[0].CommitHash != "" {
+		t.Errorf("Expected the applied commit to be dropped, got %+v", outcome)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".yag", "sequencer")); !os.IsNotExist(err) {
+		t.Errorf("Expected the sequencer state to be removed")
+	}
+
+	if _, err := repo.CherryPick([]string{"no-such-rev"}); err == nil {
+		t.Errorf("Expected an unknown revision to fail")
+	}
+}
+
+// TestCherryPickConflict tests continuing, skipping and aborting a cherry-pick that stopped on conflicts
+func TestCherryPickConflict(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "Base")
+	if err := repo.CheckoutNewBranch("feature", "", false); err != nil {
+		t.Fatalf("Failed to create feature: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "feature\n"}, "Change a")
+	conflicting := commitAs(t, repo, "feature", "alice")
+	if err := repo.Checkout("feature", true); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	clean := CommitTestFiles(t, repo, tempDir, map[string]string{"d.txt": "d\n"}, "Add d")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	start := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "master\n"}, "Change a on master")
+
+	pick := func() {
+		t.Helper()
+		output, err := CaptureOutput(t, func() error {
+			return commands.CherryPickCommand([]string{"feature~1", "feature"}, commands.CherryPickOptions{})
+		})
+		if err == nil || !strings.Contains(err.Error(), "yag cherry-pick --continue") {
+			t.Fatalf("Expected the cherry-pick to stop on conflicts, got %v", err)
+		}
+		if !strings.Contains(output, "CONFLICT (content): Merge conflict in a.txt") {
+			t.Errorf("Expected the conflict to be reported, got %q", output)
+		}
+	}
+
+	pick()
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Picking != conflicting || len(status.Unmerged) != 1 {
+		t.Errorf("Expected a cherry-pick stopped on %s, got %+v", conflicting[:8], status)
+	}
+	if todo, _ := repo.GetStorage().ReadState(storage.SequencerTodoFile); strings.TrimSpace(todo) != clean {
+		t.Errorf("Expected %s left to pick, got %q", clean[:8], todo)
+	}
+	if _, err := repo.CherryPick([]string{"feature"}); err == nil {
+		t.Errorf("Expected a new cherry-pick to be refused while one is in progress")
+	}
+	if _, err := repo.CherryPickContinue(); err == nil {
+		t.Errorf("Expected continuing with unresolved conflicts to fail")
+	}
+
+	// Continuing commits the resolution as the original author, then picks the rest
+	WriteTestFile(t, tempDir, "a.txt", "resolved\n")
+	if err := repo.Add("a.txt"); err != nil {
+		t.Fatalf("Failed to add a.txt: %v", err)
+	}
+	outcome, err := repo.CherryPickContinue()
+	if err != nil {
+		t.Fatalf("Continue failed: %v", err)
+	}
+	if len(outcome.Picks) != 2 || outcome.Stopped != "" {
+		t.Fatalf("Expected both commits to be picked, got %+v", outcome)
+	}
+	obj, _ := repo.GetStorage().GetObject(outcome.Picks[0].CommitHash)
+	if commit := obj.(*core.Commit); commit.Author() != "alice" || commit.Message() != "Change a" || commit.ParentHash() != start {
+		t.Errorf("Expected the resolved pick to keep author and message, got %s %q", commit.Author(), commit.Message())
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "resolved\n" || ReadTestFile(t, tempDir, "d.txt") != "d\n" {
+		t.Errorf("Expected the resolution and the remaining pick in the working tree")
+	}
+	if status, _ = repo.Status(); status.Picking != "" || !status.IsClean() {
+		t.Errorf("Expected the cherry-pick to be concluded, got %+v", status)
+	}
+
+	// Skipping drops the conflicting commit and picks the rest
+	if err := repo.Reset(start, repository.ResetHard); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	pick()
+	if _, err := repo.CherryPickSkip(); err != nil {
+		t.Fatalf("Skip failed: %v", err)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "master\n" || ReadTestFile(t, tempDir, "d.txt") != "d\n" {
+		t.Errorf("Expected only the clean commit to be picked after skip")
+	}
+	if head, _ := repo.GetStorage().GetHeadCommit(); head.ParentHash() != start {
+		t.Errorf("Expected a single commit on top of %s after skip", start[:8])
+	}
+
+	// Aborting returns to where the cherry-pick started
+	if err := repo.Reset(start, repository.ResetHard); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	pick()
+	if err := commands.CherryPickCommand(nil, commands.CherryPickOptions{Abort: true}); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if head, _ := repo.GetStorage().GetRef("master"); head != start {
+		t.Errorf("Expected master back at %s, got %s", start[:8], head)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "master\n" {
+		t.Errorf("Expected a.txt restored after abort")
+	}
+	if status, _ = repo.Status(); status.Picking != "" || len(status.Unmerged) != 0 {
+		t.Errorf("Expected no cherry-pick after abort, got %+v", status)
+	}
+	if err := repo.CherryPickAbort(); err == nil {
+		t.Errorf("Expected aborting without a cherry-pick in progress to fail")
+	}
+}
+
+// TestCherryPickConcludedByCommit tests finishing a stopped cherry-pick with a plain commit
+func TestCherryPickConcludedByCommit(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "Base")
+	if err := repo.CheckoutNewBranch("topic", "", false); err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+	conflicting := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "topic\n"}, "Change a")
+	clean := CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b\n"}, "Add b")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "master\n"}, "Change a on master")
+
+	resolve := func(message string) {
+		t.Helper()
+		WriteTestFile(t, tempDir, "a.txt", "resolved\n")
+		if err := repo.Add("a.txt"); err != nil {
+			t.Fatalf("Failed to add a.txt: %v", err)
+		}
+		if _, err := repo.Commit(message); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+
+	// A single pick concluded by commit is finished
+	if outcome, err := repo.CherryPick([]string{conflicting}); err != nil || outcome.Stopped != conflicting {
+		t.Fatalf("Expected the cherry-pick to stop on %s, got %+v (%v)", conflicting[:8], outcome, err)
+	}
+	resolve("resolved")
+
+	if status, _ := repo.Status(); status.Picking != "" || status.PicksLeft != 0 || !status.IsClean() {
+		t.Errorf("Expected no cherry-pick after the commit, got %+v", status)
+	}
+	if head, _ := repo.GetStorage().ReadState(storage.SequencerHeadFile); head != "" {
+		t.Errorf("Expected the sequencer to be cleared, got start %q", head)
+	}
+	if err := repo.Checkout("topic", false); err != nil {
+		t.Fatalf("Expected checkout to work after the pick was committed, got %v", err)
+	}
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+
+	// With commits left, the commit only concludes the stopped one and status reports the rest
+	if err := repo.Reset("HEAD~1", repository.ResetHard); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if outcome, err := repo.CherryPick([]string{conflicting, clean}); err != nil || outcome.Stopped != conflicting {
+		t.Fatalf("Expected the cherry-pick to stop on %s, got %+v (%v)", conflicting[:8], outcome, err)
+	}
+	resolve("resolved again")
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Picking != "" || status.PicksLeft != 1 {
+		t.Errorf("Expected one commit left to pick, got %+v", status)
+	}
+	output, err := CaptureOutput(t, func() error { return commands.StatusCommand(nil) })
+	if err != nil || !strings.Contains(output, "cherry-pick with 1 commit left to replay") || strings.Contains(output, "working tree clean") {
+		t.Errorf("Expected status to report the commit left to replay, got %q (%v)", output, err)
+	}
+	if err := repo.Checkout("topic", false); err == nil {
+		t.Errorf("Expected checkout to be refused while commits are left to pick")
+	}
+
+	outcome, err := repo.CherryPickContinue()
+	if err != nil {
+		t.Fatalf("Continue failed: %v", err)
+	}
+	if len(outcome.Picks) != 1 || outcome.Picks[0].Source != clean || ReadTestFile(t, tempDir, "b.txt") != "b\n" {
+		t.Errorf("Expected the remaining commit to be picked, got %+v", outcome)
+	}
+	if err := repo.Checkout("topic", false); err != nil {
+		t.Errorf("Expected checkout to work once the cherry-pick is done, got %v", err)
+	}
+}
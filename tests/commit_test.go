@@ -1,14 +1,18 @@
 package tests
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/xhad/yag/internal/commands"
 	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
 	"github.com/xhad/yag/internal/storage"
 )
 
@@ -141,7 +145,10 @@ func TestCommitCommand(t *testing.T) {
 		t.Errorf("CommitCommand should fail with empty commit message")
 	}
 
-	// Test empty index case (using command directly)
+	// Test empty index case (using command directly); with a commit on the branch it would record deleting every file
+	if err := os.Remove(masterRefPath); err != nil {
+		t.Fatalf("Failed to remove master ref file: %v", err)
+	}
 	if err := os.WriteFile(indexPath, []byte("{}"), 0644); err != nil {
 		t.Fatalf("Failed to write empty index file: %v", err)
 	}
@@ -215,3 +222,184 @@ func TestCommitCommand(t *testing.T) {
 		t.Fatalf("Failed to update master ref file: %v", err)
 	}
 }
+
+// TestCommitKeepsFullSnapshot tests that the index stays populated after a commit and later commits keep untouched files
+func TestCommitKeepsFullSnapshot(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		"changed.txt":   "v1",
+		"untouched.txt": "stays",
+		"doomed.txt":    "goes away",
+	}, "Initial commit")
+
+	// The index still holds the committed snapshot
+	index, err := repo.GetStorage().GetIndexEntries()
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if len(index) != 3 {
+		t.Errorf("Index should hold all 3 committed files, got %v", index)
+	}
+
+	// Committing again without changes is refused
+	if _, err := repo.Commit("No changes"); err == nil || !strings.Contains(err.Error(), "nothing to commit") {
+		t.Errorf("Expected 'nothing to commit' error, got: %v", err)
+	}
+
+	// Change one file and delete another
+	if err := os.Remove(filepath.Join(tempDir, "doomed.txt")); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if err := repo.Add("doomed.txt"); err != nil {
+		t.Fatalf("Adding a deleted tracked file should stage its removal: %v", err)
+	}
+	commitID := CommitTestFiles(t, repo, tempDir, map[string]string{"changed.txt": "v2"}, "Second commit")
+
+	// The second commit's tree contains the untouched file but not the deleted one
+	obj, err := repo.GetStorage().GetObject(commitID)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	treeObj, err := repo.GetStorage().GetObject(obj.(*core.Commit).TreeHash())
+	if err != nil {
+		t.Fatalf("Failed to read tree: %v", err)
+	}
+
+	names := make(map[string]string)
+	for _, entry := range treeObj.(*core.Tree).GetEntries() {
+		names[entry.Name] = entry.Hash
+	}
+	if _, ok := names["untouched.txt"]; !ok {
+		t.Errorf("untouched.txt must not be dropped from the second commit, got %v", names)
+	}
+	if _, ok := names["doomed.txt"]; ok {
+		t.Errorf("doomed.txt should be removed from the second commit")
+	}
+	if names["changed.txt"] != core.NewBlob([]byte("v2")).ID() {
+		t.Errorf("changed.txt should hold the new content")
+	}
+
+	// Adding a path that was never tracked still fails
+	if err := repo.Add("never_existed.txt"); err == nil {
+		t.Errorf("Adding a missing untracked file should fail")
+	}
+}
+
+// legacyCommitData mirrors the commit encoding used before commits could have several parents
+type legacyCommitData struct {
+	TreeHash   string
+	ParentHash string
+	Message    string
+	Author     string
+	Timestamp  time.Time
+}
+
+// TestCommitRemovesLastFile tests that staging the deletion of every tracked file can be committed
+func TestCommitRemovesLastFile(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"only.txt": "last one"}, "Initial commit")
+
+	RemoveTestFile(t, tempDir, "only.txt")
+	if err := repo.Add("only.txt"); err != nil {
+		t.Fatalf("Failed to stage removal: %v", err)
+	}
+
+	commitID, err := repo.Commit("Remove everything")
+	if err != nil {
+		t.Fatalf("Committing the removal of the last file failed: %v", err)
+	}
+
+	commit := readCommitObject(t, repo, commitID)
+	if commit.ParentHash() != first {
+		t.Errorf("Expected the removal to follow %s, got parent %s", first[:8], commit.ParentHash())
+	}
+	files, err := repo.Diff(repository.DiffOptions{From: first, To: commitID})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "only.txt" || files[0].Change != repository.ChangeDeleted {
+		t.Errorf("Expected only.txt to be removed, got %+v", files)
+	}
+	if status, _ := repo.Status(); !status.IsClean() {
+		t.Errorf("Expected a clean status after committing the removal, got %+v", status)
+	}
+
+	// With the tree already empty there is nothing left to commit
+	if _, err := repo.Commit("Again"); err == nil || !strings.Contains(err.Error(), "nothing to commit") {
+		t.Errorf("Expected 'nothing to commit', got %v", err)
+	}
+}
+
+// TestMergeCommitParents tests multi-parent commits and loading single-parent commits in the old encoding
+func TestMergeCommitParents(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+	fs := repo.GetStorage()
+
+	// A merge commit keeps its parents in order through a storage round trip
+	merge := core.NewMergeCommit("tree", []string{"first", "second"}, "Merge branch", "test")
+	if !merge.IsMerge() || merge.ParentHash() != "first" {
+		t.Fatalf("Unexpected merge commit parents: %v", merge.ParentHashes())
+	}
+	if err := fs.StoreObject(merge); err != nil {
+		t.Fatalf("Failed to store merge commit: %v", err)
+	}
+	obj, err := fs.GetObject(merge.ID())
+	if err != nil {
+		t.Fatalf("Failed to load merge commit: %v", err)
+	}
+	loaded := obj.(*core.Commit)
+	if loaded.ID() != merge.ID() {
+		t.Errorf("Merge commit hash changed on round trip")
+	}
+	if parents := loaded.ParentHashes(); len(parents) != 2 || parents[0] != "first" || parents[1] != "second" {
+		t.Errorf("Expected parents [first second], got %v", parents)
+	}
+
+	// The encoding is deterministic
+	once, _ := merge.Serialize()
+	twice, _ := merge.Serialize()
+	if string(once) != string(twice) {
+		t.Errorf("Commit serialization should be deterministic")
+	}
+
+	// Root commits have no parents
+	root := core.NewCommit("tree", "", "Root", "test")
+	if len(root.ParentHashes()) != 0 || root.ParentHash() != "" {
+		t.Errorf("Root commit should have no parents, got %v", root.ParentHashes())
+	}
+
+	// A commit written in the single-parent encoding still loads with the same hash
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(legacyCommitData{
+		TreeHash:   "tree",
+		ParentHash: "parent",
+		Message:    "Old commit",
+		Author:     "test",
+		Timestamp:  time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to encode legacy commit: %v", err)
+	}
+	legacyBytes := core.SerializeObject(core.CommitType, buf.Bytes())
+	legacyHash := core.CalculateHash(legacyBytes)
+	objectPath := filepath.Join(tempDir, storage.YAGDir, storage.ObjectsDir, legacyHash)
+	if err := os.WriteFile(objectPath, legacyBytes, 0644); err != nil {
+		t.Fatalf("Failed to write legacy commit: %v", err)
+	}
+
+	obj, err = fs.GetObject(legacyHash)
+	if err != nil {
+		t.Fatalf("Failed to load legacy commit: %v", err)
+	}
+	legacy := obj.(*core.Commit)
+	if legacy.ID() != legacyHash {
+		t.Errorf("Legacy commit hash changed: %s != %s", legacy.ID(), legacyHash)
+	}
+	if parents := legacy.ParentHashes(); len(parents) != 1 || parents[0] != "parent" {
+		t.Errorf("Expected legacy parent to load, got %v", parents)
+	}
+	if legacy.Message() != "Old commit" {
+		t.Errorf("Unexpected legacy message: %q", legacy.Message())
+	}
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// TestDiffLines tests the line-based diff engine and unified output
+func TestDiffLines(t *testing.T) {
+	oldBlob := core.NewBlob([]byte("one\ntwo\nthree\nfour\n"))
+	newBlob := core.NewBlob([]byte("one\n2\nthree\nfour\nfive\n"))
+
+	lines := core.DiffBlobs(oldBlob, newBlob)
+
+	// Replaying the script must rebuild both versions
+	var rebuiltOld, rebuiltNew strings.Builder
+	for _, line := range lines {
+		if line.Op != core.DiffInsert {
+			rebuiltOld.WriteString(line.Text)
+		}
+		if line.Op != core.DiffDelete {
+			rebuiltNew.WriteString(line.Text)
+		}
+	}
+	if rebuiltOld.String() != string(oldBlob.Content()) || rebuiltNew.String() != string(newBlob.Content()) {
+		t.Fatalf("Edit script does not reproduce the inputs: %+v", lines)
+	}
+
+	insertions, deletions := core.CountChanges(lines)
+	if insertions != 2 || deletions != 1 {
+		t.Errorf("Expected 2 insertions and 1 deletion, got %d and %d", insertions, deletions)
+	}
+
+	expected := "--- a/f\n+++ b/f\n" +
+		"@@ -1,4 +1,5 @@\n" +
+		" one\n-two\n+2\n three\n four\n+five\n"
+	if got := core.FormatUnified("a/f", "b/f", lines, 3); got != expected {
+		t.Errorf("Unexpected unified diff:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	// Distant changes are split into separate hunks
+	var before, after []string
+	for i := 0; i < 20; i++ {
+		before = append(before, "line\n")
+		after = append(after, "line\n")
+	}
+	after[1] = "changed\n"
+	after[18] = "changed\n"
+	unified := core.FormatUnified("a/f", "b/f", core.DiffLines(before, after), 3)
+	if strings.Count(unified, "@@ -") != 2 {
+		t.Errorf("Expected two hunks, got:\n%s", unified)
+	}
+
+	// A missing newline at end of file is a change of its own
+	unified = core.FormatUnified("a/f", "b/f", core.DiffBlobs(core.NewBlob([]byte("x\n")), core.NewBlob([]byte("x"))), 3)
+	if !strings.Contains(unified, "\\ No newline at end of file") {
+		t.Errorf("Expected a no-newline marker, got:\n%s", unified)
+	}
+
+	if !core.IsBinary([]byte{'P', 'N', 'G', 0, 1}) || core.IsBinary([]byte("plain text")) {
+		t.Errorf("Binary detection is wrong")
+	}
+}
+
+// TestDiffLinesLargeRewrite tests that rewriting a large file takes memory in proportion to its size
+func TestDiffLinesLargeRewrite(t *testing.T) {
+	const size = 4000
+
+	var before, after []string
+	for i := 0; i < size; i++ {
+		before = append(before, fmt.Sprintf("old %d\n", i))
+		after = append(after, fmt.Sprintf("new %d\n", i))
+	}
+	// A few shared lines make the paths split instead of failing to meet
+	for i := 0; i < size; i += 500 {
+		after[i] = before[i]
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	allocated := stats.TotalAlloc
+
+	lines := core.DiffLines(before, after)
+
+	runtime.ReadMemStats(&stats)
+	if used := stats.TotalAlloc - allocated; used > 64<<20 {
+		t.Errorf("Expected the diff to allocate well under 64 MiB, got %d MiB", used>>20)
+	}
+
+	insertions, deletions := core.CountChanges(lines)
+	if insertions != size-8 || deletions != size-8 {
+		t.Errorf("Expected %d insertions and deletions, got %d and %d", size-8, insertions, deletions)
+	}
+}
+
+// TestDiffCommand tests comparing the working tree, the index and commits
+func TestDiffCommand(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{
+		"notes.txt": "alpha\nbeta\n",
+		"gone.txt":  "bye\n",
+	}, "Initial commit")
+
+	// Working tree vs index
+	WriteTestFile(t, tempDir, "notes.txt", "alpha\nBETA\n")
+	diffs, err := repo.Diff(repository.DiffOptions{})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "notes.txt" || diffs[0].Change != repository.ChangeModified {
+		t.Fatalf("Expected notes.txt to be modified in the working tree, got %+v", diffs)
+	}
+
+	// Index vs HEAD is empty until the change is staged
+	if diffs, _ := repo.Diff(repository.DiffOptions{Staged: true}); len(diffs) != 0 {
+		t.Errorf("Expected no staged changes, got %d", len(diffs))
+	}
+	if err := repo.Add("notes.txt"); err != nil {
+		t.Fatalf("Failed to stage notes.txt: %v", err)
+	}
+	if diffs, _ := repo.Diff(repository.DiffOptions{Staged: true}); len(diffs) != 1 {
+		t.Errorf("Expected one staged change, got %d", len(diffs))
+	}
+
+	// Commit vs commit, including an added binary file and a deleted file
+	WriteTestFile(t, tempDir, "image.bin", "\x89PNG\x00\x01\x02")
+	if err := repo.Add("image.bin"); err != nil {
+		t.Fatalf("Failed to add binary file: %v", err)
+	}
+	RemoveTestFile(t, tempDir, "gone.txt")
+	if err := repo.Add("gone.txt"); err != nil {
+		t.Fatalf("Failed to stage removal: %v", err)
+	}
+	second, err := repo.Commit("Second commit")
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	diffs, err = repo.Diff(repository.DiffOptions{From: first, To: second})
+	if err != nil {
+		t.Fatalf("Diff between commits failed: %v", err)
+	}
+	changes := make(map[string]repository.FileChange)
+	for _, diff := range diffs {
+		changes[diff.Path] = diff.Change
+		if diff.Path == "image.bin" && !diff.Binary {
+			t.Errorf("image.bin should be detected as binary")
+		}
+	}
+	expected := map[string]repository.FileChange{
+		"notes.txt": repository.ChangeModified,
+		"image.bin": repository.ChangeAdded,
+		"gone.txt":  repository.ChangeDeleted,
+	}
+	for path, change := range expected {
+		if changes[path] != change {
+			t.Errorf("Expected %s to be %s, got %q", path, change, changes[path])
+		}
+	}
+
+	// Patch output
+	output, err := CaptureOutput(t, func() error {
+		return commands.DiffCommand([]string{first, second}, commands.DiffOptions{})
+	})
+	if err != nil {
+		t.Fatalf("DiffCommand failed: %v", err)
+	}
+	for _, fragment := range []string{"-beta", "+BETA", "Binary files /dev/null and b/image.bin differ", "+++ /dev/null"} {
+		if !strings.Contains(output, fragment) {
+			t.Errorf("Patch output should contain %q, got:\n%s", fragment, output)
+		}
+	}
+
+	// Name-status output
+	output, err = CaptureOutput(t, func() error {
+		return commands.DiffCommand([]string{first, second}, commands.DiffOptions{NameStatus: true})
+	})
+	if err != nil {
+		t.Fatalf("DiffCommand --name-status failed: %v", err)
+	}
+	if output != "D\tgone.txt\nA\timage.bin\nM\tnotes.txt\n" {
+		t.Errorf("Unexpected name-status output:\n%s", output)
+	}
+
+	// Stat output, restricted to a path
+	output, err = CaptureOutput(t, func() error {
+		return commands.DiffCommand([]string{first, second, "--", "notes.txt"}, commands.DiffOptions{Stat: true})
+	})
+	if err != nil {
+		t.Fatalf("DiffCommand --stat failed: %v", err)
+	}
+	if !strings.Contains(output, "notes.txt | 2 +-") || !strings.Contains(output, "1 file changed, 1 insertion(+), 1 deletion(-)") {
+		t.Errorf("Unexpected stat output:\n%s", output)
+	}
+}
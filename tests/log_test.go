@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// collectLog drains a log iterator into a slice of commit messages
+func collectLog(t *testing.T, repo *repository.Repository, opts repository.LogOptions) []string {
+	t.Helper()
+
+	it, err := repo.Log(opts)
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	var messages []string
+	for {
+		commit, err := it.Next()
+		if err != nil {
+			t.Fatalf("Log iteration failed: %v", err)
+		}
+		if commit == nil {
+			return messages
+		}
+		messages = append(messages, commit.Message())
+	}
+}
+
+// TestLogCommand tests walking and filtering the commit history
+func TestLogCommand(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	// An empty repository has no history
+	if messages := collectLog(t, repo, repository.LogOptions{}); len(messages) != 0 {
+		t.Errorf("Expected no commits in a fresh repository, got %v", messages)
+	}
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "a1"}, "Add a")
+	CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b1"}, "Add b")
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "a2"}, "Change a")
+
+	// Full history, newest first
+	messages := collectLog(t, repo, repository.LogOptions{})
+	expected := []string{"Change a", "Add b", "Add a"}
+	if strings.Join(messages, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, messages)
+	}
+
+	// Limit
+	if messages := collectLog(t, repo, repository.LogOptions{Limit: 2}); len(messages) != 2 {
+		t.Errorf("Expected 2 commits with a limit, got %v", messages)
+	}
+
+	// Starting point
+	if messages := collectLog(t, repo, repository.LogOptions{Start: first}); len(messages) != 1 || messages[0] != "Add a" {
+		t.Errorf("Expected only the first commit when starting from it, got %v", messages)
+	}
+
+	// Path filtering only shows commits that touched the path
+	messages = collectLog(t, repo, repository.LogOptions{Paths: []string{"a.txt"}})
+	if strings.Join(messages, ",") != "Change a,Add a" {
+		t.Errorf("Expected commits touching a.txt, got %v", messages)
+	}
+
+	// Author and date filters
+	if messages := collectLog(t, repo, repository.LogOptions{Author: "no-such-author"}); len(messages) != 0 {
+		t.Errorf("Expected no commits for an unknown author, got %v", messages)
+	}
+	if messages := collectLog(t, repo, repository.LogOptions{Since: time.Now().Add(time.Hour)}); len(messages) != 0 {
+		t.Errorf("Expected no commits since the future, got %v", messages)
+	}
+	if messages := collectLog(t, repo, repository.LogOptions{Until: time.Now().Add(-time.Hour)}); len(messages) != 0 {
+		t.Errorf("Expected no commits until an hour ago, got %v", messages)
+	}
+
+	// The command prints one line per commit in oneline mode
+	output, err := CaptureOutput(t, func() error {
+		return commands.LogCommand([]string{"--", "b.txt"}, commands.LogOptions{Oneline: true})
+	})
+	if err != nil {
+		t.Fatalf("LogCommand failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "Add b") {
+		t.Errorf("Expected a single oneline entry for b.txt, got:\n%s", output)
+	}
+
+	// The default format shows the full hash
+	output, err = CaptureOutput(t, func() error {
+		return commands.LogCommand([]string{first}, commands.LogOptions{})
+	})
+	if err != nil {
+		t.Fatalf("LogCommand failed: %v", err)
+	}
+	if !strings.Contains(output, "commit "+first) || !strings.Contains(output, "    Add a") {
+		t.Errorf("Unexpected log output:\n%s", output)
+	}
+
+	// Invalid dates are rejected
+	if err := commands.LogCommand(nil, commands.LogOptions{Since: "yesterday-ish"}); err == nil {
+		t.Errorf("LogCommand should reject an unparseable --since date")
+	}
+
+}
@@ -0,0 +1,317 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// TestMergeLines tests the line-based three-way merge
+func TestMergeLines(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\n"
+
+	// Changes to different regions merge cleanly
+	result := core.MergeContent([]byte(base), []byte("ONE\ntwo\nthree\nfour\n"), []byte("one\ntwo\nthree\nFOUR\n"))
+	if result.HasConflicts() {
+		t.Fatalf("Expected a clean merge of separate changes")
+	}
+	if got := string(result.Content()); got != "ONE\ntwo\nthree\nFOUR\n" {
+		t.Errorf("Unexpected merged content: %q", got)
+	}
+
+	// Identical changes on both sides are not a conflict
+	result = core.MergeContent([]byte(base), []byte("one\n2\nthree\nfour\n"), []byte("one\n2\nthree\nfour\n"))
+	if result.HasConflicts() || string(result.Content()) != "one\n2\nthree\nfour\n" {
+		t.Errorf("Expected identical changes to merge cleanly, got %q", result.Content())
+	}
+
+	// Different changes to the same line conflict
+	result = core.MergeContent([]byte(base), []byte("one\nours\nthree\nfour\n"), []byte("one\ntheirs\nthree\nfour\n"))
+	if !result.HasConflicts() {
+		t.Fatalf("Expected a conflict for overlapping changes")
+	}
+}
+
+// TestMergeCommand tests fast-forward, three-way and conflicting merges
+func TestMergeCommand(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		"shared.txt": "one\ntwo\nthree\nfour\n",
+	}, "Initial commit")
+
+	if err := repo.CreateBranch("feature"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	feature := CommitTestFiles(t, repo, tempDir, map[string]string{"feature.txt": "feature\n"}, "Add feature")
+
+	// master is behind feature, so the merge fast-forwards
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	output, err := CaptureOutput(t, func() error { return commands.MergeCommand("feature") })
+	if err != nil {
+		t.Fatalf("Fast-forward merge failed: %v", err)
+	}
+	if !strings.Contains(output, "Fast-forward") {
+		t.Errorf("Expected fast-forward output, got %q", output)
+	}
+	if head, _ := repo.GetStorage().GetHeadCommit(); head.ID() != feature {
+		t.Errorf("Expected master to point at %s after fast-forward", feature)
+	}
+	if ReadTestFile(t, tempDir, "feature.txt") != "feature\n" {
+		t.Errorf("Expected feature.txt in the working tree after fast-forward")
+	}
+
+	// Merging again is a no-op
+	outcome, err := repo.Merge("feature")
+	if err != nil || !outcome.UpToDate {
+		t.Errorf("Expected an up-to-date merge, got %+v, %v", outcome, err)
+	}
+
+	// Diverge: both branches change different lines of the same file
+	ours := CommitTestFiles(t, repo, tempDir, map[string]string{"shared.txt": "ONE\ntwo\nthree\nfour\n"}, "Change first line")
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	theirs := CommitTestFiles(t, repo, tempDir, map[string]string{
+		"shared.txt": "one\ntwo\nthree\nFOUR\n",
+		"other.txt":  "other\n",
+	}, "Change last line")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+
+	if mergeBase, err := repo.MergeBase(ours, theirs); err != nil || mergeBase != feature {
+		t.Errorf("Expected merge base %s, got %s (%v)", feature, mergeBase, err)
+	}
+
+	outcome, err = repo.Merge("feature")
+	if err != nil {
+		t.Fatalf("Three-way merge failed: %v", err)
+	}
+	if outcome.FastForward || outcome.UpToDate || len(outcome.Conflicts) > 0 {
+		t.Fatalf("Expected a merge commit, got %+v", outcome)
+	}
+
+	mergeCommit, err := repo.GetStorage().GetHeadCommit()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	parents := mergeCommit.ParentHashes()
+	if len(parents) != 2 || parents[0] != ours || parents[1] != theirs {
+		t.Errorf("Expected parents [%s %s], got %v", ours, theirs, parents)
+	}
+	if mergeCommit.Message() != "Merge branch 'feature'" {
+		t.Errorf("Unexpected merge message %q", mergeCommit.Message())
+	}
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != "ONE\ntwo\nthree\nFOUR\n" {
+		t.Errorf("Expected both changes in shared.txt, got %q", got)
+	}
+	if ReadTestFile(t, tempDir, "other.txt") != "other\n" {
+		t.Errorf("Expected other.txt to be merged in")
+	}
+
+	status, err := repo.Status()
+	if err != nil || !status.IsClean() {
+		t.Errorf("Expected a clean status after merging, got %+v (%v)", status, err)
+	}
+
+	// Conflicting changes to the same line are reported and nothing is committed
+	CommitTestFiles(t, repo, tempDir, map[string]string{"shared.txt": "ours\n"}, "Ours")
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"shared.txt": "theirs\n"}, "Theirs")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+
+	before, _ := repo.GetStorage().GetHeadCommit()
+	output, err = CaptureOutput(t, func() error { return commands.MergeCommand("feature") })
+	if err == nil {
+		t.Fatalf("Expected the conflicting merge to fail")
+	}
+	if !strings.Contains(output, "CONFLICT (content): Merge conflict in shared.txt") {
+		t.Errorf("Expected the conflict to be reported, got %q", output)
+	}
+	if after, _ := repo.GetStorage().GetHeadCommit(); after.ID() != before.ID() {
+		t.Errorf("Expected HEAD to stay put after a conflicting merge")
+	}
+}
+
+// divergeBranches commits different versions of the same files on master and feature and leaves master checked out
+func divergeBranches(t *testing.T, repo *repository.Repository, root string, ours, theirs map[string]string) {
+	t.Helper()
+
+	if err := repo.CreateBranch("feature"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	CommitTestFiles(t, repo, root, ours, "Ours")
+
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	CommitTestFiles(t, repo, root, theirs, "Theirs")
+
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+}
+
+// TestMergeConflictResolution tests conflict markers, index stages and concluding or aborting a merge
+func TestMergeConflictResolution(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		"shared.txt": "one\ntwo\nthree\n",
+		"gone.txt":   "base\n",
+	}, "Initial commit")
+
+	// master deletes gone.txt while feature modifies it
+	RemoveTestFile(t, tempDir, "gone.txt")
+	if err := repo.Add("gone.txt"); err != nil {
+		t.Fatalf("Failed to stage removal: %v", err)
+	}
+	divergeBranches(t, repo, tempDir,
+		map[string]string{"shared.txt": "one\nours\nthree\n"},
+		map[string]string{"shared.txt": "one\ntheirs\nthree\n", "gone.txt": "changed\n"})
+	head, _ := repo.GetStorage().GetHeadCommit()
+
+	outcome, err := repo.Merge("feature")
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if strings.Join(outcome.Conflicts, ",") != "gone.txt,shared.txt" {
+		t.Fatalf("Expected conflicts in gone.txt and shared.txt, got %v", outcome.Conflicts)
+	}
+
+	// The conflicted file carries markers, the deleted one comes back with their content
+	expected := "one\n<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> feature\nthree\n"
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != expected {
+		t.Errorf("Expected conflict markers, got %q", got)
+	}
+	if got := ReadTestFile(t, tempDir, "gone.txt"); got != "changed\n" {
+		t.Errorf("Expected their version of gone.txt, got %q", got)
+	}
+
+	// All three versions are recorded in the index
+	conflicts, err := repo.GetStorage().GetIndexConflicts()
+	if err != nil {
+		t.Fatalf("Failed to read index conflicts: %v", err)
+	}
+	if stages := conflicts["shared.txt"]; stages.Base == "" || stages.Ours == "" || stages.Theirs == "" {
+		t.Errorf("Expected base, ours and theirs for shared.txt, got %+v", stages)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.Merging || status.Unmerged["shared.txt"] != repository.UnmergedBothModified ||
+		status.Unmerged["gone.txt"] != repository.UnmergedDeletedByUs {
+		t.Errorf("Unexpected unmerged status: %+v", status)
+	}
+	output, err := CaptureOutput(t, func() error { return commands.StatusCommand(nil) })
+	if err != nil || !strings.Contains(output, "Unmerged paths:") || !strings.Contains(output, "both modified:") {
+		t.Errorf("Expected an unmerged paths section, got %q (%v)", output, err)
+	}
+
+	// Committing is refused while conflicts remain
+	if _, err := repo.Commit("too early"); err == nil {
+		t.Errorf("Expected commit to fail with unresolved conflicts")
+	}
+
+	// Abort brings everything back to HEAD
+	if err := repo.MergeAbort(); err != nil {
+		t.Fatalf("Merge abort failed: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != "one\nours\nthree\n" {
+		t.Errorf("Expected our version after abort, got %q", got)
+	}
+	if ReadTestFile(t, tempDir, "gone.txt") != "" {
+		t.Errorf("Expected gone.txt to be removed again after abort")
+	}
+	if status, _ := repo.Status(); !status.IsClean() || status.Merging {
+		t.Errorf("Expected a clean status after abort, got %+v", status)
+	}
+
+	// Merge again and resolve: take their shared.txt and keep gone.txt deleted
+	if _, err := repo.Merge("feature"); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if _, err := repo.CheckoutConflictSide([]string{"shared.txt"}, repository.ConflictTheirs); err != nil {
+		t.Fatalf("Checkout --theirs failed: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "shared.txt"); got != "one\ntheirs\nthree\n" {
+		t.Errorf("Expected their version, got %q", got)
+	}
+	if _, err := repo.CheckoutConflictSide([]string{"gone.txt"}, repository.ConflictOurs); err == nil {
+		t.Errorf("Expected checkout --ours to fail for a file we deleted")
+	}
+
+	RemoveTestFile(t, tempDir, "gone.txt")
+	for _, file := range []string{"shared.txt", "gone.txt"} {
+		if err := repo.Add(file); err != nil {
+			t.Fatalf("Failed to add %s: %v", file, err)
+		}
+	}
+
+	commitID, err := repo.MergeContinue()
+	if err != nil {
+		t.Fatalf("Merge continue failed: %v", err)
+	}
+	commit, err := repo.GetStorage().GetHeadCommit()
+	if err != nil || commit.ID() != commitID {
+		t.Fatalf("Expected HEAD to be the merge commit")
+	}
+	if parents := commit.ParentHashes(); len(parents) != 2 || parents[0] != head.ID() {
+		t.Errorf("Expected a merge commit on top of %s, got parents %v", head.ID(), parents)
+	}
+	if status, _ := repo.Status(); !status.IsClean() || status.Merging {
+		t.Errorf("Expected a clean status after concluding the merge, got %+v", status)
+	}
+}
+
+// TestCheckoutDuringMerge tests that switching branches cannot carry a stopped merge into the next commit
+func TestCheckoutDuringMerge(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"shared.txt": "base\n"}, "Initial commit")
+	if err := repo.CreateBranch("other"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	divergeBranches(t, repo, tempDir, map[string]string{"shared.txt": "ours\n"}, map[string]string{"shared.txt": "theirs\n"})
+
+	if outcome, err := repo.Merge("feature"); err != nil || len(outcome.Conflicts) == 0 {
+		t.Fatalf("Expected the merge to conflict, got %+v (%v)", outcome, err)
+	}
+
+	// Resolving the conflicts does not conclude the merge, so a plain switch is still refused
+	WriteTestFile(t, tempDir, "shared.txt", "resolved\n")
+	if err := repo.Add("shared.txt"); err != nil {
+		t.Fatalf("Failed to add shared.txt: %v", err)
+	}
+	if err := repo.Checkout("other", false); err == nil || !strings.Contains(err.Error(), "not concluded your merge") {
+		t.Fatalf("Expected checkout during a merge to fail, got %v", err)
+	}
+
+	// A forced switch abandons the merge
+	if err := repo.Checkout("other", true); err != nil {
+		t.Fatalf("Forced checkout failed: %v", err)
+	}
+	if status, _ := repo.Status(); status.Merging {
+		t.Errorf("Expected the merge to be abandoned by a forced checkout")
+	}
+
+	hash := CommitTestFiles(t, repo, tempDir, map[string]string{"unrelated.txt": "new\n"}, "Unrelated")
+	if commit := readCommitObject(t, repo, hash); len(commit.ParentHashes()) != 1 {
+		t.Errorf("Expected a single-parent commit on other, got parents %v", commit.ParentHashes())
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// sortedLinesDriver merges by taking every distinct line of both sides in sorted order
+type sortedLinesDriver struct {
+	calls []string
+}
+
+// Merge implements repository.MergeDriver
+func (d *sortedLinesDriver) Merge(input *repository.MergeDriverInput) ([]byte, bool, error) {
+	d.calls = append(d.calls, input.Path)
+
+	seen := make(map[string]bool)
+	var lines []string
+	for _, content := range [][]byte{input.Ours, input.Theirs} {
+		for _, line := range core.SplitLines(content) {
+			if !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+	}
+	sort.Strings(lines)
+
+	return []byte(strings.Join(lines, "")), false, nil
+}
+
+// TestMergeStrategies tests resolving conflicts with the ours, theirs and union strategies
+func TestMergeStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		expected string
+	}{
+		{"ours", "one\nours\nthree\n"},
+		{"theirs", "one\ntheirs\nthree\n"},
+		{"union", "one\nours\ntheirs\nthree\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			tempDir, repo := SetupRepository(t)
+
+			CommitTestFiles(t, repo, tempDir, map[string]string{"file.txt": "one\ntwo\nthree\n"}, "Initial commit")
+			divergeBranches(t, repo, tempDir,
+				map[string]string{"file.txt": "one\nours\nthree\n"},
+				map[string]string{"file.txt": "one\ntheirs\nthree\n"})
+
+			outcome, err := repo.MergeWithOptions("feature", repository.MergeOptions{Strategy: tt.strategy})
+			if err != nil {
+				t.Fatalf("Merge failed: %v", err)
+			}
+			if len(outcome.Conflicts) > 0 || outcome.CommitHash == "" {
+				t.Fatalf("Expected the strategy to resolve the conflict, got %+v", outcome)
+			}
+			if got := ReadTestFile(t, tempDir, "file.txt"); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	_, repo := SetupRepository(t)
+	if _, err := repo.MergeWithOptions("master", repository.MergeOptions{Strategy: "bogus"}); err == nil {
+		t.Errorf("Expected an unknown strategy to be rejected")
+	}
+}
+
+// TestMergeDriverAttributes tests selecting merge drivers per path from the attributes file
+func TestMergeDriverAttributes(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	driver := &sortedLinesDriver{}
+	if err := repo.RegisterMergeDriver("sorted", driver); err != nil {
+		t.Fatalf("Failed to register driver: %v", err)
+	}
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		repository.AttributesFile: "# generated files\n*.lock merge=theirs\nconfig/*.list merge=sorted\n",
+		"deps.lock":               "a 1\n",
+		"config/names.list":       "bob\n",
+		"notes.txt":               "base\n",
+	}, "Initial commit")
+
+	divergeBranches(t, repo, tempDir,
+		map[string]string{"deps.lock": "a 2\n", "config/names.list": "bob\ncarol\n", "notes.txt": "ours\n"},
+		map[string]string{"deps.lock": "a 3\n", "config/names.list": "alice\nbob\n", "notes.txt": "theirs\n"})
+
+	outcome, err := repo.Merge("feature")
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	// Only the file without a driver attribute conflicts
+	if strings.Join(outcome.Conflicts, ",") != "notes.txt" {
+		t.Errorf("Expected only notes.txt to conflict, got %v", outcome.Conflicts)
+	}
+	if got := ReadTestFile(t, tempDir, "deps.lock"); got != "a 3\n" {
+		t.Errorf("Expected the theirs driver to pick their lock file, got %q", got)
+	}
+	if got := ReadTestFile(t, tempDir, "config/names.list"); got != "alice\nbob\ncarol\n" {
+		t.Errorf("Expected the custom driver's output, got %q", got)
+	}
+	if len(driver.calls) != 1 || driver.calls[0] != "config/names.list" {
+		t.Errorf("Expected the custom driver to run once for config/names.list, got %v", driver.calls)
+	}
+	if !strings.Contains(ReadTestFile(t, tempDir, "notes.txt"), "<<<<<<< HEAD") {
+		t.Errorf("Expected conflict markers in notes.txt")
+	}
+
+	// Resolved files are staged, the conflicted one is not
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if _, unmerged := status.Unmerged["deps.lock"]; unmerged {
+		t.Errorf("Expected deps.lock to be merged")
+	}
+	if status.Unmerged["notes.txt"] != repository.UnmergedBothModified {
+		t.Errorf("Expected notes.txt to be unmerged, got %+v", status.Unmerged)
+	}
+}
+
+// TestMergeDriverUnknown tests that naming a driver nobody registered stops the merge before anything changes
+func TestMergeDriverUnknown(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		repository.AttributesFile: "*.txt merge=text\n*.json merge=jsonmerge\n",
+		"notes.txt":               "base\n",
+	}, "Initial commit")
+
+	divergeBranches(t, repo, tempDir, map[string]string{"notes.txt": "ours\n"}, map[string]string{"notes.txt": "theirs\n"})
+	head, _ := repo.GetStorage().GetHeadCommit()
+
+	_, err := CaptureOutput(t, func() error { return commands.MergeCommand("feature") })
+	if err == nil || !strings.Contains(err.Error(), ".yagattributes:2: unknown merge driver 'jsonmerge'") ||
+		!strings.Contains(err.Error(), "binary, ours, text, theirs, union") {
+		t.Fatalf("Expected the unknown driver to be reported with its line, got %v", err)
+	}
+
+	if after, _ := repo.GetStorage().GetHeadCommit(); after.ID() != head.ID() {
+		t.Errorf("Expected HEAD to stay put")
+	}
+	if got := ReadTestFile(t, tempDir, "notes.txt"); got != "ours\n" {
+		t.Errorf("Expected the working tree untouched, got %q", got)
+	}
+}
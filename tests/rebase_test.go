@@ -0,0 +1,295 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// readCommitObject loads a commit from the repository's object store
+func readCommitObject(t *testing.T, repo *repository.Repository, hash string) *core.Commit {
+	t.Helper()
+
+	obj, err := repo.GetStorage().GetObject(hash)
+	if err != nil {
+		t.Fatalf("Failed to read commit %s: %v", hash, err)
+	}
+	commit, ok := obj.(*core.Commit)
+	if !ok {
+		t.Fatalf("Object %s is not a commit", hash)
+	}
+	return commit
+}
+
+// TestRebase tests replaying a branch onto another one
+func TestRebase(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\ntwo\nthree\n"}, "Base")
+	if err := repo.CheckoutNewBranch("feature", "", false); err != nil {
+		t.Fatalf("Failed to create feature: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b\n"}, "Add b")
+	commitAs(t, repo, "feature", "alice")
+	if err := repo.Checkout("feature", true); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	original := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\ntwo\nTHREE\n"}, "Change three")
+
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	upstream := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "ONE\ntwo\nthree\n"}, "Change one")
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+
+	output, err := CaptureOutput(t, func() error { return commands.RebaseCommand("master", commands.RebaseOptions{}) })
+	if err != nil {
+		t.Fatalf("Rebase failed: %v", err)
+	}
+	if output != "Successfully rebased and updated refs/heads/feature.\n" {
+		t.Errorf("Unexpected rebase output %q", output)
+	}
+
+	if branch, _ := repo.GetCurrentBranch(); branch != "feature" {
+		t.Errorf("Expected to be back on feature, got %q", branch)
+	}
+	tip, _ := repo.GetStorage().GetRef("feature")
+	last := readCommitObject(t, repo, tip)
+	first := readCommitObject(t, repo, last.ParentHash())
+	if first.ParentHash() != upstream {
+		t.Errorf("Expected the replayed commits on top of %s, got parent %s", upstream[:8], first.ParentHash())
+	}
+	if first.Message() != "Add b" || first.Author() != "alice" || last.Message() != "Change three" {
+		t.Errorf("Expected messages and authors to be kept, got %q by %s and %q", first.Message(), first.Author(), last.Message())
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "ONE\ntwo\nTHREE\n" || ReadTestFile(t, tempDir, "b.txt") != "b\n" {
+		t.Errorf("Expected both sides' changes in the working tree")
+	}
+
+	// The reflog keeps the tip from before the rebase
+	if hash, err := repo.ResolveRevision("feature@{1}"); err != nil || hash != original {
+		t.Errorf("Expected feature@{1} to be the pre-rebase tip %s, got %s (%v)", original[:8], hash, err)
+	}
+	if orig, _ := repo.GetStorage().ReadState(storage.OrigHeadFile); orig != original {
+		t.Errorf("Expected ORIG_HEAD to be the pre-rebase tip, got %s", orig)
+	}
+	entries, _ := repo.Reflog("HEAD")
+	if entries[0].Reason != "rebase (finish): returning to refs/heads/feature" || !strings.HasPrefix(entries[len(entries)-1].Reason, "commit (initial)") {
+		t.Errorf("Unexpected HEAD reflog %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".yag", "rebase-merge")); !os.IsNotExist(err) {
+		t.Errorf("Expected the rebase state to be removed")
+	}
+
+	outcome, err := repo.Rebase("master", repository.RebaseOptions{})
+	if err != nil || !outcome.UpToDate {
+		t.Errorf("Expected a second rebase to be up to date, got %+v (%v)", outcome, err)
+	}
+
+	// A branch behind its upstream is fast-forwarded
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	if outcome, err = repo.Rebase("feature", repository.RebaseOptions{}); err != nil {
+		t.Fatalf("Fast-forward rebase failed: %v", err)
+	}
+	if head, _ := repo.GetStorage().GetRef("master"); head != tip || len(outcome.Picks) != 0 {
+		t.Errorf("Expected master fast-forwarded to %s, got %s", tip[:8], head)
+	}
+}
+
+// TestRebaseConflict tests continuing, skipping and aborting a rebase that stopped on conflicts
+func TestRebaseConflict(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "Base")
+	if err := repo.CheckoutNewBranch("feature", "", false); err != nil {
+		t.Fatalf("Failed to create feature: %v", err)
+	}
+	conflicting := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "feature\n"}, "Change a")
+	original := CommitTestFiles(t, repo, tempDir, map[string]string{"d.txt": "d\n"}, "Add d")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	upstream := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "master\n"}, "Change a on master")
+
+	start := func() {
+		t.Helper()
+		if err := repo.Checkout("feature", false); err != nil {
+			t.Fatalf("Failed to checkout feature: %v", err)
+		}
+		outcome, err := repo.Rebase("master", repository.RebaseOptions{})
+		if err != nil {
+			t.Fatalf("Rebase failed: %v", err)
+		}
+		if outcome.Stopped != conflicting || len(outcome.Conflicts) != 1 {
+			t.Fatalf("Expected the rebase to stop on %s, got %+v", conflicting[:8], outcome)
+		}
+	}
+
+	start()
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Rebasing != upstream || len(status.Unmerged) != 1 {
+		t.Errorf("Expected a rebase onto %s in progress, got %+v", upstream[:8], status)
+	}
+	if _, err := repo.Merge("master"); err == nil || !strings.Contains(err.Error(), "rebase is in progress") {
+		t.Errorf("Expected a merge during a rebase to fail, got %v", err)
+	}
+	if _, err := repo.RebaseContinue(nil); err == nil {
+		t.Errorf("Expected continuing with unresolved conflicts to fail")
+	}
+
+	// Aborting goes back to the branch as it was
+	if err := repo.RebaseAbort(); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "feature" {
+		t.Errorf("Expected to be back on feature, got %q", branch)
+	}
+	if head, _ := repo.GetStorage().GetRef("feature"); head != original || ReadTestFile(t, tempDir, "a.txt") != "feature\n" {
+		t.Errorf("Expected feature untouched after abort")
+	}
+
+	// Continuing commits the resolution and replays the rest
+	start()
+	WriteTestFile(t, tempDir, "a.txt", "resolved\n")
+	if err := repo.Add("a.txt"); err != nil {
+		t.Fatalf("Failed to add a.txt: %v", err)
+	}
+	outcome, err := repo.RebaseContinue(nil)
+	if err != nil {
+		t.Fatalf("Continue failed: %v", err)
+	}
+	if len(outcome.Picks) != 2 || outcome.Stopped != "" || outcome.Branch != "feature" {
+		t.Fatalf("Expected both commits replayed, got %+v", outcome)
+	}
+	resolved := readCommitObject(t, repo, outcome.Picks[0].CommitHash)
+	if resolved.ParentHash() != upstream || resolved.Message() != "Change a" {
+		t.Errorf("Expected the resolution committed on %s as 'Change a', got %q", upstream[:8], resolved.Message())
+	}
+	if head, _ := repo.GetStorage().GetRef("feature"); head != outcome.Head {
+		t.Errorf("Expected feature at the new tip")
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "resolved\n" || ReadTestFile(t, tempDir, "d.txt") != "d\n" {
+		t.Errorf("Expected the resolution and the replayed commit in the working tree")
+	}
+
+	// Skipping drops the stopped commit
+	if err := repo.Reset(original, repository.ResetHard); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	start()
+	if outcome, err = repo.RebaseSkip(nil); err != nil {
+		t.Fatalf("Skip failed: %v", err)
+	}
+	tip := readCommitObject(t, repo, outcome.Head)
+	if tip.ParentHash() != upstream || ReadTestFile(t, tempDir, "a.txt") != "master\n" {
+		t.Errorf("Expected only 'Add d' replayed onto %s after skip", upstream[:8])
+	}
+	if err := repo.RebaseAbort(); err == nil {
+		t.Errorf("Expected aborting without a rebase in progress to fail")
+	}
+}
+
+// TestRebaseInteractive tests the todo list actions of an interactive rebase
+func TestRebaseInteractive(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	base := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "a\n"}, "Base")
+	var commits []string
+	for i := 1; i <= 4; i++ {
+		file := fmt.Sprintf("c%d.txt", i)
+		commits = append(commits, CommitTestFiles(t, repo, tempDir, map[string]string{file: file + "\n"}, fmt.Sprintf("C%d", i)))
+	}
+	original := commits[3]
+
+	// editor answers the todo list with a fixed script and rewords messages
+	editor := func(todo string, shown *string) repository.Editor {
+		return func(name, text string) (string, error) {
+			if name == storage.RebaseTodoFile {
+				*shown = text
+				return todo, nil
+			}
+			if strings.HasPrefix(text, "C2") {
+				return "Reworded\n# a comment\n", nil
+			}
+			return text, nil
+		}
+	}
+
+	var shown string
+	todo := fmt.Sprintf("pick %s\nr %s C2\nfixup %s\n# drop %s is implied by removing it\ndrop %s\n",
+		commits[0][:8], commits[1][:8], commits[2][:8], commits[3][:8], commits[3][:8])
+	outcome, err := repo.Rebase("HEAD~4", repository.RebaseOptions{Interactive: true, Edit: editor(todo, &shown)})
+	if err != nil {
+		t.Fatalf("Interactive rebase failed: %v", err)
+	}
+	if !strings.Contains(shown, "pick "+commits[0][:8]+" C1\n") || !strings.Contains(shown, "# Commands:") {
+		t.Errorf("Expected the todo list with help, got %q", shown)
+	}
+
+	head := readCommitObject(t, repo, outcome.Head)
+	if head.Message() != "Reworded" || head.ParentHash() != commits[0] {
+		t.Errorf("Expected the reworded commit with the fixup melded in on top of the unchanged C1, got %q on %s", head.Message(), head.ParentHash())
+	}
+	if ReadTestFile(t, tempDir, "c3.txt") != "c3.txt\n" {
+		t.Errorf("Expected the fixup's changes to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "c4.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the dropped commit's file to be gone")
+	}
+	if hash, _ := repo.ResolveRevision("master@{1}"); hash != original {
+		t.Errorf("Expected master@{1} to be the pre-rebase tip")
+	}
+
+	// Squash combines the messages; edit stops so staged changes are folded into the commit
+	if err := repo.Reset(original, repository.ResetHard); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	todo = fmt.Sprintf("edit %s\nsquash %s\npick %s\npick %s\n", commits[0][:8], commits[1][:8], commits[2][:8], commits[3][:8])
+	outcome, err = repo.Rebase(base, repository.RebaseOptions{Interactive: true, Edit: editor(todo, &shown)})
+	if err != nil {
+		t.Fatalf("Interactive rebase failed: %v", err)
+	}
+	if !outcome.Editing || outcome.Stopped != commits[0] {
+		t.Fatalf("Expected the rebase to stop for editing %s, got %+v", commits[0][:8], outcome)
+	}
+	WriteTestFile(t, tempDir, "c1.txt", "amended\n")
+	if err := repo.Add("c1.txt"); err != nil {
+		t.Fatalf("Failed to add c1.txt: %v", err)
+	}
+	if outcome, err = repo.RebaseContinue(editor("", &shown)); err != nil {
+		t.Fatalf("Continue failed: %v", err)
+	}
+
+	tip := readCommitObject(t, repo, outcome.Head)
+	squashed := readCommitObject(t, repo, readCommitObject(t, repo, tip.ParentHash()).ParentHash())
+	if squashed.Message() != "C1\n\nC2" || squashed.ParentHash() != base {
+		t.Errorf("Expected C1 and C2 squashed on the base, got %q", squashed.Message())
+	}
+	if ReadTestFile(t, tempDir, "c1.txt") != "amended\n" || ReadTestFile(t, tempDir, "c2.txt") != "c2.txt\n" {
+		t.Errorf("Expected the amendment and the squashed changes in the working tree")
+	}
+
+	// Bad todo lists leave no rebase behind
+	for _, bad := range []string{"", "squash " + commits[0][:8], "bogus " + commits[0][:8], "pick no-such-commit"} {
+		if _, err := repo.Rebase(base, repository.RebaseOptions{Interactive: true, Edit: editor(bad, &shown)}); err == nil {
+			t.Errorf("Expected todo list %q to be rejected", bad)
+		}
+		if status, _ := repo.Status(); status.Rebasing != "" {
+			t.Errorf("Expected no rebase in progress after rejecting %q", bad)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+)
+
+// TestReflog tests recording ref changes and resolving "<ref>@{n}"
+func TestReflog(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+
+	if err := repo.CheckoutNewBranch("topic", first, false); err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+	third := CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "topic\n"}, "Topic work")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+
+	entries, err := repo.Reflog("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to read HEAD reflog: %v", err)
+	}
+	expected := []struct {
+		hash   string
+		reason string
+	}{
+		{second, "checkout: moving from topic to master"},
+		{third, "commit: Topic work"},
+		{first, "checkout: moving from master to topic"},
+		{second, "commit: Second"},
+		{first, "commit (initial): First"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d HEAD reflog entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, want := range expected {
+		if entries[i].NewHash != want.hash || entries[i].Reason != want.reason {
+			t.Errorf("HEAD@{%d}: expected %s %q, got %s %q", i, want.hash[:8], want.reason, entries[i].NewHash, entries[i].Reason)
+		}
+		if entries[i].Identity == "" || entries[i].Timestamp.IsZero() {
+			t.Errorf("HEAD@{%d}: expected an identity and a time, got %+v", i, entries[i])
+		}
+	}
+	if entries[4].OldHash != "" || entries[3].OldHash != first {
+		t.Errorf("Expected old values to chain, got %+v", entries)
+	}
+
+	// Branch reflogs only record that branch's moves
+	topicLog, err := repo.Reflog("topic")
+	if err != nil {
+		t.Fatalf("Failed to read topic reflog: %v", err)
+	}
+	if len(topicLog) != 2 || topicLog[1].Reason != "branch: Created from "+first {
+		t.Errorf("Unexpected topic reflog: %+v", topicLog)
+	}
+
+	tests := []struct {
+		rev      string
+		expected string
+	}{
+		{"HEAD@{0}", second},
+		{"HEAD@{1}", third},
+		{"HEAD@{2}", first},
+		{"master@{1}", first},
+		{"@{1}", first},
+		{"topic@{1}", first},
+		{"HEAD@{1}~1", first},
+	}
+	for _, test := range tests {
+		got, err := repo.ResolveRevision(test.rev)
+		if err != nil {
+			t.Errorf("ResolveRevision(%q) failed: %v", test.rev, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("ResolveRevision(%q) = %s, want %s", test.rev, got, test.expected)
+		}
+	}
+	if _, err := repo.ResolveRevision("master@{5}"); err == nil || !strings.Contains(err.Error(), "only has 2 entries") {
+		t.Errorf("Expected a too-short reflog error, got %v", err)
+	}
+
+	// Renaming moves the history; deleting drops it but HEAD's reflog still remembers its commits
+	if err := repo.RenameBranch("topic", "feature/topic", false); err != nil {
+		t.Fatalf("Failed to rename topic: %v", err)
+	}
+	if got, err := repo.ResolveRevision("feature/topic@{2}"); err != nil || got != first {
+		t.Errorf("Expected the renamed branch to keep its history, got %s (%v)", got, err)
+	}
+	if _, err := repo.DeleteBranch("feature/topic", true); err != nil {
+		t.Fatalf("Failed to delete branch: %v", err)
+	}
+	if log, _ := repo.GetStorage().ReadReflog("refs/heads/feature/topic"); len(log) != 0 {
+		t.Errorf("Expected the deleted branch's reflog to be removed, got %+v", log)
+	}
+
+	headLog, err := repo.Reflog("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to read HEAD's reflog: %v", err)
+	}
+	found := false
+	for _, entry := range headLog {
+		found = found || entry.NewHash == third
+	}
+	if !found {
+		t.Errorf("Expected the deleted branch's commit %s to stay in HEAD's reflog", third[:8])
+	}
+
+	// Tags are not logged
+	if _, err := repo.CreateTag("v1", "", "", false); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if log, _ := repo.GetStorage().ReadReflog("refs/tags/v1"); len(log) != 0 {
+		t.Errorf("Expected tags to have no reflog, got %+v", log)
+	}
+}
+
+// TestReflogCommand tests the reflog command output
+func TestReflogCommand(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+
+	output, err := CaptureOutput(t, func() error { return commands.ReflogCommand(nil) })
+	if err != nil {
+		t.Fatalf("Reflog command failed: %v", err)
+	}
+	expected := second[:8] + " HEAD@{0}: commit: Second\n" +
+		first[:8] + " HEAD@{1}: commit (initial): First\n"
+	if output != expected {
+		t.Errorf("Expected reflog output\n%q\ngot\n%q", expected, output)
+	}
+
+	output, err = CaptureOutput(t, func() error { return commands.ReflogCommand([]string{"master"}) })
+	if err != nil {
+		t.Fatalf("Reflog command failed: %v", err)
+	}
+	if !strings.HasPrefix(output, second[:8]+" master@{0}: commit: Second\n") {
+		t.Errorf("Unexpected master reflog output %q", output)
+	}
+
+	if err := commands.ReflogCommand([]string{"missing"}); err == nil {
+		t.Errorf("Expected an unknown ref to fail")
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xhad/yag/internal/storage"
+)
+
+// TestNestedBranches tests branches with nested names such as feature/login
+func TestNestedBranches(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+
+	if err := repo.CreateBranch("feature/login"); err != nil {
+		t.Fatalf("Failed to create nested branch: %v", err)
+	}
+
+	branches, err := repo.ListBranches()
+	if err != nil {
+		t.Fatalf("Failed to list branches: %v", err)
+	}
+	if len(branches) != 2 || branches[0] != "feature/login" || branches[1] != "master" {
+		t.Errorf("Expected [feature/login master], got %v", branches)
+	}
+
+	// A ref cannot be both a branch and a directory of branches
+	if err := repo.CreateBranch("feature"); err == nil {
+		t.Errorf("Expected creating 'feature' next to 'feature/login' to fail")
+	}
+	if err := repo.CreateBranch("feature/login/v2"); err == nil {
+		t.Errorf("Expected creating a branch below 'feature/login' to fail")
+	}
+
+	// Committing on a nested branch moves it
+	if err := repo.Checkout("feature/login", false); err != nil {
+		t.Fatalf("Failed to checkout nested branch: %v", err)
+	}
+	if branch, _ := repo.GetCurrentBranch(); branch != "feature/login" {
+		t.Errorf("Expected to be on feature/login, got %q", branch)
+	}
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+
+	store := repo.GetStorage()
+	if hash, _ := store.GetRef("feature/login"); hash != second {
+		t.Errorf("Expected feature/login at %s, got %s", second, hash)
+	}
+	if hash, _ := store.GetRef("master"); hash != first {
+		t.Errorf("Expected master to stay at %s, got %s", first, hash)
+	}
+
+	// Deleting the last ref in a directory prunes the directory but keeps the namespace
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	if err := store.DeleteRef("refs/heads/feature/login"); err != nil {
+		t.Fatalf("Failed to delete nested branch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".yag", "refs", "heads", "feature")); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty feature directory to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".yag", "refs", "heads")); err != nil {
+		t.Errorf("Expected refs/heads to be kept: %v", err)
+	}
+	if err := repo.CreateBranch("feature"); err != nil {
+		t.Errorf("Expected 'feature' to be available again: %v", err)
+	}
+}
+
+// TestRefNamespaces tests fully qualified ref names, listing by prefix, symbolic refs and deletion
+func TestRefNamespaces(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	commit := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	store := repo.GetStorage()
+
+	// Any namespace can hold refs
+	for _, name := range []string{"refs/remotes/origin/master", "refs/notes/commits", "refs/stash"} {
+		if err := store.UpdateRef(name, commit); err != nil {
+			t.Fatalf("Failed to update %s: %v", name, err)
+		}
+	}
+	if _, err := repo.CreateTag("v1", "", "", false); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	all, err := store.ListRefsWithPrefix("refs/")
+	if err != nil {
+		t.Fatalf("Failed to list refs: %v", err)
+	}
+	for _, name := range []string{"refs/heads/master", "refs/tags/v1", "refs/remotes/origin/master", "refs/notes/commits", "refs/stash"} {
+		if all[name] != commit {
+			t.Errorf("Expected %s in the ref listing, got %v", name, all)
+		}
+	}
+
+	remotes, err := storage.ListRefsIn(store, "remotes")
+	if err != nil {
+		t.Fatalf("Failed to list remotes: %v", err)
+	}
+	if len(remotes) != 1 || remotes["origin/master"] != commit {
+		t.Errorf("Expected origin/master as the only remote-tracking ref, got %v", remotes)
+	}
+
+	// Symbolic refs resolve through their target, and updating one moves the target
+	if err := store.UpdateSymbolicRef("refs/remotes/origin/HEAD", "refs/remotes/origin/master"); err != nil {
+		t.Fatalf("Failed to create symbolic ref: %v", err)
+	}
+	if target, _ := store.GetSymbolicRef("refs/remotes/origin/HEAD"); target != "refs/remotes/origin/master" {
+		t.Errorf("Expected symbolic ref target refs/remotes/origin/master, got %q", target)
+	}
+	if target, _ := store.GetSymbolicRef("refs/stash"); target != "" {
+		t.Errorf("Expected refs/stash not to be symbolic, got target %q", target)
+	}
+	if target, _ := store.GetSymbolicRef(storage.HeadFile); target != "refs/heads/master" {
+		t.Errorf("Expected HEAD to point to refs/heads/master, got %q", target)
+	}
+
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+	if err := store.UpdateRef("refs/remotes/origin/HEAD", second); err != nil {
+		t.Fatalf("Failed to update through symbolic ref: %v", err)
+	}
+	if hash, _ := store.GetRef("refs/remotes/origin/master"); hash != second {
+		t.Errorf("Expected the symbolic ref's target to move to %s, got %s", second, hash)
+	}
+
+	// Deleting a symbolic ref leaves its target alone
+	if err := store.DeleteRef("refs/remotes/origin/HEAD"); err != nil {
+		t.Fatalf("Failed to delete symbolic ref: %v", err)
+	}
+	if _, err := store.GetRef("refs/remotes/origin/master"); err != nil {
+		t.Errorf("Deleting a symbolic ref must not delete its target: %v", err)
+	}
+	if err := store.DeleteRef("refs/remotes/origin/HEAD"); err == nil {
+		t.Errorf("Expected an error deleting a missing ref")
+	}
+
+	// Names cannot escape the refs directory
+	for _, name := range []string{"refs/../HEAD", "refs/heads//x", "refs/heads/"} {
+		if err := store.UpdateRef(name, commit); err == nil {
+			t.Errorf("Expected ref name %q to be rejected", name)
+		}
+	}
+
+	// Checking out a tag by name detaches HEAD instead of treating the tag as a branch
+	if err := repo.Checkout("v1", false); err != nil {
+		t.Fatalf("Failed to checkout tag: %v", err)
+	}
+	if detached, _ := repo.IsDetached(); !detached {
+		t.Errorf("Expected HEAD to be detached after checking out a tag")
+	}
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// TestResetModes tests soft, mixed and hard resets
+func TestResetModes(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n", "b.txt": "new\n"}, "Second")
+	store := repo.GetStorage()
+
+	// Soft: only the branch moves, so the second commit's changes are staged
+	if err := repo.Reset("HEAD~1", repository.ResetSoft); err != nil {
+		t.Fatalf("Soft reset failed: %v", err)
+	}
+	if hash, _ := store.GetRef("master"); hash != first {
+		t.Errorf("Expected master at %s after soft reset, got %s", first, hash)
+	}
+	if orig, _ := store.ReadState(storage.OrigHeadFile); orig != second {
+		t.Errorf("Expected ORIG_HEAD to remember %s, got %s", second, orig)
+	}
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Staged["a.txt"] != repository.ChangeModified || status.Staged["b.txt"] != repository.ChangeAdded {
+		t.Errorf("Expected the undone commit's changes to be staged, got %v", status.Staged)
+	}
+
+	// Mixed: the index follows, the working tree keeps the changes
+	if err := repo.Reset("", repository.ResetMixed); err != nil {
+		t.Fatalf("Mixed reset failed: %v", err)
+	}
+	if status, _ = repo.Status(); len(status.Staged) != 0 || status.Unstaged["a.txt"] != repository.ChangeModified || !status.Untracked["b.txt"] {
+		t.Errorf("Expected unstaged and untracked changes after mixed reset, got %+v", status)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "two\n" {
+		t.Errorf("Mixed reset must not touch the working tree")
+	}
+
+	// Hard: back to the second commit through the reflog (before the two resets), discarding everything
+	if err := repo.Reset("HEAD@{2}", repository.ResetHard); err != nil {
+		t.Fatalf("Hard reset to the reflog failed: %v", err)
+	}
+	if hash, _ := store.GetRef("master"); hash != second {
+		t.Errorf("Expected master back at %s, got %s", second, hash)
+	}
+	WriteTestFile(t, tempDir, "a.txt", "local edit\n")
+	WriteTestFile(t, tempDir, "c.txt", "staged only\n")
+	if err := repo.Add("c.txt"); err != nil {
+		t.Fatalf("Failed to add c.txt: %v", err)
+	}
+	WriteTestFile(t, tempDir, "untracked.txt", "keep me\n")
+
+	if err := repo.Reset(first[:10], repository.ResetHard); err != nil {
+		t.Fatalf("Hard reset failed: %v", err)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "one\n" {
+		t.Errorf("Expected a.txt from the first commit after hard reset")
+	}
+	for _, gone := range []string{"b.txt", "c.txt"} {
+		if _, err := os.Stat(filepath.Join(tempDir, gone)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed by hard reset", gone)
+		}
+	}
+	if ReadTestFile(t, tempDir, "untracked.txt") != "keep me\n" {
+		t.Errorf("Hard reset must leave untracked files alone")
+	}
+	if status, _ = repo.Status(); len(status.Staged)+len(status.Unstaged) != 0 {
+		t.Errorf("Expected a clean tree after hard reset, got %+v", status)
+	}
+
+	entries, err := repo.Reflog("master")
+	if err != nil {
+		t.Fatalf("Failed to read reflog: %v", err)
+	}
+	if entries[0].Reason != "reset: moving to "+first[:10] {
+		t.Errorf("Expected the reset in the reflog, got %q", entries[0].Reason)
+	}
+
+	if err := repo.Reset("no-such-rev", repository.ResetHard); err == nil {
+		t.Errorf("Expected an unknown revision to fail")
+	}
+}
+
+// TestResetAbandonsMerge tests that a hard reset clears conflicts and merge state
+func TestResetAbandonsMerge(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "base\n"}, "Base")
+	divergeBranches(t, repo, tempDir, map[string]string{"a.txt": "ours\n"}, map[string]string{"a.txt": "theirs\n"})
+	outcome, err := repo.Merge("feature")
+	if err != nil || len(outcome.Conflicts) == 0 {
+		t.Fatalf("Expected a conflicted merge, got %+v (%v)", outcome, err)
+	}
+
+	if err := repo.Reset("", repository.ResetSoft); err == nil {
+		t.Errorf("Expected a soft reset during a merge to fail")
+	}
+	if err := repo.Reset("", repository.ResetHard); err != nil {
+		t.Fatalf("Hard reset failed: %v", err)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "ours\n" {
+		t.Errorf("Expected the conflicted file to be restored")
+	}
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Merging || len(status.Unmerged) != 0 {
+		t.Errorf("Expected the merge to be abandoned, got %+v", status)
+	}
+}
+
+// TestResetAbandonsCherryPick tests that a hard reset ends a stopped cherry-pick with nothing left to replay
+func TestResetAbandonsCherryPick(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "base\n"}, "Base")
+	divergeBranches(t, repo, tempDir, map[string]string{"a.txt": "ours\n"}, map[string]string{"a.txt": "theirs\n"})
+	outcome, err := repo.CherryPick([]string{"feature"})
+	if err != nil || outcome.Stopped == "" {
+		t.Fatalf("Expected a stopped cherry-pick, got %+v (%v)", outcome, err)
+	}
+
+	if err := repo.Reset("", repository.ResetHard); err != nil {
+		t.Fatalf("Hard reset failed: %v", err)
+	}
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Picking != "" || status.PicksLeft != 0 || !status.IsClean() {
+		t.Errorf("Expected the cherry-pick to be abandoned, got %+v", status)
+	}
+	if head, _ := repo.GetStorage().ReadState(storage.SequencerHeadFile); head != "" {
+		t.Errorf("Expected the sequencer to be cleared, got start %q", head)
+	}
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Errorf("Expected checkout to work after the reset, got %v", err)
+	}
+}
+
+// TestResetPaths tests resetting index entries without moving HEAD
+func TestResetPaths(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"src/a.txt": "one\n", "b.txt": "b\n"}, "First")
+	CommitTestFiles(t, repo, tempDir, map[string]string{"src/a.txt": "two\n"}, "Second")
+
+	WriteTestFile(t, tempDir, "src/a.txt", "three\n")
+	WriteTestFile(t, tempDir, "src/new.txt", "new\n")
+	for _, file := range []string{"src/a.txt", "src/new.txt"} {
+		if err := repo.Add(file); err != nil {
+			t.Fatalf("Failed to add %s: %v", file, err)
+		}
+	}
+
+	// Resetting against HEAD unstages, dropping entries HEAD does not have
+	output, err := CaptureOutput(t, func() error {
+		return commands.ResetCommand([]string{"--", "src"}, commands.ResetOptions{})
+	})
+	if err != nil {
+		t.Fatalf("Path reset failed: %v", err)
+	}
+	if !strings.Contains(output, "Unstaged changes after reset:\nM\tsrc/a.txt\n") {
+		t.Errorf("Expected the unstaged changes to be listed, got %q", output)
+	}
+
+	index, err := repo.GetStorage().GetIndexEntries()
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if index[filepath.Join("src", "a.txt")] != core.NewBlob([]byte("two\n")).ID() {
+		t.Errorf("Expected src/a.txt staged as in HEAD")
+	}
+	if _, staged := index[filepath.Join("src", "new.txt")]; staged {
+		t.Errorf("Expected src/new.txt to be unstaged")
+	}
+	if ReadTestFile(t, tempDir, "src/a.txt") != "three\n" {
+		t.Errorf("Path reset must not touch the working tree")
+	}
+
+	// Resetting against an older commit stages its version
+	if _, err := repo.ResetPaths(first, []string{"src/a.txt"}); err != nil {
+		t.Fatalf("Path reset to a commit failed: %v", err)
+	}
+	index, _ = repo.GetStorage().GetIndexEntries()
+	if index[filepath.Join("src", "a.txt")] != core.NewBlob([]byte("one\n")).ID() {
+		t.Errorf("Expected src/a.txt staged as in the first commit")
+	}
+
+	if err := commands.ResetCommand([]string{"HEAD", "--", "b.txt"}, commands.ResetOptions{Hard: true}); err == nil {
+		t.Errorf("Expected a hard reset with paths to fail")
+	}
+	if err := commands.ResetCommand(nil, commands.ResetOptions{Soft: true, Hard: true}); err == nil {
+		t.Errorf("Expected conflicting modes to fail")
+	}
+	if _, err := repo.ResetPaths("", []string{"missing.txt"}); err == nil {
+		t.Errorf("Expected a pathspec that matches nothing to fail")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/xhad/yag/internal/commands"
@@ -188,3 +189,82 @@ func readIndexFile(path string) (map[string]string, error) {
 
 	return entries, nil
 }
+
+// TestRestoreWorkingTree tests discarding local modifications from the index or from a commit
+func TestRestoreWorkingTree(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	firstCommit := CommitTestFiles(t, repo, tempDir, map[string]string{
+		"notes.txt":                     "v1",
+		filepath.Join("src", "main.go"): "package main",
+		filepath.Join("src", "util.go"): "package util",
+		filepath.Join("src", "README"):  "sources",
+	}, "Initial commit")
+
+	// Stage a new version of notes.txt, then break it locally
+	WriteTestFile(t, tempDir, "notes.txt", "v2")
+	if err := repo.Add("notes.txt"); err != nil {
+		t.Fatalf("Failed to stage notes.txt: %v", err)
+	}
+	WriteTestFile(t, tempDir, "notes.txt", "bad edit")
+
+	// Restoring without a source uses the staged version
+	if err := commands.RestoreCommand([]string{"notes.txt"}, false); err != nil {
+		t.Fatalf("RestoreCommand failed: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "notes.txt"); got != "v2" {
+		t.Errorf("notes.txt should be restored from the index, got %q", got)
+	}
+
+	// Restoring from a commit uses that commit's tree
+	if err := commands.RestoreCommandWithOptions([]string{"notes.txt"}, commands.RestoreOptions{Source: firstCommit}); err != nil {
+		t.Fatalf("RestoreCommand with --source failed: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, "notes.txt"); got != "v1" {
+		t.Errorf("notes.txt should be restored from the first commit, got %q", got)
+	}
+
+	// A file that already matches the source is not reported
+	output, err := CaptureOutput(t, func() error {
+		return commands.RestoreCommandWithOptions([]string{"notes.txt"}, commands.RestoreOptions{Source: firstCommit})
+	})
+	if err != nil || output != "" {
+		t.Errorf("Expected no output for an unchanged file, got %q (%v)", output, err)
+	}
+
+	// Directory pathspecs restore everything below them
+	WriteTestFile(t, tempDir, filepath.Join("src", "main.go"), "broken")
+	WriteTestFile(t, tempDir, filepath.Join("src", "README"), "broken")
+	restored, err := repo.Restore([]string{"src"}, storage.DefaultBranch)
+	if err != nil {
+		t.Fatalf("Restore of a directory failed: %v", err)
+	}
+	// Only files that differed from the source are reported; src/util.go was untouched
+	if strings.Join(restored, ",") != "src/README,src/main.go" {
+		t.Errorf("Expected src/README and src/main.go to be restored, got %v", restored)
+	}
+	if got := ReadTestFile(t, tempDir, filepath.Join("src", "main.go")); got != "package main" {
+		t.Errorf("src/main.go should be restored, got %q", got)
+	}
+
+	// Glob pathspecs only touch matching files
+	WriteTestFile(t, tempDir, filepath.Join("src", "util.go"), "broken")
+	WriteTestFile(t, tempDir, filepath.Join("src", "README"), "still broken")
+	if _, err := repo.Restore([]string{filepath.Join("src", "*.go")}, "HEAD"); err != nil {
+		t.Fatalf("Restore of a glob failed: %v", err)
+	}
+	if got := ReadTestFile(t, tempDir, filepath.Join("src", "util.go")); got != "package util" {
+		t.Errorf("src/util.go should be restored by the glob, got %q", got)
+	}
+	if got := ReadTestFile(t, tempDir, filepath.Join("src", "README")); got != "still broken" {
+		t.Errorf("src/README should not match the glob, got %q", got)
+	}
+
+	// Error cases
+	if _, err := repo.Restore([]string{"missing.txt"}, "HEAD"); err == nil {
+		t.Errorf("Restore should fail for a pathspec that matches nothing")
+	}
+	if _, err := repo.Restore([]string{"notes.txt"}, "no-such-branch"); err == nil {
+		t.Errorf("Restore should fail for an unknown source revision")
+	}
+}
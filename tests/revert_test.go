@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// TestRevert tests undoing a commit while keeping later changes
+func TestRevert(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\ntwo\nthree\n", "b.txt": "b\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\nTWO\nthree\n", "c.txt": "new\n"}, "Change two")
+	third := CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b changed\n"}, "Change b")
+
+	outcome, err := repo.Revert("HEAD~1")
+	if err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if len(outcome.Conflicts) != 0 {
+		t.Fatalf("Expected a clean revert, got conflicts %v", outcome.Conflicts)
+	}
+
+	if ReadTestFile(t, tempDir, "a.txt") != "one\ntwo\nthree\n" {
+		t.Errorf("Expected the change to a.txt to be undone")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected c.txt, added by the reverted commit, to be removed")
+	}
+	if ReadTestFile(t, tempDir, "b.txt") != "b changed\n" {
+		t.Errorf("Expected later changes to be kept")
+	}
+
+	commit, err := repo.GetStorage().GetHeadCommit()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	if commit.ID() != outcome.CommitHash || commit.ParentHash() != third || len(commit.ParentHashes()) != 1 {
+		t.Errorf("Expected a single-parent commit on top of %s, got %s", third[:8], commit.ID())
+	}
+	expected := "Revert \"Change two\"\n\nThis reverts commit " + second + "."
+	if commit.Message() != expected {
+		t.Errorf("Expected message %q, got %q", expected, commit.Message())
+	}
+
+	entries, err := repo.Reflog("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to read reflog: %v", err)
+	}
+	if entries[0].Reason != "revert: Revert \"Change two\"" {
+		t.Errorf("Expected the revert in the reflog, got %q", entries[0].Reason)
+	}
+	if status, _ := repo.Status(); !status.IsClean() {
+		t.Errorf("Expected a clean tree after revert, got %+v", status)
+	}
+
+	// The changes are gone now, so reverting again has nothing to do
+	if _, err := repo.Revert(second); err == nil || !strings.Contains(err.Error(), "nothing to revert") {
+		t.Errorf("Expected reverting twice to fail, got %v", err)
+	}
+
+	WriteTestFile(t, tempDir, "b.txt", "dirty\n")
+	if _, err := repo.Revert(third); err == nil {
+		t.Errorf("Expected a revert with local changes to fail")
+	}
+}
+
+// TestRevertConflict tests stopping, continuing and aborting a conflicted revert
+func TestRevertConflict(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+	third := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "three\n"}, "Third")
+
+	output, err := CaptureOutput(t, func() error { return commands.RevertCommand(second, commands.RevertOptions{}) })
+	if err == nil || !strings.Contains(err.Error(), "yag revert --continue") {
+		t.Fatalf("Expected the revert to stop on conflicts, got %v", err)
+	}
+	if !strings.Contains(output, "CONFLICT (content): Merge conflict in a.txt") {
+		t.Errorf("Expected the conflict to be reported, got %q", output)
+	}
+
+	content := ReadTestFile(t, tempDir, "a.txt")
+	if !strings.Contains(content, "<<<<<<< HEAD\nthree\n") || !strings.Contains(content, ">>>>>>> parent of "+second[:8]+" (Second)") {
+		t.Errorf("Expected conflict markers, got %q", content)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Reverting != second || len(status.Unmerged) != 1 {
+		t.Errorf("Expected a revert of %s in progress, got %+v", second[:8], status)
+	}
+	if _, err := repo.Merge("master"); err == nil {
+		t.Errorf("Expected a merge during a revert to fail")
+	}
+	if _, err := repo.RevertContinue(); err == nil {
+		t.Errorf("Expected continuing with unresolved conflicts to fail")
+	}
+
+	// Aborting restores HEAD's version
+	if err := repo.RevertAbort(); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "three\n" {
+		t.Errorf("Expected a.txt restored after abort")
+	}
+	if reverting, _ := repo.GetStorage().ReadState(storage.RevertHeadFile); reverting != "" {
+		t.Errorf("Expected the revert state to be removed")
+	}
+
+	// Resolving and continuing records the revert with its message
+	if outcome, err := repo.Revert(second); err != nil || len(outcome.Conflicts) == 0 {
+		t.Fatalf("Expected the revert to conflict again, got %+v (%v)", outcome, err)
+	}
+	WriteTestFile(t, tempDir, "a.txt", "one\n")
+	if err := repo.Add("a.txt"); err != nil {
+		t.Fatalf("Failed to add a.txt: %v", err)
+	}
+	hash, err := repo.RevertContinue()
+	if err != nil {
+		t.Fatalf("Continue failed: %v", err)
+	}
+
+	commit, err := repo.GetStorage().GetHeadCommit()
+	if err != nil {
+		t.Fatalf("Failed to read HEAD: %v", err)
+	}
+	if commit.ID() != hash || len(commit.ParentHashes()) != 1 || commit.ParentHash() != third {
+		t.Errorf("Expected a single-parent revert commit on top of %s", third[:8])
+	}
+	if !strings.HasPrefix(commit.Message(), "Revert \"Second\"") {
+		t.Errorf("Expected the revert message, got %q", commit.Message())
+	}
+	if status, _ = repo.Status(); status.Reverting != "" {
+		t.Errorf("Expected the revert to be concluded")
+	}
+	if err := repo.RevertAbort(); err == nil {
+		t.Errorf("Expected aborting without a revert in progress to fail")
+	}
+}
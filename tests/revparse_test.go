@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// TestResolveRevision tests resolving revision expressions
+func TestResolveRevision(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"src/a.txt": "a1\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"src/a.txt": "a2\n"}, "Second")
+
+	// Make a merge commit whose second parent is on feature
+	if err := repo.CreateBranch("feature"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := repo.Checkout("feature", false); err != nil {
+		t.Fatalf("Failed to checkout feature: %v", err)
+	}
+	side := CommitTestFiles(t, repo, tempDir, map[string]string{"b.txt": "b\n"}, "Side")
+	if err := repo.Checkout("master", false); err != nil {
+		t.Fatalf("Failed to checkout master: %v", err)
+	}
+	third := CommitTestFiles(t, repo, tempDir, map[string]string{"c.txt": "c\n"}, "Third")
+	outcome, err := repo.Merge("feature")
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	merge := outcome.CommitHash
+
+	fileHash := core.NewBlob([]byte("a2\n")).ID()
+
+	tests := []struct {
+		rev      string
+		expected string
+	}{
+		{"HEAD", merge},
+		{"@", merge},
+		{"master", merge},
+		{"refs/heads/feature", side},
+		{merge, merge},
+		{merge[:10], merge},
+		{"HEAD^", third},
+		{"HEAD^1", third},
+		{"HEAD^2", side},
+		{"HEAD~2", second},
+		{"HEAD~3", first},
+		{"master~1~1^", first},
+		{"HEAD^0", merge},
+		{"HEAD@{0}", merge},
+		{"HEAD^2~1", second},
+		{"HEAD:src/a.txt", fileHash},
+		{":src/a.txt", fileHash},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.ResolveRevision(tt.rev)
+		if err != nil {
+			t.Errorf("ResolveRevision(%q) failed: %v", tt.rev, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ResolveRevision(%q) = %s, expected %s", tt.rev, got, tt.expected)
+		}
+	}
+
+	// A directory resolves to its subtree
+	treeHash, err := repo.ResolveRevision(second + ":src")
+	if err != nil {
+		t.Fatalf("Failed to resolve a directory: %v", err)
+	}
+	if obj, err := repo.GetStorage().GetObject(treeHash); err != nil || obj.Type() != core.TreeType {
+		t.Errorf("Expected %s:src to be a tree", second[:8])
+	}
+
+	for _, rev := range []string{"nope", "HEAD~10", "HEAD^3", "HEAD:missing.txt", "zzzz", "ab", "HEAD^{tree}"} {
+		if _, err := repo.ResolveRevision(rev); err == nil {
+			t.Errorf("Expected ResolveRevision(%q) to fail", rev)
+		}
+	}
+
+	// rev-parse prints one hash per expression, and other commands accept expressions too
+	output, err := CaptureOutput(t, func() error {
+		return commands.RevParseCommand([]string{"HEAD~1", "HEAD^2"}, commands.RevParseOptions{Short: true})
+	})
+	if err != nil {
+		t.Fatalf("rev-parse failed: %v", err)
+	}
+	if output != third[:8]+"\n"+side[:8]+"\n" {
+		t.Errorf("Unexpected rev-parse output %q", output)
+	}
+	diffs, err := repo.Diff(repository.DiffOptions{From: "HEAD~3", To: "HEAD^"})
+	if err != nil || len(diffs) != 2 {
+		t.Errorf("Expected diff to accept revision expressions, got %d files (%v)", len(diffs), err)
+	}
+}
+
+// TestAmbiguousShortHash tests that an abbreviated hash matching several objects is rejected
+func TestAmbiguousShortHash(t *testing.T) {
+	_, repo := SetupRepository(t)
+	store := repo.GetStorage()
+
+	// Store blobs until two of them share a four-character prefix
+	seen := make(map[string]string)
+	prefix := ""
+	for i := 0; prefix == ""; i++ {
+		blob := core.NewBlob([]byte(fmt.Sprintf("blob %d", i)))
+		if err := store.StoreObject(blob); err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		if _, dup := seen[blob.ID()[:4]]; dup {
+			prefix = blob.ID()[:4]
+		}
+		seen[blob.ID()[:4]] = blob.ID()
+	}
+
+	_, err := repo.ResolveRevision(prefix)
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected an ambiguity error for %s, got %v", prefix, err)
+	}
+
+	// A longer prefix of one of them is unique again
+	if hash, err := repo.ResolveRevision(seen[prefix]); err != nil || hash != seen[prefix] {
+		t.Errorf("Expected the full hash to resolve, got %s (%v)", hash, err)
+	}
+}
@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/repository"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// TestStash tests saving local changes and applying them on a moved HEAD
+func TestStash(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	base := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\ntwo\nthree\n", "b.txt": "b\n"}, "First")
+
+	if entry, err := repo.StashPush(""); err != nil || entry != nil {
+		t.Fatalf("Expected nothing to stash on a clean tree, got %+v (%v)", entry, err)
+	}
+
+	// A staged new file, a staged change and an unstaged change
+	WriteTestFile(t, tempDir, "new.txt", "new\n")
+	WriteTestFile(t, tempDir, "b.txt", "b staged\n")
+	for _, file := range []string{"new.txt", "b.txt"} {
+		if err := repo.Add(file); err != nil {
+			t.Fatalf("Failed to add %s: %v", file, err)
+		}
+	}
+	WriteTestFile(t, tempDir, "a.txt", "one\ntwo\nTHREE\n")
+	WriteTestFile(t, tempDir, "untracked.txt", "mine\n")
+
+	entry, err := repo.StashPush("")
+	if err != nil {
+		t.Fatalf("StashPush failed: %v", err)
+	}
+	if entry.Message != "WIP on master: "+base[:8]+" First" {
+		t.Errorf("Unexpected stash message %q", entry.Message)
+	}
+
+	if status, _ := repo.Status(); len(status.Staged) != 0 || len(status.Unstaged) != 0 {
+		t.Errorf("Expected the tree reset to HEAD, got %+v", status)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "one\ntwo\nthree\n" || ReadTestFile(t, tempDir, "b.txt") != "b\n" {
+		t.Errorf("Expected tracked files restored to HEAD")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the staged new file to be stashed away")
+	}
+	if ReadTestFile(t, tempDir, "untracked.txt") != "mine\n" {
+		t.Errorf("Expected untracked files to be left alone")
+	}
+
+	// The stash commit records HEAD and the index as parents
+	stash := readCommitObject(t, repo, entry.Commit)
+	parents := stash.ParentHashes()
+	if len(parents) != 2 || parents[0] != base {
+		t.Fatalf("Expected the stash commit to have HEAD and the index as parents, got %v", parents)
+	}
+	if index := readCommitObject(t, repo, parents[1]); !strings.HasPrefix(index.Message(), "index on master: ") {
+		t.Errorf("Expected the index commit message, got %q", index.Message())
+	}
+	if hash, err := repo.ResolveRevision("stash@{0}"); err != nil || hash != entry.Commit {
+		t.Errorf("Expected stash@{0} to resolve to %s, got %s (%v)", entry.Commit[:8], hash, err)
+	}
+
+	// HEAD moves on another branch; the stash still applies with a three-way merge
+	if err := repo.CheckoutNewBranch("other", "", false); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "ONE\ntwo\nthree\n"}, "Change one")
+
+	outcome, err := repo.StashApply("", false)
+	if err != nil {
+		t.Fatalf("StashApply failed: %v", err)
+	}
+	if len(outcome.Conflicts) != 0 || outcome.Dropped {
+		t.Errorf("Expected a clean apply that keeps the entry, got %+v", outcome)
+	}
+	if ReadTestFile(t, tempDir, "a.txt") != "ONE\ntwo\nTHREE\n" {
+		t.Errorf("Expected both changes to a.txt, got %q", ReadTestFile(t, tempDir, "a.txt"))
+	}
+	if ReadTestFile(t, tempDir, "b.txt") != "b staged\n" || ReadTestFile(t, tempDir, "new.txt") != "new\n" {
+		t.Errorf("Expected the stashed files to be restored")
+	}
+
+	// Without --index only new files are staged
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Staged) != 1 || status.Staged["new.txt"] == "" || len(status.Unstaged) != 2 {
+		t.Errorf("Expected new.txt staged and the changes unstaged, got %+v", status)
+	}
+	if _, err := repo.StashApply("", false); err == nil {
+		t.Errorf("Expected applying onto local changes to fail")
+	}
+
+	// With --index the staged changes come back staged
+	if err := repo.Reset("HEAD", repository.ResetHard); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	outcome, err = repo.StashPop("stash@{0}", true)
+	if err != nil {
+		t.Fatalf("StashPop failed: %v", err)
+	}
+	if !outcome.Dropped {
+		t.Errorf("Expected pop to drop the entry")
+	}
+	status, _ = repo.Status()
+	if len(status.Staged) != 2 || status.Staged["b.txt"] == "" || len(status.Unstaged) != 1 || status.Unstaged["a.txt"] == "" {
+		t.Errorf("Expected b.txt and new.txt staged and a.txt unstaged, got %+v", status)
+	}
+	if stashes, err := repo.StashList(); err != nil || len(stashes) != 0 {
+		t.Errorf("Expected no entries once the last one is dropped, got %+v (%v)", stashes, err)
+	}
+	if _, err := repo.GetStorage().GetRef(storage.StashRef); err == nil {
+		t.Errorf("Expected refs/stash to be deleted with its last entry")
+	}
+	if _, err := repo.StashApply("", false); err == nil {
+		t.Errorf("Expected applying without entries to fail")
+	}
+}
+
+// TestStashDrop tests that dropping an entry keeps the others in order
+func TestStashDrop(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "a\n"}, "First")
+
+	var hashes []string
+	for _, content := range []string{"first\n", "second\n", "third\n"} {
+		WriteTestFile(t, tempDir, "a.txt", content)
+		entry, err := repo.StashPush(strings.TrimSpace(content))
+		if err != nil {
+			t.Fatalf("StashPush failed: %v", err)
+		}
+		hashes = append(hashes, entry.Commit)
+	}
+
+	output, err := CaptureOutput(t, func() error { return commands.StashCommand([]string{"list"}, commands.StashOptions{}) })
+	if err != nil {
+		t.Fatalf("stash list failed: %v", err)
+	}
+	expected := "stash@{0}: On master: third\nstash@{1}: On master: second\nstash@{2}: On master: first\n"
+	if output != expected {
+		t.Errorf("Expected list %q, got %q", expected, output)
+	}
+
+	output, err = CaptureOutput(t, func() error { return commands.StashCommand([]string{"drop", "stash@{1}"}, commands.StashOptions{}) })
+	if err != nil {
+		t.Fatalf("stash drop failed: %v", err)
+	}
+	if output != "Dropped stash@{1} ("+hashes[1][:8]+")\n" {
+		t.Errorf("Unexpected drop output %q", output)
+	}
+
+	stashes, err := repo.StashList()
+	if err != nil {
+		t.Fatalf("StashList failed: %v", err)
+	}
+	if len(stashes) != 2 || stashes[0].Commit != hashes[2] || stashes[1].Commit != hashes[0] {
+		t.Fatalf("Expected the third and first entries to remain, got %+v", stashes)
+	}
+	if ref, _ := repo.GetStorage().GetRef(storage.StashRef); ref != hashes[2] {
+		t.Errorf("Expected refs/stash to stay on the newest entry")
+	}
+
+	// Dropping the newest entry moves refs/stash back
+	if _, err := repo.StashDrop(""); err != nil {
+		t.Fatalf("StashDrop failed: %v", err)
+	}
+	if ref, _ := repo.GetStorage().GetRef(storage.StashRef); ref != hashes[0] {
+		t.Errorf("Expected refs/stash to move to the remaining entry")
+	}
+	if _, err := repo.StashDrop("1"); err == nil {
+		t.Errorf("Expected dropping a missing entry to fail")
+	}
+}
+
+// TestStashConflict tests that a conflicting pop writes markers and keeps the entry
+func TestStashConflict(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+
+	WriteTestFile(t, tempDir, "a.txt", "stashed\n")
+	if _, err := repo.StashPush(""); err != nil {
+		t.Fatalf("StashPush failed: %v", err)
+	}
+	CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "committed\n"}, "Second")
+
+	output, err := CaptureOutput(t, func() error { return commands.StashCommand([]string{"pop"}, commands.StashOptions{}) })
+	if err == nil || !strings.Contains(err.Error(), "left conflicts") {
+		t.Fatalf("Expected the pop to stop on conflicts, got %v", err)
+	}
+	if !strings.Contains(output, "CONFLICT (content): Merge conflict in a.txt") || !strings.Contains(output, "stash entry is kept") {
+		t.Errorf("Expected the conflict reported and the entry kept, got %q", output)
+	}
+
+	content := ReadTestFile(t, tempDir, "a.txt")
+	if !strings.Contains(content, "<<<<<<< Updated upstream\ncommitted\n") || !strings.Contains(content, "stashed\n>>>>>>> Stashed changes") {
+		t.Errorf("Expected conflict markers, got %q", content)
+	}
+	if status, _ := repo.Status(); len(status.Unmerged) != 1 {
+		t.Errorf("Expected a.txt to be unmerged, got %+v", status)
+	}
+	if stashes, _ := repo.StashList(); len(stashes) != 1 {
+		t.Errorf("Expected the entry to be kept after a conflicted pop")
+	}
+	if _, err := repo.StashPush(""); err == nil {
+		t.Errorf("Expected stashing unmerged files to fail")
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/xhad/yag/internal/commands"
 	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
 	"github.com/xhad/yag/internal/storage"
 )
 
@@ -209,3 +211,85 @@ func TestStatusCommand(t *testing.T) {
 		t.Fatalf("StatusCommand failed: %v", err)
 	}
 }
+
+// TestStatusThreeWay tests that status compares the working tree, the index and the HEAD commit
+func TestStatusThreeWay(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	CommitTestFiles(t, repo, tempDir, map[string]string{
+		"staged.txt":   "one",
+		"unstaged.txt": "two",
+		"removed.txt":  "three",
+	}, "Initial commit")
+
+	// Right after a commit nothing is reported, committed files are not untracked
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.IsClean() {
+		t.Fatalf("Status should be clean right after a commit, got %+v", status)
+	}
+
+	// Create one change of each kind
+	WriteTestFile(t, tempDir, "staged.txt", "one, edited")
+	WriteTestFile(t, tempDir, "new.txt", "brand new")
+	for _, file := range []string{"staged.txt", "new.txt"} {
+		if err := repo.Add(file); err != nil {
+			t.Fatalf("Failed to add %s: %v", file, err)
+		}
+	}
+	WriteTestFile(t, tempDir, "unstaged.txt", "two, edited")
+	if err := os.Remove(filepath.Join(tempDir, "removed.txt")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	WriteTestFile(t, tempDir, "untracked.txt", "not added")
+
+	status, err = repo.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+
+	expectedStaged := map[string]repository.FileChange{
+		"staged.txt": repository.ChangeModified,
+		"new.txt":    repository.ChangeAdded,
+	}
+	for file, change := range expectedStaged {
+		if status.Staged[file] != change {
+			t.Errorf("Expected %s to be staged as %s, got %q", file, change, status.Staged[file])
+		}
+	}
+	if len(status.Staged) != len(expectedStaged) {
+		t.Errorf("Unexpected staged changes: %v", status.Staged)
+	}
+
+	expectedUnstaged := map[string]repository.FileChange{
+		"unstaged.txt": repository.ChangeModified,
+		"removed.txt":  repository.ChangeDeleted,
+	}
+	for file, change := range expectedUnstaged {
+		if status.Unstaged[file] != change {
+			t.Errorf("Expected %s to be unstaged as %s, got %q", file, change, status.Unstaged[file])
+		}
+	}
+	if len(status.Unstaged) != len(expectedUnstaged) {
+		t.Errorf("Unexpected unstaged changes: %v", status.Unstaged)
+	}
+
+	if len(status.Untracked) != 1 || !status.Untracked["untracked.txt"] {
+		t.Errorf("Expected only untracked.txt to be untracked, got %v", status.Untracked)
+	}
+
+	// The status command labels each change
+	output, err := CaptureOutput(t, func() error {
+		return commands.StatusCommand([]string{})
+	})
+	if err != nil {
+		t.Fatalf("StatusCommand failed: %v", err)
+	}
+	for _, line := range []string{"added:    new.txt", "modified: staged.txt", "deleted:  removed.txt"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Status output should contain %q, got:\n%s", line, output)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhad/yag/internal/commands"
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+)
+
+// TestTags tests creating, resolving, listing and deleting lightweight and annotated tags
+func TestTags(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	first := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "First")
+	second := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "two\n"}, "Second")
+
+	// A lightweight tag is a plain ref to the commit
+	hash, err := repo.CreateTag("v0.1", first[:10], "", false)
+	if err != nil {
+		t.Fatalf("Failed to create lightweight tag: %v", err)
+	}
+	if hash != first {
+		t.Errorf("Lightweight tag should point at %s, got %s", first, hash)
+	}
+
+	// An annotated tag is an object of its own that names the commit
+	tagHash, err := repo.CreateTag("v1.0", "", "First release", false)
+	if err != nil {
+		t.Fatalf("Failed to create annotated tag: %v", err)
+	}
+	if tagHash == second {
+		t.Fatalf("Annotated tag should point at a tag object, not the commit")
+	}
+
+	info, err := repo.GetTag("v1.0")
+	if err != nil {
+		t.Fatalf("Failed to get tag: %v", err)
+	}
+	if !info.Annotated() || info.Target != second {
+		t.Errorf("Expected an annotated tag of %s, got %+v", second, info)
+	}
+	if info.Tag.Message() != "First release" || info.Tag.TargetType() != core.CommitType || info.Tag.Tagger() == "" {
+		t.Errorf("Unexpected tag object contents: %+v", info.Tag)
+	}
+
+	// Tags take part in revision expressions; ancestry operators follow annotated tags to the commit
+	tests := []struct {
+		rev      string
+		expected string
+	}{
+		{"v0.1", first},
+		{"tags/v0.1", first},
+		{"refs/tags/v0.1", first},
+		{"v1.0", tagHash},
+		{"v1.0^0", second},
+		{"v1.0~1", first},
+	}
+	for _, test := range tests {
+		got, err := repo.ResolveRevision(test.rev)
+		if err != nil {
+			t.Errorf("ResolveRevision(%q) failed: %v", test.rev, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("ResolveRevision(%q) = %s, want %s", test.rev, got, test.expected)
+		}
+	}
+
+	// Commands that need a commit peel annotated tags
+	diffs, err := repo.Diff(repository.DiffOptions{From: "v0.1", To: "v1.0"})
+	if err != nil {
+		t.Fatalf("Diff between tags failed: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "a.txt" {
+		t.Errorf("Expected a.txt to differ between the tags, got %v", diffs)
+	}
+
+	// Existing tags are only replaced when forced
+	if _, err := repo.CreateTag("v0.1", "", "", false); err == nil {
+		t.Errorf("Expected an error when creating an existing tag")
+	}
+	if hash, err := repo.CreateTag("v0.1", "", "", true); err != nil || hash != second {
+		t.Errorf("Forced tag should move to %s, got %s (%v)", second, hash, err)
+	}
+
+	// Invalid names are rejected
+	for _, name := range []string{"", "bad name", "a..b", "x~1", "-v"} {
+		if _, err := repo.CreateTag(name, "", "", false); err == nil {
+			t.Errorf("Expected tag name %q to be rejected", name)
+		}
+	}
+
+	tags, err := repo.ListTags()
+	if err != nil {
+		t.Fatalf("Failed to list tags: %v", err)
+	}
+	if strings.Join(tags, ",") != "v0.1,v1.0" {
+		t.Errorf("Expected tags v0.1,v1.0, got %v", tags)
+	}
+
+	if err := repo.DeleteTag("v0.1"); err != nil {
+		t.Fatalf("Failed to delete tag: %v", err)
+	}
+	if err := repo.DeleteTag("v0.1"); err == nil {
+		t.Errorf("Expected an error when deleting a missing tag")
+	}
+	if _, err := repo.ResolveRevision("v0.1"); err == nil {
+		t.Errorf("Deleted tag should no longer resolve")
+	}
+}
+
+// TestTagCommand tests the tag command's create, list, show and delete output
+func TestTagCommand(t *testing.T) {
+	tempDir, repo := SetupRepository(t)
+
+	commit := CommitTestFiles(t, repo, tempDir, map[string]string{"a.txt": "one\n"}, "Initial")
+
+	if err := commands.TagCommand([]string{"v1"}, commands.TagOptions{Annotate: true}); err == nil {
+		t.Errorf("Expected -a without a message to fail")
+	}
+
+	output, err := CaptureOutput(t, func() error {
+		return commands.TagCommand([]string{"v1"}, commands.TagOptions{Annotate: true, Message: "Release one"})
+	})
+	if err != nil {
+		t.Fatalf("Tag command failed: %v", err)
+	}
+	if !strings.Contains(output, "Created tag 'v1'") {
+		t.Errorf("Expected a creation message, got %q", output)
+	}
+
+	if _, err := CaptureOutput(t, func() error {
+		return commands.TagCommand([]string{"latest", "HEAD"}, commands.TagOptions{})
+	}); err != nil {
+		t.Fatalf("Tag command failed: %v", err)
+	}
+
+	output, err = CaptureOutput(t, func() error { return commands.TagCommand(nil, commands.TagOptions{}) })
+	if err != nil {
+		t.Fatalf("Listing tags failed: %v", err)
+	}
+	if output != "latest\nv1\n" {
+		t.Errorf("Expected both tags listed, got %q", output)
+	}
+
+	output, err = CaptureOutput(t, func() error {
+		return commands.TagCommand([]string{"v*"}, commands.TagOptions{List: true})
+	})
+	if err != nil {
+		t.Fatalf("Listing tags failed: %v", err)
+	}
+	if output != "v1\n" {
+		t.Errorf("Expected only v1 to match the pattern, got %q", output)
+	}
+
+	output, err = CaptureOutput(t, func() error {
+		return commands.TagCommand([]string{"v1"}, commands.TagOptions{Show: true})
+	})
+	if err != nil {
+		t.Fatalf("Showing tag failed: %v", err)
+	}
+	for _, want := range []string{"tag v1\n", "Tagger: ", "Release one\n", "commit " + commit, "    Initial"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected tag output to contain %q, got %q", want, output)
+		}
+	}
+
+	output, err = CaptureOutput(t, func() error {
+		return commands.TagCommand([]string{"latest"}, commands.TagOptions{Delete: true})
+	})
+	if err != nil {
+		t.Fatalf("Deleting tag failed: %v", err)
+	}
+	if !strings.Contains(output, "Deleted tag 'latest' (was "+commit[:8]+")") {
+		t.Errorf("Expected a deletion message, got %q", output)
+	}
+}
@@ -3,12 +3,15 @@ package tests
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
 	"github.com/xhad/yag/internal/storage"
 	"github.com/xhad/yag/tests/testutil"
 )
@@ -312,3 +315,119 @@ func initTestRepoWithLogger(path string, log *testutil.Logger) error {
 
 	return nil
 }
+
+// SetupRepository initializes an empty repository in a temporary directory and changes into it
+// The directory is removed and the original working directory restored when the test finishes
+func SetupRepository(t *testing.T) (string, *repository.Repository) {
+	t.Helper()
+
+	// Create a temporary directory for the test
+	tempDir, err := os.MkdirTemp("", "yag_test_repo_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	// Save current directory and change to the temporary one
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(originalDir)
+		os.RemoveAll(tempDir)
+	})
+
+	repo, err := repository.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	return tempDir, repo
+}
+
+// WriteTestFile writes content to a file relative to the repository root, creating parent directories
+func WriteTestFile(t *testing.T, root, relPath, content string) {
+	t.Helper()
+
+	path := filepath.Join(root, relPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", relPath, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", relPath, err)
+	}
+}
+
+// ReadTestFile returns the content of a file relative to the repository root, or an empty string if it is missing
+func ReadTestFile(t *testing.T, root, relPath string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(root, relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ""
+		}
+		t.Fatalf("Failed to read %s: %v", relPath, err)
+	}
+
+	return string(data)
+}
+
+// CommitTestFiles writes the given files, stages them and commits them with the given message
+func CommitTestFiles(t *testing.T, repo *repository.Repository, root string, files map[string]string, message string) string {
+	t.Helper()
+
+	for relPath, content := range files {
+		WriteTestFile(t, root, relPath, content)
+		if err := repo.Add(filepath.Join(root, relPath)); err != nil {
+			t.Fatalf("Failed to add %s: %v", relPath, err)
+		}
+	}
+
+	commitID, err := repo.Commit(message)
+	if err != nil {
+		t.Fatalf("Failed to commit %q: %v", message, err)
+	}
+
+	return commitID
+}
+
+// CaptureOutput runs fn while capturing everything it writes to stdout
+func CaptureOutput(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	// Drain the pipe concurrently so large outputs cannot block fn
+	done := make(chan string)
+	go func() {
+		var buf strings.Builder
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	fnErr := fn()
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	return <-done, fnErr
+}
+
+// RemoveTestFile deletes a file relative to the repository root
+func RemoveTestFile(t *testing.T, root, relPath string) {
+	t.Helper()
+
+	if err := os.Remove(filepath.Join(root, relPath)); err != nil {
+		t.Fatalf("Failed to remove %s: %v", relPath, err)
+	}
+}
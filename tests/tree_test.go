@@ -0,0 +1,299 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xhad/yag/internal/core"
+	"github.com/xhad/yag/internal/repository"
+	"github.com/xhad/yag/internal/storage"
+)
+
+// TestTreeRoundTrip tests that nested trees can be stored and read back from storage
+func TestTreeRoundTrip(t *testing.T) {
+	// Create a temporary directory for the test
+	tempDir, err := os.MkdirTemp("", "yag_test_tree_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir) // Clean up after the test
+
+	// Initialize storage
+	fs := storage.NewFileSystemStorage(tempDir)
+	if err := fs.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Build a nested structure: root -> docs -> guides -> intro.md
+	blob := core.NewBlob([]byte("# Intro"))
+	if err := fs.StoreObject(blob); err != nil {
+		t.Fatalf("Failed to store blob: %v", err)
+	}
+
+	guides := core.NewTree()
+	guides.AddFile("intro.md", blob.ID())
+
+	docs := core.NewTree()
+	docs.AddDirectory("guides", guides.ID())
+	docs.AddFile("index.md", blob.ID())
+
+	root := core.NewTree()
+	root.AddDirectory("docs", docs.ID())
+	root.AddFile("README.md", blob.ID())
+
+	for _, tree := range []*core.Tree{guides, docs, root} {
+		if err := fs.StoreObject(tree); err != nil {
+			t.Fatalf("Failed to store tree: %v", err)
+		}
+	}
+
+	// Walk the hierarchy back down from the root hash
+	obj, err := fs.GetObject(root.ID())
+	if err != nil {
+		t.Fatalf("Failed to read root tree: %v", err)
+	}
+	loadedRoot, ok := obj.(*core.Tree)
+	if !ok {
+		t.Fatalf("Expected a tree, got %T", obj)
+	}
+	if loadedRoot.ID() != root.ID() {
+		t.Errorf("Root hash changed on round trip: %s != %s", loadedRoot.ID(), root.ID())
+	}
+
+	entries := loadedRoot.GetEntries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 root entries, got %d", len(entries))
+	}
+	if entries[0].Name != "README.md" || entries[0].Mode != core.ModeFile {
+		t.Errorf("Unexpected first root entry: %+v", entries[0])
+	}
+	if entries[1].Name != "docs" || entries[1].Mode != core.ModeDir {
+		t.Errorf("Unexpected second root entry: %+v", entries[1])
+	}
+
+	obj, err = fs.GetObject(entries[1].Hash)
+	if err != nil {
+		t.Fatalf("Failed to read docs tree: %v", err)
+	}
+	loadedDocs := obj.(*core.Tree)
+	if loadedDocs.ID() != docs.ID() {
+		t.Errorf("Docs hash changed on round trip")
+	}
+
+	var guidesHash string
+	for _, entry := range loadedDocs.GetEntries() {
+		if entry.Name == "guides" {
+			guidesHash = entry.Hash
+		}
+	}
+
+	obj, err = fs.GetObject(guidesHash)
+	if err != nil {
+		t.Fatalf("Failed to read guides tree: %v", err)
+	}
+	loadedGuides := obj.(*core.Tree)
+	guideEntries := loadedGuides.GetEntries()
+	if len(guideEntries) != 1 || guideEntries[0].Hash != blob.ID() {
+		t.Errorf("Guides tree should point at the intro blob, got %+v", guideEntries)
+	}
+
+	// An empty tree should survive the round trip as well
+	empty := core.NewTree()
+	serialized, err := empty.Serialize()
+	if err != nil {
+		t.Fatalf("Failed to serialize empty tree: %v", err)
+	}
+	_, data, err := core.DeserializeObject(serialized)
+	if err != nil {
+		t.Fatalf("Failed to split empty tree header: %v", err)
+	}
+	loadedEmpty, err := core.DeserializeTree(data)
+	if err != nil {
+		t.Fatalf("Failed to deserialize empty tree: %v", err)
+	}
+	if loadedEmpty.ID() != empty.ID() {
+		t.Errorf("Empty tree hash changed on round trip")
+	}
+}
+
+// TestCommitPersistsSubtrees tests that a commit stores every tree in its directory hierarchy
+func TestCommitPersistsSubtrees(t *testing.T) {
+	// Create a temporary directory for the test
+	tempDir, err := os.MkdirTemp("", "yag_test_subtrees_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir) // Clean up after the test
+
+	repo, err := repository.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// a/ has no files of its own, only the b/ subdirectory
+	nestedDir := filepath.Join(tempDir, "a", "b")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("Failed to create nested directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(nestedDir, "c.txt"), []byte("deep"), 0644); err != nil {
+		t.Fatalf("Failed to create nested file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "top.txt"), []byte("top"), 0644); err != nil {
+		t.Fatalf("Failed to create top-level file: %v", err)
+	}
+
+	if err := repo.Add(tempDir); err != nil {
+		t.Fatalf("Failed to add files: %v", err)
+	}
+	commitID, err := repo.Commit("Nested layout")
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	fs := repo.GetStorage()
+	obj, err := fs.GetObject(commitID)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	commit := obj.(*core.Commit)
+
+	// Walk the commit's tree and collect every file path we can reach
+	found := make(map[string]string)
+	var walk func(hash, prefix string)
+	walk = func(hash, prefix string) {
+		obj, err := fs.GetObject(hash)
+		if err != nil {
+			t.Fatalf("Tree %s for %q is not in the object store: %v", hash, prefix, err)
+		}
+		for _, entry := range obj.(*core.Tree).GetEntries() {
+			path := filepath.Join(prefix, entry.Name)
+			if entry.Mode == core.ModeDir {
+				walk(entry.Hash, path)
+			} else {
+				found[path] = entry.Hash
+			}
+		}
+	}
+	walk(commit.TreeHash(), "")
+
+	for _, path := range []string{"top.txt", filepath.Join("a", "b", "c.txt")} {
+		if _, ok := found[path]; !ok {
+			t.Errorf("Expected %s to be reachable from the commit tree, got %v", path, found)
+		}
+	}
+
+	// BuildTreeHierarchy should report a tree for every directory level
+	_, trees := core.BuildTreeHierarchy(map[string]string{
+		filepath.Join("a", "b", "c.txt"): "hash",
+	})
+	if len(trees) != 3 {
+		t.Errorf("Expected 3 trees (root, a, a/b), got %d", len(trees))
+	}
+}
+
+// TestDiffTrees tests the recursive tree comparison and that identical subtrees are skipped
+func TestDiffTrees(t *testing.T) {
+	// Build two versions of a tree in memory
+	oldTrees := map[string]string{
+		"README.md":                         "readme",
+		filepath.Join("lib", "util.go"):     "util v1",
+		filepath.Join("lib", "old.go"):      "old",
+		filepath.Join("vendor", "dep", "x"): "vendored",
+		"script.sh":                         "echo",
+		"swap":                              "file becomes dir",
+	}
+	newTrees := map[string]string{
+		"README.md":                         "readme",
+		filepath.Join("lib", "util.go"):     "util v2",
+		filepath.Join("lib", "new.go"):      "new",
+		filepath.Join("vendor", "dep", "x"): "vendored",
+		"script.sh":                         "echo",
+		filepath.Join("swap", "inner.txt"):  "now a dir",
+	}
+
+	store := make(map[string]*core.Tree)
+	build := func(files map[string]string) *core.Tree {
+		hashes := make(map[string]string)
+		for path, content := range files {
+			hashes[path] = core.NewBlob([]byte(content)).ID()
+		}
+		root, trees := core.BuildTreeHierarchy(hashes)
+		for _, tree := range trees {
+			store[tree.ID()] = tree
+		}
+		return root
+	}
+	oldRoot := build(oldTrees)
+	newRoot := build(newTrees)
+
+	// Mark script.sh as executable in the new tree to produce a mode-only change
+	modeRoot := core.NewTree()
+	for _, entry := range newRoot.GetEntries() {
+		mode := entry.Mode
+		if entry.Name == "script.sh" {
+			mode = 0100755
+		}
+		modeRoot.AddEntry(entry.Name, entry.Hash, mode)
+	}
+	store[modeRoot.ID()] = modeRoot
+
+	// Count which trees get loaded
+	loaded := make(map[string]int)
+	load := func(hash string) (*core.Tree, error) {
+		loaded[hash]++
+		return store[hash], nil
+	}
+
+	changes, err := core.DiffTrees(load, oldRoot.ID(), modeRoot.ID())
+	if err != nil {
+		t.Fatalf("DiffTrees failed: %v", err)
+	}
+
+	got := make(map[string]core.TreeChangeType)
+	for _, change := range changes {
+		got[change.Path] = change.Type
+	}
+	expected := map[string]core.TreeChangeType{
+		filepath.Join("lib", "util.go"):    core.TreeEntryModified,
+		filepath.Join("lib", "old.go"):     core.TreeEntryDeleted,
+		filepath.Join("lib", "new.go"):     core.TreeEntryAdded,
+		"script.sh":                        core.TreeEntryModeChanged,
+		"swap":                             core.TreeEntryDeleted,
+		filepath.Join("swap", "inner.txt"): core.TreeEntryAdded,
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Expected %d changes, got %v", len(expected), got)
+	}
+	for path, changeType := range expected {
+		if got[path] != changeType {
+			t.Errorf("Expected %s to be %s, got %q", path, changeType, got[path])
+		}
+	}
+
+	// The unchanged vendor subtree must not be loaded at all
+	var vendorHash string
+	for _, entry := range oldRoot.GetEntries() {
+		if entry.Name == "vendor" {
+			vendorHash = entry.Hash
+		}
+	}
+	if loaded[vendorHash] != 0 {
+		t.Errorf("Identical vendor subtree should be skipped, it was loaded %d times", loaded[vendorHash])
+	}
+
+	// Comparing a tree with itself loads nothing and reports nothing
+	loaded = make(map[string]int)
+	if changes, _ := core.DiffTrees(load, oldRoot.ID(), oldRoot.ID()); len(changes) != 0 || len(loaded) != 0 {
+		t.Errorf("Identical trees should produce no changes and no loads")
+	}
+
+	// An empty old side reports every file as added
+	changes, err = core.DiffTrees(load, "", oldRoot.ID())
+	if err != nil {
+		t.Fatalf("DiffTrees from empty failed: %v", err)
+	}
+	if len(changes) != len(oldTrees) {
+		t.Errorf("Expected %d added files, got %d", len(oldTrees), len(changes))
+	}
+}